@@ -0,0 +1,76 @@
+// Package client queries the /api/v1 JSON API of a fluffy-robot dashboard.
+// The types and one method per endpoint are generated from the server's
+// OpenAPI document into api_gen.go, this file is the transport they share.
+package client
+
+//go:generate go run ../tools/apigen -out api_gen.go
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	// BaseURL is where the dashboard is served, such as http://localhost:8845
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Error is a response other than 200, Message is the server's explanation
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("fluffy-robot api: %d %s", e.StatusCode, e.Message)
+}
+
+// get requests path and decodes the response's data into data
+func (c *Client) get(ctx context.Context, path string, query url.Values, data any) (*APIMeta, error) {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr APIError
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			apiErr.Error = http.StatusText(resp.StatusCode)
+		}
+		return nil, &Error{StatusCode: resp.StatusCode, Message: apiErr.Error}
+	}
+
+	var body struct {
+		Data json.RawMessage `json:"data"`
+		Meta *APIMeta        `json:"meta"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	if err := json.Unmarshal(body.Data, data); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return body.Meta, nil
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+	"github.com/papaburgs/fluffy-robot/internal/frontend"
+)
+
+func testClient(t *testing.T) *Client {
+	store := ds.NewMemoryStore()
+	if err := store.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	now := time.Now().Add(-time.Minute).Unix()
+	err := store.StoreAgents([]ds.PublicAgent{
+		{Symbol: "ALPHA", Credits: 175000, Headquarters: "X1-AB12-A1", ShipCount: 2, StartingFaction: "COSMIC"},
+		{Symbol: "BRAVO", Credits: 250000, Headquarters: "X1-CD34-B2", ShipCount: 3, StartingFaction: "VOID"},
+	}, now)
+	if err != nil {
+		t.Fatalf("StoreAgents: %v", err)
+	}
+	err = store.UpdateJumpGates([]ds.JGInfo{
+		{Jumpgate: "X1-CD34-I1", System: "X1-CD34", Headquarters: "X1-CD34-B2", Status: ds.Const},
+	})
+	if err != nil {
+		t.Fatalf("UpdateJumpGates: %v", err)
+	}
+	err = store.AddConstructions([]ds.JGConstruction{{Timestamp: now, Jumpgate: "X1-CD34-I1", Materials: ds.Materials{
+		{Symbol: "FAB_MATS", Required: 1600, Fulfilled: 500},
+	}}}, now)
+	if err != nil {
+		t.Fatalf("AddConstructions: %v", err)
+	}
+
+	srv, err := frontend.NewServer(store, "../internal/frontend", "../internal/frontend")
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return NewClient(ts.URL)
+}
+
+func TestClient(t *testing.T) {
+	c := testClient(t)
+	ctx := context.Background()
+
+	hist, meta, err := c.GetAgentHistory(ctx, GetAgentHistoryParams{Agents: []string{"BRAVO"}, Tier: "1h"})
+	if err != nil {
+		t.Fatalf("GetAgentHistory: %v", err)
+	}
+	if meta.Reset != "2026-01-04" || meta.Tier != "1h" || len(hist) != 1 || hist[0].Credits.Last != 250000 {
+		t.Fatalf("GetAgentHistory = %+v, %+v", hist, meta)
+	}
+
+	status, _, err := c.GetConstructionStatus(ctx, GetConstructionStatusParams{Agents: []string{"BRAVO"}})
+	if err != nil {
+		t.Fatalf("GetConstructionStatus: %v", err)
+	}
+	if len(status) != 1 || status[0].Jumpgate != "X1-CD34-I1" || status[0].Materials[0].Fulfilled != 500 {
+		t.Fatalf("GetConstructionStatus = %+v", status)
+	}
+
+	rows, meta, err := c.GetAgents(ctx, GetAgentsParams{Active: true, Limit: 1})
+	if err != nil || meta.Total != 1 || len(rows) != 1 || rows[0].Symbol != "BRAVO" {
+		t.Fatalf("GetAgents = %+v, %+v, %v", rows, meta, err)
+	}
+
+	resets, _, err := c.GetResets(ctx)
+	if err != nil || len(resets) != 1 {
+		t.Fatalf("GetResets = %v, %v", resets, err)
+	}
+}
+
+func TestClientError(t *testing.T) {
+	c := testClient(t)
+	_, _, err := c.GetStats(context.Background(), GetStatsParams{Reset: "2020-01-01"})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message == "" {
+		t.Fatalf("GetStats for an unknown reset = %v", err)
+	}
+}
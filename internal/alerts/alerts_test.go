@@ -0,0 +1,338 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+)
+
+const testRules = `
+webhooks:
+  - name: discord
+    url: %s
+rules:
+  - name: alpha-rich
+    type: credits_above
+    agents: [alpha]
+    threshold: 1000000
+    cooldown: 2h
+  - name: top-three
+    type: rank_reached
+    board: credits
+    rank: 3
+  - name: our-gate
+    type: construction_started
+    agents: [ALPHA]
+  - name: any-gate-done
+    type: construction_complete
+  - name: new-reset
+    type: reset
+`
+
+// hook stands in for a webhook and keeps what was posted to it
+type hook struct {
+	*httptest.Server
+	mu       sync.Mutex
+	payloads []payload
+}
+
+func newHook(t *testing.T) *hook {
+	h := &hook{}
+	h.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p payload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Errorf("bad payload: %v", err)
+		}
+		h.mu.Lock()
+		h.payloads = append(h.payloads, p)
+		h.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(h.Close)
+	return h
+}
+
+func (h *hook) take() []payload {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	res := h.payloads
+	h.payloads = nil
+	return res
+}
+
+type world struct {
+	credits map[string]int64
+	board   []string
+	gates   map[string]ds.ConstructionStatus
+}
+
+// store puts w into s for thisReset at ts
+func (w world) store(t *testing.T, s ds.Store, thisReset ds.Reset, ts int64) {
+	t.Helper()
+	if err := s.UpdateReset(thisReset); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	hqs := map[string]string{"ALPHA": "X1-AB12-A1", "BRAVO": "X1-CD34-B2"}
+	agents := []ds.PublicAgent{}
+	for sym, credits := range w.credits {
+		agents = append(agents, ds.PublicAgent{Symbol: sym, Credits: credits, Headquarters: hqs[sym], ShipCount: 2})
+	}
+	if err := s.StoreAgents(agents, ts); err != nil {
+		t.Fatalf("StoreAgents: %v", err)
+	}
+	var status ds.ResponseStatus
+	status.ResetDate = string(thisReset)
+	for _, sym := range w.board {
+		status.Leaderboards.MostCredits = append(status.Leaderboards.MostCredits, struct {
+			AgentSymbol string `json:"agentSymbol"`
+			Credits     int64  `json:"credits"`
+		}{AgentSymbol: sym, Credits: w.credits[sym]})
+	}
+	if err := s.StoreLeaderboards(status, ts); err != nil {
+		t.Fatalf("StoreLeaderboards: %v", err)
+	}
+	jgs := []ds.JGInfo{}
+	for sys, st := range w.gates {
+		jgs = append(jgs, ds.JGInfo{Jumpgate: sys + "-I1", System: sys, Status: st})
+	}
+	if err := s.UpdateJumpGates(jgs); err != nil {
+		t.Fatalf("UpdateJumpGates: %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse([]byte(strings.Replace(testRules, "%s", "http://localhost/hook", 1)))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(cfg.Rules) != 5 || cfg.Rules[0].Agents[0] != "ALPHA" || cfg.Rules[0].Cooldown != 2*time.Hour {
+		t.Fatalf("unexpected rules: %+v", cfg.Rules)
+	}
+	if cfg.Rules[1].Cooldown != DefaultCooldown {
+		t.Fatalf("expected the default cooldown, got %v", cfg.Rules[1].Cooldown)
+	}
+
+	// JSON loads the same way
+	js := `{"webhooks": [{"name": "slack", "url": "http://localhost/hook"}],
+		"rules": [{"name": "reset", "type": "reset", "webhooks": ["slack"]}]}`
+	if cfg, err := Parse([]byte(js)); err != nil || len(cfg.Rules) != 1 {
+		t.Fatalf("Parse JSON: %+v %v", cfg, err)
+	}
+
+	for name, bad := range map[string]string{
+		"unknown type":    "rules: [{name: a, type: nope}]",
+		"no threshold":    "rules: [{name: a, type: credits_above}]",
+		"bad board":       "rules: [{name: a, type: rank_reached, board: ships, rank: 1}]",
+		"unknown webhook": "rules: [{name: a, type: reset, webhooks: [nope]}]",
+		"duplicate rule":  "rules: [{name: a, type: reset}, {name: a, type: reset}]",
+		"no url":          "webhooks: [{name: a}]",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestEngine(t *testing.T) {
+	h := newHook(t)
+	cfg, err := Parse([]byte(strings.Replace(testRules, "%s", h.URL, 1)))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	s := ds.NewMemoryStore()
+	e := NewEngine(s, cfg)
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	e.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	check := func(w world, thisReset ds.Reset, want ...string) {
+		t.Helper()
+		w.store(t, s, thisReset, now.Unix())
+		fired := e.Check(ctx, thisReset)
+		e.sender.wait()
+		got := h.take()
+		if len(fired) != len(want) || len(got) != len(want) {
+			t.Fatalf("expected %d alerts, fired %+v, posted %+v", len(want), fired, got)
+		}
+		for i, msg := range want {
+			if got[i].Content != msg || got[i].Text != msg || got[i].Alert.Reset != string(thisReset) {
+				t.Fatalf("alert %d: got %+v, want %q", i, got[i], msg)
+			}
+		}
+	}
+
+	start := world{
+		credits: map[string]int64{"ALPHA": 900000, "BRAVO": 2000000},
+		board:   []string{"BRAVO"},
+		gates:   map[string]ds.ConstructionStatus{"X1-AB12": ds.Active, "X1-CD34": ds.Const},
+	}
+	// the first check only looks
+	check(start, "2026-01-04")
+
+	next := world{
+		credits: map[string]int64{"ALPHA": 1200000, "BRAVO": 2100000},
+		board:   []string{"BRAVO", "ALPHA"},
+		gates:   map[string]ds.ConstructionStatus{"X1-AB12": ds.Const, "X1-CD34": ds.Complete},
+	}
+	check(next, "2026-01-04",
+		"[alpha-rich] ALPHA passed 1000000 credits, now at 1200000",
+		"[top-three] ALPHA reached #2 on the credits leaderboard",
+		"[our-gate] Construction started on X1-AB12-I1 in X1-AB12, home of ALPHA",
+		"[any-gate-done] Jumpgate X1-CD34-I1 in X1-CD34 is complete, home of BRAVO",
+	)
+	// nothing moved
+	check(next, "2026-01-04")
+
+	// ALPHA dips and passes the mark again inside the cooldown
+	dip := world{credits: start.credits, board: start.board, gates: next.gates}
+	now = now.Add(30 * time.Minute)
+	check(dip, "2026-01-04")
+	now = now.Add(30 * time.Minute)
+	check(next, "2026-01-04", "[top-three] ALPHA reached #2 on the credits leaderboard")
+	// and once more after it
+	now = now.Add(2 * time.Hour)
+	check(dip, "2026-01-04")
+	check(next, "2026-01-04",
+		"[alpha-rich] ALPHA passed 1000000 credits, now at 1200000",
+		"[top-three] ALPHA reached #2 on the credits leaderboard",
+	)
+
+	// a new reset is only news about the reset
+	check(start, "2026-01-11", "[new-reset] New reset 2026-01-11 detected")
+	check(start, "2026-01-11")
+}
+
+func TestEngine_WebhookFailure(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(down.Close)
+	up := newHook(t)
+	cfg, err := Parse([]byte(`
+webhooks:
+  - {name: down, url: "` + down.URL + `"}
+  - {name: up, url: "` + up.URL + `"}
+  - {name: quiet, url: "` + up.URL + `/quiet"}
+rules:
+  - {name: new-reset, type: reset, webhooks: [down, up]}
+`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	s := ds.NewMemoryStore()
+	e := NewEngine(s, cfg)
+	world{}.store(t, s, "2026-01-04", time.Now().Unix())
+	e.Check(context.Background(), "2026-01-04")
+	world{}.store(t, s, "2026-01-11", time.Now().Unix())
+	if fired := e.Check(context.Background(), "2026-01-11"); len(fired) != 1 {
+		t.Fatalf("expected the reset alert, got %+v", fired)
+	}
+	e.sender.wait()
+	// the failing webhook does not stop the others, and quiet is not asked
+	if got := up.take(); len(got) != 1 || got[0].Alert.Rule != "new-reset" {
+		t.Fatalf("expected one post to the working webhook, got %+v", got)
+	}
+}
+
+// flakyStore fails to read the jumpgates while broken is set
+type flakyStore struct {
+	ds.Store
+	broken bool
+}
+
+func (s *flakyStore) GetJumpgateList(thisReset ds.Reset) ([]ds.JGInfo, error) {
+	if s.broken {
+		return nil, errors.New("disk on fire")
+	}
+	return s.Store.GetJumpgateList(thisReset)
+}
+
+func TestEngine_ReadFailure(t *testing.T) {
+	h := newHook(t)
+	cfg, err := Parse([]byte(strings.Replace(testRules, "%s", h.URL, 1)))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	s := &flakyStore{Store: ds.NewMemoryStore()}
+	e := NewEngine(s, cfg)
+	w := world{
+		credits: map[string]int64{"ALPHA": 900000, "BRAVO": 2000000},
+		board:   []string{"BRAVO", "ALPHA"},
+		gates:   map[string]ds.ConstructionStatus{"X1-AB12": ds.Const, "X1-CD34": ds.Complete},
+	}
+	w.store(t, s, "2026-01-04", time.Now().Unix())
+	e.Check(context.Background(), "2026-01-04")
+
+	// a failed read is skipped, and the one after is compared with the
+	// last good one rather than the failure
+	s.broken = true
+	if fired := e.Check(context.Background(), "2026-01-04"); len(fired) != 0 {
+		t.Fatalf("a failed read fired %+v", fired)
+	}
+	s.broken = false
+	if fired := e.Check(context.Background(), "2026-01-04"); len(fired) != 0 {
+		t.Fatalf("expected nothing to have changed, fired %+v", fired)
+	}
+
+	// a new reset is still announced when its data cannot be read yet
+	s.broken = true
+	w.store(t, s, "2026-01-11", time.Now().Unix())
+	if fired := e.Check(context.Background(), "2026-01-11"); len(fired) != 1 || fired[0].Rule != "new-reset" {
+		t.Fatalf("expected the reset alert, got %+v", fired)
+	}
+	s.broken = false
+	if fired := e.Check(context.Background(), "2026-01-11"); len(fired) != 0 {
+		t.Fatalf("the first read of the new reset fired %+v", fired)
+	}
+	e.sender.wait()
+}
+
+func TestEngine_SlowWebhook(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(release) })
+	cfg, err := Parse([]byte(`
+webhooks: [{name: slow, url: "` + slow.URL + `"}]
+rules: [{name: new-reset, type: reset}]
+`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	s := ds.NewMemoryStore()
+	e := NewEngine(s, cfg)
+	world{}.store(t, s, "2026-01-04", time.Now().Unix())
+	e.Check(context.Background(), "2026-01-04")
+	world{}.store(t, s, "2026-01-11", time.Now().Unix())
+
+	// the check returns while the webhook is still being posted to
+	done := make(chan []Alert)
+	go func() {
+		done <- e.Check(context.Background(), "2026-01-11")
+	}()
+	select {
+	case fired := <-done:
+		if len(fired) != 1 {
+			t.Fatalf("expected the reset alert, got %+v", fired)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Check waited for the webhook")
+	}
+}
+
+func TestEngine_Nil(t *testing.T) {
+	var e *Engine
+	if fired := e.Check(context.Background(), "2026-01-04"); fired != nil {
+		t.Fatalf("a nil engine fired %+v", fired)
+	}
+}
@@ -0,0 +1,297 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+	"github.com/papaburgs/fluffy-robot/internal/logging"
+	"github.com/papaburgs/fluffy-robot/internal/metrics"
+)
+
+// Alert is one rule firing about one agent, system or reset
+type Alert struct {
+	Rule    string    `json:"rule"`
+	Type    RuleType  `json:"type"`
+	Subject string    `json:"subject"`
+	Message string    `json:"message"`
+	Reset   string    `json:"reset"`
+	Time    time.Time `json:"time"`
+}
+
+// Engine checks the rules against the store after each collector tick.
+// Everything is a transition from the previous check, so the first check
+// only records where things stand. A nil *Engine does nothing
+type Engine struct {
+	store  ds.Store
+	cfg    Config
+	sender *sender
+	now    func() time.Time
+
+	mu      sync.Mutex
+	started bool
+	reset   ds.Reset
+	credits map[string]int64
+	// ranks is board to agent to 1 based rank
+	ranks map[string]map[string]int
+	gates map[string]ds.ConstructionStatus
+	// fired is when a rule last fired, keyed by rule and subject
+	fired map[string]time.Time
+}
+
+func NewEngine(store ds.Store, cfg Config) *Engine {
+	return &Engine{
+		store:  store,
+		cfg:    cfg,
+		sender: newSender(cfg.Webhooks),
+		now:    time.Now,
+		fired:  make(map[string]time.Time),
+	}
+}
+
+// snapshot is what the rules look at, read from the store
+type snapshot struct {
+	agents map[string]ds.Agent
+	ranks  map[string]map[string]int
+	gates  map[string]ds.JGInfo
+}
+
+// load reads the snapshot, failing on any store error since a partial one
+// would look like every agent and gate changed
+func (e *Engine) load(thisReset ds.Reset) (snapshot, error) {
+	s := snapshot{
+		agents: make(map[string]ds.Agent),
+		ranks:  make(map[string]map[string]int),
+		gates:  make(map[string]ds.JGInfo),
+	}
+	aList, err := e.store.GetAgentList(thisReset)
+	if err != nil {
+		return s, fmt.Errorf("loading agents: %w", err)
+	}
+	for _, a := range aList {
+		s.agents[a.Symbol] = a
+	}
+	creditLB, chartLB, err := e.store.GetLeaderboard(thisReset)
+	if err != nil {
+		return s, fmt.Errorf("loading leaderboards: %w", err)
+	}
+	for board, entries := range map[string][]ds.LeaderboardEntry{"credits": creditLB, "charts": chartLB} {
+		s.ranks[board] = make(map[string]int, len(entries))
+		for i, entry := range entries {
+			s.ranks[board][entry.Symbol] = i + 1
+		}
+	}
+	jgList, err := e.store.GetJumpgateList(thisReset)
+	if err != nil {
+		return s, fmt.Errorf("loading jumpgates: %w", err)
+	}
+	for _, jg := range jgList {
+		s.gates[jg.System] = jg
+	}
+	return s, nil
+}
+
+// Check compares the store's view of thisReset with the last check and
+// queues the alerts that fire for delivery, which it also returns. When the
+// store cannot be read nothing is compared and the last view is kept
+func (e *Engine) Check(ctx context.Context, thisReset ds.Reset) []Alert {
+	if e == nil || thisReset == "" {
+		return nil
+	}
+	snap, err := e.load(thisReset)
+	if err != nil {
+		logging.Error("alerts: cannot check", thisReset, err)
+	}
+
+	e.mu.Lock()
+	now := e.now()
+	candidates := []Alert{}
+	switch {
+	case !e.started:
+		// nothing to compare against yet
+	case thisReset != e.reset:
+		// a new reset starts everything over, so only the reset itself
+		// is news
+		candidates = e.resetAlerts(thisReset)
+	case err == nil:
+		candidates = e.compare(snap)
+	}
+	if err == nil {
+		e.started = true
+		e.reset = thisReset
+		e.credits = make(map[string]int64, len(snap.agents))
+		for sym, a := range snap.agents {
+			e.credits[sym] = a.Credits
+		}
+		e.ranks = snap.ranks
+		e.gates = make(map[string]ds.ConstructionStatus, len(snap.gates))
+		for sys, jg := range snap.gates {
+			e.gates[sys] = jg.Status
+		}
+	} else if thisReset != e.reset {
+		// the new reset is announced, and the next good read of it only
+		// records where things stand
+		e.started = false
+	}
+
+	fired := []Alert{}
+	for _, a := range candidates {
+		rule := e.rule(a.Rule)
+		key := a.Rule + "/" + a.Subject
+		if last, ok := e.fired[key]; ok && now.Sub(last) < rule.Cooldown {
+			metrics.AlertsSuppressed.Add(1)
+			continue
+		}
+		e.fired[key] = now
+		a.Reset = string(thisReset)
+		a.Time = now
+		fired = append(fired, a)
+	}
+	e.mu.Unlock()
+
+	for _, a := range fired {
+		metrics.AlertsFired.Add(1)
+		e.sender.enqueue(ctx, a, e.rule(a.Rule).Webhooks)
+	}
+	return fired
+}
+
+func (e *Engine) rule(name string) Rule {
+	for _, r := range e.cfg.Rules {
+		if r.Name == name {
+			return r
+		}
+	}
+	return Rule{}
+}
+
+func (e *Engine) resetAlerts(thisReset ds.Reset) []Alert {
+	res := []Alert{}
+	for _, r := range e.cfg.Rules {
+		if r.Type == ResetDetected {
+			res = append(res, Alert{
+				Rule:    r.Name,
+				Type:    r.Type,
+				Subject: string(thisReset),
+				Message: fmt.Sprintf("New reset %s detected", thisReset),
+			})
+		}
+	}
+	return res
+}
+
+// compare finds what changed since the last check, ordered by rule then
+// subject so deliveries are predictable
+func (e *Engine) compare(snap snapshot) []Alert {
+	res := []Alert{}
+	for _, r := range e.cfg.Rules {
+		found := []Alert{}
+		switch r.Type {
+		case CreditsAbove:
+			for sym, a := range snap.agents {
+				prev, ok := e.credits[sym]
+				if !ok || !matchAgent(r, a) || prev >= r.Threshold || a.Credits < r.Threshold {
+					continue
+				}
+				found = append(found, Alert{
+					Subject: sym,
+					Message: fmt.Sprintf("%s passed %d credits, now at %d", sym, r.Threshold, a.Credits),
+				})
+			}
+		case RankReached:
+			prevRanks := e.ranks[r.Board]
+			for sym, rank := range snap.ranks[r.Board] {
+				a, ok := snap.agents[sym]
+				if !ok {
+					a = ds.Agent{Symbol: sym}
+				}
+				if !matchAgent(r, a) {
+					continue
+				}
+				prev := prevRanks[sym]
+				if rank > r.Rank || prev != 0 && prev <= r.Rank {
+					continue
+				}
+				found = append(found, Alert{
+					Subject: sym,
+					Message: fmt.Sprintf("%s reached #%d on the %s leaderboard", sym, rank, r.Board),
+				})
+			}
+		case ConstructionStarted, ConstructionComplete:
+			for sys, jg := range snap.gates {
+				if !e.matchSystem(r, sys, snap) {
+					continue
+				}
+				prev := e.gates[sys]
+				var msg string
+				if r.Type == ConstructionStarted && prev < ds.Const && jg.Status >= ds.Const {
+					msg = fmt.Sprintf("Construction started on %s in %s", jg.Jumpgate, sys)
+				}
+				if r.Type == ConstructionComplete && prev != ds.Complete && jg.Status == ds.Complete {
+					msg = fmt.Sprintf("Jumpgate %s in %s is complete", jg.Jumpgate, sys)
+				}
+				if msg == "" {
+					continue
+				}
+				if home := homeOf(snap, sys); home != "" {
+					msg += ", home of " + home
+				}
+				found = append(found, Alert{Subject: sys, Message: msg})
+			}
+		}
+		sort.Slice(found, func(i, j int) bool {
+			return found[i].Subject < found[j].Subject
+		})
+		for _, a := range found {
+			a.Rule, a.Type = r.Name, r.Type
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// matchAgent is true when a is one of the rule's agents and in one of its
+// systems, an empty list allows everyone
+func matchAgent(r Rule, a ds.Agent) bool {
+	if len(r.Agents) > 0 && !slices.Contains(r.Agents, a.Symbol) {
+		return false
+	}
+	if len(r.Systems) > 0 && !slices.Contains(r.Systems, a.System) {
+		return false
+	}
+	return true
+}
+
+// matchSystem is true when system is one of the rule's systems or the
+// system of one of its agents, or the rule names neither
+func (e *Engine) matchSystem(r Rule, system string, snap snapshot) bool {
+	if len(r.Agents) == 0 && len(r.Systems) == 0 {
+		return true
+	}
+	if slices.Contains(r.Systems, system) {
+		return true
+	}
+	for _, sym := range r.Agents {
+		if a, ok := snap.agents[sym]; ok && a.System == system {
+			return true
+		}
+	}
+	return false
+}
+
+// homeOf lists the agents with headquarters in system
+func homeOf(snap snapshot, system string) string {
+	names := []string{}
+	for sym, a := range snap.agents {
+		if a.System == system {
+			names = append(names, sym)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
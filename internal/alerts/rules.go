@@ -0,0 +1,143 @@
+// Package alerts watches what the collector stores and posts to webhooks
+// when something a rule asks about happens, like an agent passing a credit
+// mark or a jumpgate being finished.
+package alerts
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type RuleType string
+
+const (
+	// CreditsAbove fires when an agent's credits go from below Threshold
+	// to at or above it
+	CreditsAbove RuleType = "credits_above"
+	// RankReached fires when an agent gets to Rank or better on Board
+	RankReached RuleType = "rank_reached"
+	// ConstructionStarted fires when deliveries start on a jumpgate
+	ConstructionStarted RuleType = "construction_started"
+	// ConstructionComplete fires when a jumpgate is finished
+	ConstructionComplete RuleType = "construction_complete"
+	// ResetDetected fires when the server starts a new reset
+	ResetDetected RuleType = "reset"
+)
+
+// DefaultCooldown is how long a rule stays quiet about the same agent,
+// system or reset after firing, unless the rule sets its own
+const DefaultCooldown = time.Hour
+
+// Rule is one thing to watch for. Agents and Systems narrow it down, for
+// jumpgate rules Agents means the gates in those agents' systems. An empty
+// Webhooks sends to every webhook
+type Rule struct {
+	Name      string        `yaml:"name" json:"name"`
+	Type      RuleType      `yaml:"type" json:"type"`
+	Agents    []string      `yaml:"agents" json:"agents"`
+	Systems   []string      `yaml:"systems" json:"systems"`
+	Threshold int64         `yaml:"threshold" json:"threshold"`
+	Board     string        `yaml:"board" json:"board"`
+	Rank      int           `yaml:"rank" json:"rank"`
+	Cooldown  time.Duration `yaml:"cooldown" json:"cooldown"`
+	Webhooks  []string      `yaml:"webhooks" json:"webhooks"`
+}
+
+// Webhook is somewhere alerts are posted
+type Webhook struct {
+	Name string `yaml:"name" json:"name"`
+	URL  string `yaml:"url" json:"url"`
+}
+
+// Config is a rules file
+type Config struct {
+	Webhooks []Webhook `yaml:"webhooks" json:"webhooks"`
+	Rules    []Rule    `yaml:"rules" json:"rules"`
+}
+
+// LoadFile reads a rules file. YAML is a superset of JSON so .json files
+// load the same way
+func LoadFile(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return Parse(b)
+}
+
+// Parse reads and validates rules from YAML or JSON
+func Parse(b []byte) (Config, error) {
+	var cfg Config
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return Config{}, fmt.Errorf("parsing alert rules: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Validate checks every rule is complete and only names known webhooks, and
+// upper cases agent and system symbols
+func (cfg *Config) Validate() error {
+	hooks := make(map[string]bool, len(cfg.Webhooks))
+	for _, w := range cfg.Webhooks {
+		if w.Name == "" || w.URL == "" {
+			return fmt.Errorf("webhook %q needs a name and a url", w.Name)
+		}
+		if hooks[w.Name] {
+			return fmt.Errorf("webhook %q is defined twice", w.Name)
+		}
+		hooks[w.Name] = true
+	}
+	names := make(map[string]bool, len(cfg.Rules))
+	for i := range cfg.Rules {
+		r := &cfg.Rules[i]
+		if r.Name == "" {
+			return fmt.Errorf("rule %d has no name", i+1)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("rule %q is defined twice", r.Name)
+		}
+		names[r.Name] = true
+
+		switch r.Type {
+		case CreditsAbove:
+			if r.Threshold <= 0 {
+				return fmt.Errorf("rule %q needs a threshold above 0", r.Name)
+			}
+		case RankReached:
+			if r.Board != "credits" && r.Board != "charts" {
+				return fmt.Errorf("rule %q needs board credits or charts, not %q", r.Name, r.Board)
+			}
+			if r.Rank <= 0 {
+				return fmt.Errorf("rule %q needs a rank of 1 or more", r.Name)
+			}
+		case ConstructionStarted, ConstructionComplete, ResetDetected:
+		default:
+			return fmt.Errorf("rule %q has unknown type %q", r.Name, r.Type)
+		}
+		if r.Cooldown < 0 {
+			return fmt.Errorf("rule %q has a negative cooldown", r.Name)
+		}
+		if r.Cooldown == 0 {
+			r.Cooldown = DefaultCooldown
+		}
+		for _, w := range r.Webhooks {
+			if !hooks[w] {
+				return fmt.Errorf("rule %q sends to unknown webhook %q", r.Name, w)
+			}
+		}
+		for j := range r.Agents {
+			r.Agents[j] = strings.ToUpper(strings.TrimSpace(r.Agents[j]))
+		}
+		for j := range r.Systems {
+			r.Systems[j] = strings.ToUpper(strings.TrimSpace(r.Systems[j]))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/papaburgs/fluffy-robot/internal/logging"
+	"github.com/papaburgs/fluffy-robot/internal/metrics"
+)
+
+// webhookTimeout bounds each post so a dead webhook cannot hold up the
+// deliveries behind it for long
+const webhookTimeout = 10 * time.Second
+
+// queueSize is how many alerts can wait for delivery, more are dropped
+const queueSize = 256
+
+// payload is accepted as is by Discord, which shows content, and Slack,
+// which shows text. Anything else gets the whole alert too
+type payload struct {
+	Content string `json:"content"`
+	Text    string `json:"text"`
+	Alert   Alert  `json:"alert"`
+}
+
+// delivery is an alert waiting to be posted
+type delivery struct {
+	ctx   context.Context
+	alert Alert
+	names []string
+}
+
+// sender posts alerts from its own goroutine, in the order they were queued,
+// so slow webhooks do not hold up the collector tick that fired them
+type sender struct {
+	webhooks []Webhook
+	client   *http.Client
+	queue    chan delivery
+	start    sync.Once
+	// pending counts alerts queued and not yet posted
+	pending sync.WaitGroup
+}
+
+func newSender(webhooks []Webhook) *sender {
+	return &sender{
+		webhooks: webhooks,
+		client:   &http.Client{Timeout: webhookTimeout},
+		queue:    make(chan delivery, queueSize),
+	}
+}
+
+// enqueue hands a to the delivery goroutine, started on first use. A full
+// queue drops the alert, counted as a failed post
+func (s *sender) enqueue(ctx context.Context, a Alert, names []string) {
+	s.start.Do(func() {
+		go s.run()
+	})
+	s.pending.Add(1)
+	select {
+	case s.queue <- delivery{ctx: ctx, alert: a, names: names}:
+	default:
+		s.pending.Done()
+		metrics.AlertWebhookFailures.Add(1)
+		logging.Error("alerts: delivery queue full, dropping", "rule", a.Rule, "subject", a.Subject)
+	}
+}
+
+func (s *sender) run() {
+	for d := range s.queue {
+		s.send(d.ctx, d.alert, d.names)
+		s.pending.Done()
+	}
+}
+
+// wait blocks until every queued alert has been posted
+func (s *sender) wait() {
+	s.pending.Wait()
+}
+
+// send posts a to the named webhooks, or to all of them when names is
+// empty. Failures are logged, the alert is not retried
+func (s *sender) send(ctx context.Context, a Alert, names []string) {
+	msg := fmt.Sprintf("[%s] %s", a.Rule, a.Message)
+	body, err := json.Marshal(payload{Content: msg, Text: msg, Alert: a})
+	if err != nil {
+		logging.Error("alerts: failed to encode alert", err)
+		return
+	}
+	for _, w := range s.webhooks {
+		if len(names) > 0 && !slices.Contains(names, w.Name) {
+			continue
+		}
+		if err := s.post(ctx, w.URL, body); err != nil {
+			metrics.AlertWebhookFailures.Add(1)
+			logging.Error("alerts: webhook failed", "webhook", w.Name, "rule", a.Rule, "error", err)
+		}
+	}
+}
+
+func (s *sender) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Package analytics derives rates and projections from the agents' hourly
+// rollups, so the pages can show who is speeding up rather than only where
+// everyone is
+package analytics
+
+import (
+	"sort"
+	"time"
+
+	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+)
+
+// Window is how far back the recent rates look
+const Window = 6 * time.Hour
+
+// Rate is an agent's gain per hour between the end of one hourly rollup and
+// the end of the next, Timestamp is the end of the later one
+type Rate struct {
+	Timestamp int64
+	Credits   float64
+	Ships     float64
+}
+
+// Metrics is what we derive for one agent
+type Metrics struct {
+	Symbol string
+	// CreditsPerHour is over everything we have for the reset
+	CreditsPerHour float64
+	// RecentCreditsPerHour is the moving average over the last Window
+	RecentCreditsPerHour float64
+	// Acceleration is RecentCreditsPerHour less CreditsPerHour, above 0
+	// when the agent earns faster now than it has on average
+	Acceleration float64
+	// ShipsPerDay is ships added per day over the reset
+	ShipsPerDay float64
+	// ProjectedCredits is the latest credits carried on at
+	// RecentCreditsPerHour until the end of the reset
+	ProjectedCredits int64
+}
+
+// Rates turns one agent's rollups, oldest first, into a rate for every
+// rollup after the first
+func Rates(rollups []ds.AgentRollup) []Rate {
+	res := []Rate{}
+	for i := 1; i < len(rollups); i++ {
+		prev, cur := rollups[i-1], rollups[i]
+		hours := float64(cur.LastTimestamp-prev.LastTimestamp) / 3600
+		if hours <= 0 {
+			continue
+		}
+		res = append(res, Rate{
+			Timestamp: cur.LastTimestamp,
+			Credits:   float64(cur.Credits.Last-prev.Credits.Last) / hours,
+			Ships:     float64(cur.Ships.Last-prev.Ships.Last) / hours,
+		})
+	}
+	return res
+}
+
+// MovingAverage replaces every rate with the mean of the rates in the
+// window ending at it
+func MovingAverage(rates []Rate, window time.Duration) []Rate {
+	res := make([]Rate, 0, len(rates))
+	from := 0
+	var credits, ships float64
+	for i, r := range rates {
+		credits += r.Credits
+		ships += r.Ships
+		for from < i && rates[from].Timestamp <= r.Timestamp-int64(window.Seconds()) {
+			credits -= rates[from].Credits
+			ships -= rates[from].Ships
+			from++
+		}
+		n := float64(i - from + 1)
+		res = append(res, Rate{Timestamp: r.Timestamp, Credits: credits / n, Ships: ships / n})
+	}
+	return res
+}
+
+// ForAgent derives the metrics of one agent from its rollups, oldest first.
+// The projection runs to end, or stays at the latest credits when end is
+// zero or already passed
+func ForAgent(symbol string, rollups []ds.AgentRollup, end time.Time) Metrics {
+	m := Metrics{Symbol: symbol}
+	if len(rollups) == 0 {
+		return m
+	}
+	first, last := rollups[0], rollups[len(rollups)-1]
+	m.ProjectedCredits = last.Credits.Last
+	if len(rollups) < 2 {
+		return m
+	}
+
+	hours := float64(last.LastTimestamp-first.LastTimestamp) / 3600
+	if hours > 0 {
+		m.CreditsPerHour = float64(last.Credits.Last-first.Credits.Last) / hours
+		m.ShipsPerDay = float64(last.Ships.Last-first.Ships.Last) / hours * 24
+	}
+	if avg := MovingAverage(Rates(rollups), Window); len(avg) > 0 {
+		m.RecentCreditsPerHour = avg[len(avg)-1].Credits
+	}
+	m.Acceleration = m.RecentCreditsPerHour - m.CreditsPerHour
+
+	if left := end.Unix() - last.LastTimestamp; !end.IsZero() && left > 0 {
+		m.ProjectedCredits += int64(m.RecentCreditsPerHour * float64(left) / 3600)
+	}
+	return m
+}
+
+// ForAgents is ForAgent for every agent in rollups, keyed by symbol
+func ForAgents(rollups []ds.AgentRollup, end time.Time) map[string]Metrics {
+	bySymbol := make(map[string][]ds.AgentRollup)
+	for _, r := range rollups {
+		bySymbol[r.Symbol] = append(bySymbol[r.Symbol], r)
+	}
+	res := make(map[string]Metrics, len(bySymbol))
+	for symbol, rs := range bySymbol {
+		sort.Slice(rs, func(i, j int) bool {
+			return rs[i].Timestamp < rs[j].Timestamp
+		})
+		res[symbol] = ForAgent(symbol, rs, end)
+	}
+	return res
+}
+
+// Compute loads the hourly rollups of symbols, or of everyone when symbols
+// is nil, and derives their metrics, projecting to the reset's NextReset
+func Compute(s ds.Store, thisReset ds.Reset, symbols []string) (map[string]Metrics, error) {
+	rollups, err := s.GetAgentRollups(thisReset, symbols, ds.TierHour, 0, 0)
+	if err != nil {
+		return map[string]Metrics{}, err
+	}
+	var end time.Time
+	if st, err := s.GetStats(thisReset); err == nil {
+		end = st.NextReset
+	}
+	return ForAgents(rollups, end), nil
+}
@@ -0,0 +1,119 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+)
+
+// hourly builds rollups an hour apart from the credits and ships at the end
+// of every hour
+func hourly(symbol string, start int64, credits []int64, ships []int64) []ds.AgentRollup {
+	res := []ds.AgentRollup{}
+	for i := range credits {
+		res = append(res, ds.AgentRollup{
+			Symbol:        symbol,
+			Timestamp:     start + int64(i)*3600,
+			LastTimestamp: start + int64(i)*3600 + 3300,
+			Credits:       ds.Rollup{Last: credits[i]},
+			Ships:         ds.Rollup{Last: ships[i]},
+		})
+	}
+	return res
+}
+
+func TestMovingAverage(t *testing.T) {
+	rates := []Rate{
+		{Timestamp: 3600, Credits: 100},
+		{Timestamp: 7200, Credits: 200},
+		{Timestamp: 10800, Credits: 600},
+		{Timestamp: 14400, Credits: 0},
+	}
+	got := MovingAverage(rates, 2*time.Hour)
+	want := []float64{100, 150, 400, 300}
+	for i := range want {
+		if got[i].Credits != want[i] || got[i].Timestamp != rates[i].Timestamp {
+			t.Fatalf("point %d: got %+v, want %v", i, got[i], want[i])
+		}
+	}
+	if len(MovingAverage(rates, 0)) != 4 {
+		t.Fatalf("a zero window should keep every point")
+	}
+}
+
+func TestForAgent(t *testing.T) {
+	start := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC).Unix()
+	// steady at 100 an hour for 8 hours then 400 an hour for 4
+	credits := []int64{1000}
+	for i := 0; i < 12; i++ {
+		step := int64(100)
+		if i >= 8 {
+			step = 400
+		}
+		credits = append(credits, credits[len(credits)-1]+step)
+	}
+	ships := make([]int64, len(credits))
+	for i := range ships {
+		ships[i] = 2 + int64(i)/6
+	}
+	rollups := hourly("ALPHA", start, credits, ships)
+	last := rollups[len(rollups)-1]
+	end := time.Unix(last.LastTimestamp+10*3600, 0)
+
+	m := ForAgent("ALPHA", rollups, end)
+	if m.CreditsPerHour != 200 {
+		t.Fatalf("expected 200 credits per hour over the reset, got %v", m.CreditsPerHour)
+	}
+	// the last 6 hours are 2 at 100 and 4 at 400
+	if m.RecentCreditsPerHour != 300 || m.Acceleration != 100 {
+		t.Fatalf("unexpected recent rate: %+v", m)
+	}
+	if math.Abs(m.ShipsPerDay-4) > 1e-9 {
+		t.Fatalf("expected 4 ships a day, got %v", m.ShipsPerDay)
+	}
+	if m.ProjectedCredits != 3400+3000 {
+		t.Fatalf("expected a projection of 6400, got %d", m.ProjectedCredits)
+	}
+
+	if past := ForAgent("ALPHA", rollups, time.Unix(start, 0)); past.ProjectedCredits != 3400 {
+		t.Fatalf("a past end should keep the latest credits, got %d", past.ProjectedCredits)
+	}
+	if one := ForAgent("ALPHA", rollups[:1], end); one.ProjectedCredits != 1000 || one.CreditsPerHour != 0 {
+		t.Fatalf("unexpected metrics from one rollup: %+v", one)
+	}
+}
+
+func TestCompute(t *testing.T) {
+	s := ds.NewMemoryStore()
+	if err := s.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	start := time.Date(2026, 1, 10, 10, 0, 0, 0, time.UTC).Unix()
+	for i := int64(0); i < 3; i++ {
+		agents := []ds.PublicAgent{
+			{Symbol: "ALPHA", Credits: 1000 + i*500, Headquarters: "X1-AB12-A1", ShipCount: 2},
+			{Symbol: "BRAVO", Credits: 2000, Headquarters: "X1-CD34-B2", ShipCount: 3},
+		}
+		if err := s.StoreAgents(agents, start+i*3600); err != nil {
+			t.Fatalf("StoreAgents: %v", err)
+		}
+	}
+
+	all, err := Compute(s, "", nil)
+	if err != nil {
+		t.Fatalf("Compute: %v", err)
+	}
+	if len(all) != 2 || all["ALPHA"].CreditsPerHour != 500 || all["BRAVO"].CreditsPerHour != 0 {
+		t.Fatalf("unexpected metrics: %+v", all)
+	}
+	// without stats there is no NextReset to project to
+	if all["ALPHA"].ProjectedCredits != 2000 {
+		t.Fatalf("expected the latest credits as the projection, got %d", all["ALPHA"].ProjectedCredits)
+	}
+	one, _ := Compute(s, "", []string{"BRAVO"})
+	if _, ok := one["ALPHA"]; ok || len(one) != 1 {
+		t.Fatalf("expected only BRAVO: %+v", one)
+	}
+}
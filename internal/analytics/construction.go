@@ -0,0 +1,178 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+)
+
+// DefaultStallWindow is how long a started jumpgate can go without a
+// delivery before it is flagged as stalled
+const DefaultStallWindow = 12 * time.Hour
+
+// Forecast is how fast a jumpgate is being built and when it should be done
+type Forecast struct {
+	Jumpgate string
+	// Delivered and Required are units over every material, deliveries
+	// past what a material needs are not counted
+	Delivered int64
+	Required  int64
+	// UnitsPerHour is the delivery rate since deliveries were first seen
+	UnitsPerHour float64
+	// LastDelivery is the end of the last rollup that made progress, 0
+	// when nothing was delivered
+	LastDelivery int64
+	// ETA is when the gate is done at UnitsPerHour, 0 when there is no rate
+	ETA int64
+	// Bursty is set when deliveries per hour vary more than their mean.
+	// ETAEarly and ETALate then bound ETA, ETALate is 0 when the slow end
+	// of the band never finishes
+	Bursty   bool
+	ETAEarly int64
+	ETALate  int64
+	// Stalled is set when a started, unfinished gate had no delivery for
+	// the stall window
+	Stalled  bool
+	Complete bool
+}
+
+// Remaining is the units still to deliver
+func (f Forecast) Remaining() int64 {
+	return f.Required - f.Delivered
+}
+
+// Progress is the share delivered, 0 to 1
+func (f Forecast) Progress() float64 {
+	if f.Required == 0 {
+		return 0
+	}
+	return float64(f.Delivered) / float64(f.Required)
+}
+
+// delivered sums what counts towards the recipe at the end of a rollup
+func delivered(r ds.ConstructionRollup) (done, required int64) {
+	for _, m := range r.Materials {
+		done += min(m.Fulfilled.Last, m.Required)
+		required += m.Required
+	}
+	return done, required
+}
+
+// ForecastConstruction forecasts one jumpgate from its rollups, oldest
+// first. now is when stalls are measured to, the end of the reset for
+// older ones
+func ForecastConstruction(jumpgate string, rollups []ds.ConstructionRollup, now time.Time, stallWindow time.Duration) Forecast {
+	f := Forecast{Jumpgate: jumpgate}
+	if len(rollups) == 0 {
+		return f
+	}
+	totals := make([]int64, len(rollups))
+	for i, r := range rollups {
+		totals[i], _ = delivered(r)
+	}
+	last := rollups[len(rollups)-1]
+	f.Delivered, f.Required = delivered(last)
+	f.Complete = f.Required > 0 && f.Delivered >= f.Required
+
+	// deliveries are measured from the end of the rollup before the first
+	// one that made progress
+	from := -1
+	for i := 1; i < len(rollups); i++ {
+		if totals[i] > totals[i-1] {
+			if from < 0 {
+				from = i - 1
+			}
+			f.LastDelivery = rollups[i].LastTimestamp
+		}
+	}
+	if from < 0 && f.Delivered > 0 {
+		// it all happened before our first record
+		f.LastDelivery = rollups[0].LastTimestamp
+	}
+	f.Stalled = f.Delivered > 0 && !f.Complete && now.Unix()-f.LastDelivery >= int64(stallWindow.Seconds())
+	if f.Complete {
+		f.ETA = f.LastDelivery
+		return f
+	}
+	if from < 0 {
+		return f
+	}
+
+	hours := float64(last.LastTimestamp-rollups[from].LastTimestamp) / 3600
+	if hours <= 0 {
+		return f
+	}
+	f.UnitsPerHour = float64(f.Delivered-totals[from]) / hours
+	f.ETA = etaAt(last.LastTimestamp, f.Remaining(), f.UnitsPerHour)
+
+	// the band comes from how much the rollup to rollup rate moves around
+	rates := []float64{}
+	for i := from + 1; i < len(rollups); i++ {
+		if h := float64(rollups[i].LastTimestamp-rollups[i-1].LastTimestamp) / 3600; h > 0 {
+			rates = append(rates, float64(totals[i]-totals[i-1])/h)
+		}
+	}
+	if len(rates) < 2 {
+		return f
+	}
+	mean, spread := meanStdDev(rates)
+	if spread <= mean {
+		return f
+	}
+	f.Bursty = true
+	margin := 2 * spread / math.Sqrt(float64(len(rates)))
+	f.ETAEarly = etaAt(last.LastTimestamp, f.Remaining(), f.UnitsPerHour+margin)
+	f.ETALate = etaAt(last.LastTimestamp, f.Remaining(), f.UnitsPerHour-margin)
+	return f
+}
+
+// etaAt is when remaining units are done at rate starting from ts, 0 when
+// they never are
+func etaAt(ts, remaining int64, rate float64) int64 {
+	if rate <= 0 {
+		return 0
+	}
+	return ts + int64(float64(remaining)/rate*3600)
+}
+
+func meanStdDev(xs []float64) (float64, float64) {
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	mean := sum / float64(len(xs))
+	var sq float64
+	for _, x := range xs {
+		sq += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(xs)-1))
+}
+
+// ForecastConstructions is ForecastConstruction for every jumpgate in
+// rollups, keyed by jumpgate
+func ForecastConstructions(rollups []ds.ConstructionRollup, now time.Time, stallWindow time.Duration) map[string]Forecast {
+	byGate := make(map[string][]ds.ConstructionRollup)
+	for _, r := range rollups {
+		byGate[r.Jumpgate] = append(byGate[r.Jumpgate], r)
+	}
+	res := make(map[string]Forecast, len(byGate))
+	for gate, rs := range byGate {
+		sort.Slice(rs, func(i, j int) bool {
+			return rs[i].Timestamp < rs[j].Timestamp
+		})
+		res[gate] = ForecastConstruction(gate, rs, now, stallWindow)
+	}
+	return res
+}
+
+// ComputeForecasts loads the hourly construction rollups of jumpgates, or
+// of every gate when jumpgates is nil, and forecasts them
+func ComputeForecasts(s ds.Store, thisReset ds.Reset, jumpgates []string, now time.Time, stallWindow time.Duration) (map[string]Forecast, error) {
+	rollups, err := s.GetConstructionRollups(thisReset, jumpgates, ds.TierHour, 0, 0)
+	if err != nil {
+		return map[string]Forecast{}, err
+	}
+	return ForecastConstructions(rollups, now, stallWindow), nil
+}
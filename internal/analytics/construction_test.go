@@ -0,0 +1,81 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+)
+
+// gateHours builds hourly rollups of a jumpgate needing 1000 of one
+// material, delivered is the running total at the end of each hour
+func gateHours(start int64, delivered []int64) []ds.ConstructionRollup {
+	res := []ds.ConstructionRollup{}
+	for i, d := range delivered {
+		res = append(res, ds.ConstructionRollup{
+			Jumpgate:      "X1-AB12-J1",
+			Timestamp:     start + int64(i)*3600,
+			LastTimestamp: start + int64(i+1)*3600,
+			Materials: []ds.MaterialRollup{
+				{Symbol: "FAB_MATS", Required: 1000, Fulfilled: ds.Rollup{Last: d}},
+			},
+		})
+	}
+	return res
+}
+
+func TestForecastConstruction(t *testing.T) {
+	start := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC).Unix()
+
+	t.Run("steady", func(t *testing.T) {
+		rollups := gateHours(start, []int64{0, 0, 100, 200, 300, 400})
+		now := time.Unix(start+6*3600, 0)
+		f := ForecastConstruction("X1-AB12-J1", rollups, now, DefaultStallWindow)
+		if f.UnitsPerHour != 100 || f.Remaining() != 600 || f.Progress() != 0.4 {
+			t.Fatalf("unexpected rate: %+v", f)
+		}
+		if f.ETA != start+12*3600 || f.Bursty || f.Stalled || f.Complete {
+			t.Fatalf("unexpected forecast: %+v", f)
+		}
+		if f.LastDelivery != start+6*3600 {
+			t.Fatalf("unexpected last delivery: %d", f.LastDelivery)
+		}
+	})
+
+	t.Run("bursty", func(t *testing.T) {
+		rollups := gateHours(start, []int64{0, 0, 300, 300, 300, 400})
+		f := ForecastConstruction("X1-AB12-J1", rollups, time.Unix(start+6*3600, 0), DefaultStallWindow)
+		if !f.Bursty || f.ETA != start+12*3600 {
+			t.Fatalf("expected a bursty forecast: %+v", f)
+		}
+		if f.ETAEarly == 0 || f.ETAEarly >= f.ETA || (f.ETALate != 0 && f.ETALate <= f.ETA) {
+			t.Fatalf("ETA is outside its band: %+v", f)
+		}
+	})
+
+	t.Run("stalled", func(t *testing.T) {
+		rollups := gateHours(start, []int64{0, 200, 200, 200})
+		f := ForecastConstruction("X1-AB12-J1", rollups, time.Unix(start+4*3600, 0), time.Hour)
+		if !f.Stalled || f.LastDelivery != start+2*3600 {
+			t.Fatalf("expected a stall: %+v", f)
+		}
+		if f := ForecastConstruction("X1-AB12-J1", rollups, time.Unix(start+4*3600, 0), DefaultStallWindow); f.Stalled {
+			t.Fatalf("stalled inside the window: %+v", f)
+		}
+	})
+
+	t.Run("complete", func(t *testing.T) {
+		rollups := gateHours(start, []int64{500, 1200, 1200})
+		f := ForecastConstruction("X1-AB12-J1", rollups, time.Unix(start+30*3600, 0), time.Hour)
+		if !f.Complete || f.Stalled || f.Delivered != 1000 || f.ETA != start+2*3600 {
+			t.Fatalf("unexpected forecast for a finished gate: %+v", f)
+		}
+	})
+
+	t.Run("not started", func(t *testing.T) {
+		f := ForecastConstruction("X1-AB12-J1", gateHours(start, []int64{0, 0}), time.Unix(start+30*3600, 0), time.Hour)
+		if f.ETA != 0 || f.Stalled || f.LastDelivery != 0 {
+			t.Fatalf("unexpected forecast for an idle gate: %+v", f)
+		}
+	})
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/papaburgs/fluffy-robot/internal/datastore"
 	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+	"github.com/papaburgs/fluffy-robot/internal/events"
 	"github.com/papaburgs/fluffy-robot/internal/logging"
 	"github.com/papaburgs/fluffy-robot/internal/metrics"
 )
@@ -15,7 +16,7 @@ import (
 func (c *Collector) updateStatus(ctx context.Context) error {
 	var err error
 	// logging.Debug("updating server status")
-	c.currentTimestamp = time.Now().Truncate(time.Minute).Unix()
+	c.currentTimestamp = c.now().Truncate(time.Minute).Unix()
 	c.apiCalls = 0
 	c.ingestStart = time.Now()
 
@@ -30,16 +31,25 @@ func (c *Collector) updateStatus(ctx context.Context) error {
 	}
 	// logging.Debug("api call done")
 
+	previous := c.currentReset
 	c.currentReset = ds.Reset(status.ResetDate)
-	datastore.UpdateReset(c.currentReset)
+	if err := c.store.UpdateReset(c.currentReset); err != nil {
+		return err
+	}
+	if previous != "" && previous != c.currentReset {
+		c.events.Publish(events.ResetDetected, string(c.currentReset))
+	}
 	c.nextReset = status.ServerResets.Next
+	if !c.nextReset.IsZero() {
+		metrics.CollectorNextReset.Set(c.nextReset.Unix())
+	}
 
 	// logging.Debug("processing response")
-	err = datastore.StoreStats(status)
+	err = c.store.StoreStats(status, c.currentTimestamp)
 	if err != nil {
 		logging.Error("Error saving stats", err)
 	}
-	err = datastore.StoreLeaderboards(status)
+	err = c.store.StoreLeaderboards(status, c.currentTimestamp)
 	if err != nil {
 		logging.Error("Error saving leaderboards", err)
 	}
@@ -79,7 +89,9 @@ func (c *Collector) updateAgents(ctx context.Context) error {
 		return nil
 	}
 
-	datastore.StoreAgents(allAgents, c.currentTimestamp)
+	if err := c.store.StoreAgents(allAgents, c.currentTimestamp); err != nil {
+		return err
+	}
 
 	err := c.updateJumpgatesFromAgents(ctx, allAgents)
 	if err != nil {
@@ -88,6 +100,8 @@ func (c *Collector) updateAgents(ctx context.Context) error {
 
 	metrics.CollectorAgentUpdates.Add(1)
 	metrics.CollectorLastTimestamp.Set(time.Now().Unix())
+	c.events.Publish(events.AgentsUpdated, string(c.currentReset))
+	c.alerts.Check(ctx, c.currentReset)
 	logging.Info("agent ingestion completed", "apiCalls", c.apiCalls, "duration", time.Now().Sub(c.ingestStart))
 	allAgents = nil
 	return nil
@@ -121,7 +135,9 @@ func (c *Collector) updateFactionss(ctx context.Context) error {
 		page++
 	}
 
-	datastore.StoreFactions(allFactions)
+	if err := c.store.StoreFactions(allFactions); err != nil {
+		return err
+	}
 
 	logging.Info("faction", "apiCalls", c.apiCalls, "duration", time.Now().Sub(c.ingestStart))
 	allFactions = nil
@@ -5,17 +5,39 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/papaburgs/fluffy-robot/internal/datastore"
 	"github.com/papaburgs/fluffy-robot/internal/metrics"
 )
 
+// apiRoute is url's path below the base URL with system and waypoint
+// symbols replaced, to keep the latency labels few
+func (c *Collector) apiRoute(url string) string {
+	path, _, _ := strings.Cut(strings.TrimPrefix(url, c.baseURL), "?")
+	parts := strings.Split(path, "/")
+	for i := 1; i < len(parts); i++ {
+		switch parts[i-1] {
+		case "systems":
+			parts[i] = "{system}"
+		case "waypoints":
+			parts[i] = "{waypoint}"
+		}
+	}
+	if path = strings.Join(parts, "/"); path == "" {
+		path = "/"
+	}
+	return path
+}
+
 func (c *Collector) doGET(ctx context.Context, url string) (HTTPResponse, error) {
 	var retries429 int
 	var retriesOther int
 	c.apiCalls++
 	metrics.CollectorAPICalls.Add(1)
+	route := c.apiRoute(url)
 	for {
 		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 		if err != nil {
@@ -25,18 +47,26 @@ func (c *Collector) doGET(ctx context.Context, url string) (HTTPResponse, error)
 		c.gate.Latch(ctx)
 
 		client := &http.Client{Timeout: 10 * time.Second}
+		start := time.Now()
 		resp, err := client.Do(req)
 		if err != nil {
+			metrics.APIRequestDuration.WithLabelValues(route, "error").Observe(time.Since(start).Seconds())
+			if ctx.Err() != nil {
+				return HTTPResponse{}, ctx.Err()
+			}
 			retriesOther++
 			if retriesOther >= 3 {
 				return HTTPResponse{}, err
 			}
-			time.Sleep(time.Second)
+			if !sleep(ctx, time.Second) {
+				return HTTPResponse{}, ctx.Err()
+			}
 			continue
 		}
 
 		body, err := io.ReadAll(resp.Body)
 		resp.Body.Close()
+		metrics.APIRequestDuration.WithLabelValues(route, strconv.Itoa(resp.StatusCode)).Observe(time.Since(start).Seconds())
 		if err != nil {
 			return HTTPResponse{}, err
 		}
@@ -57,7 +87,9 @@ func (c *Collector) doGET(ctx context.Context, url string) (HTTPResponse, error)
 				return res, fmt.Errorf("received too many 429 errors")
 			}
 			c.gate.Lock(ctx)
-			time.Sleep(time.Second)
+			if !sleep(ctx, time.Second) {
+				return res, ctx.Err()
+			}
 			continue
 		}
 
@@ -66,7 +98,9 @@ func (c *Collector) doGET(ctx context.Context, url string) (HTTPResponse, error)
 		if retriesOther >= 3 {
 			return res, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
 		}
-		time.Sleep(time.Second)
+		if !sleep(ctx, time.Second) {
+			return res, ctx.Err()
+		}
 	}
 }
 
@@ -98,6 +132,15 @@ type ConstructionStatus struct {
 	IsComplete bool                   `json:"isComplete"`
 }
 
+// materials copies the whole recipe, in the order the API lists it
+func (s ConstructionStatus) materials() datastore.Materials {
+	res := make(datastore.Materials, 0, len(s.Materials))
+	for _, m := range s.Materials {
+		res = append(res, datastore.Material{Symbol: m.TradeSymbol, Required: m.Required, Fulfilled: m.Fulfilled})
+	}
+	return res
+}
+
 type HTTPResponse struct {
 	Bytes      []byte
 	StatusCode int
@@ -3,13 +3,18 @@ package collector
 import (
 	"context"
 	"encoding/json"
+	"expvar"
 	"io"
 	"net/http"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/papaburgs/fluffy-robot/internal/alerts"
+	"github.com/papaburgs/fluffy-robot/internal/config"
 	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+	"github.com/papaburgs/fluffy-robot/internal/events"
 	"github.com/papaburgs/fluffy-robot/internal/gate"
 	"github.com/papaburgs/fluffy-robot/internal/logging"
 	"github.com/papaburgs/fluffy-robot/internal/metrics"
@@ -17,38 +22,98 @@ import (
 
 type Collector struct {
 	baseURL          string
+	intervals        config.Collector
 	gate             *gate.Gate
+	store            ds.Store
 	currentReset     ds.Reset
 	nextReset        time.Time
 	currentTimestamp int64
-	apiCalls         int
-	ingestStart      time.Time
-	filterRegexes    []*regexp.Regexp
-	agentTicker      *time.Ticker
-	constTicker      *time.Ticker
-	jumpgateTicker   *time.Ticker
+	// now is the clock ticks are stamped with
+	now            func() time.Time
+	apiCalls       int
+	ingestStart    time.Time
+	filterRegexes  []*regexp.Regexp
+	agentTicker    *time.Ticker
+	constTicker    *time.Ticker
+	jumpgateTicker *time.Ticker
+	// events is told about every write, nil when nobody listens
+	events *events.Bus
+	// alerts checks its rules after every write, nil when there are none
+	alerts *alerts.Engine
+	// ready is closed once the first status check is done
+	ready     chan struct{}
+	readyOnce sync.Once
 }
 
-func NewCollector(gate *gate.Gate, baseURL string) *Collector {
+// NewCollector polls cfg.BaseURL through gate at cfg's intervals, any
+// interval left at 0 uses the default
+func NewCollector(gate *gate.Gate, cfg config.Collector, store ds.Store) *Collector {
+	def := config.Default().Collector
+	if cfg.AgentInterval <= 0 {
+		cfg.AgentInterval = def.AgentInterval
+	}
+	if cfg.JumpgateInterval <= 0 {
+		cfg.JumpgateInterval = def.JumpgateInterval
+	}
+	if cfg.ConstructionInterval <= 0 {
+		cfg.ConstructionInterval = def.ConstructionInterval
+	}
 	c := Collector{
-		gate:    gate,
-		baseURL: baseURL,
+		gate:      gate,
+		baseURL:   cfg.BaseURL,
+		intervals: cfg,
+		store:     store,
+		now:       time.Now,
+		ready:     make(chan struct{}),
 	}
 	return &c
 }
 
+// PublishTo makes the collector announce new data on bus
+func (c *Collector) PublishTo(bus *events.Bus) {
+	c.events = bus
+}
+
+// AlertWith has e check its rules after every tick that stored something
+func (c *Collector) AlertWith(e *alerts.Engine) {
+	c.alerts = e
+}
+
+// Ready is closed once Run has asked the server for the current reset,
+// whether or not it answered, or Run has returned
+func (c *Collector) Ready() <-chan struct{} {
+	return c.ready
+}
+
+func (c *Collector) markReady() {
+	c.readyOnce.Do(func() {
+		close(c.ready)
+	})
+}
+
+// Run collects until ctx is cancelled. A tick in progress is abandoned at
+// its next API call, nothing is stored for a half fetched update
 func (c *Collector) Run(ctx context.Context) {
 	var err error
+	defer c.markReady()
 
 	err = c.updateStatus(ctx)
 	if err != nil {
 		logging.Error("Error running updateStatus", err)
 	}
+	c.markReady()
 
-	c.agentTicker = time.NewTicker(5 * time.Minute)
-	c.jumpgateTicker = time.NewTicker(30 * time.Minute)
-	c.constTicker = time.NewTicker(4 * 60 * time.Minute)
+	c.agentTicker = time.NewTicker(c.intervals.AgentInterval)
+	c.jumpgateTicker = time.NewTicker(c.intervals.JumpgateInterval)
+	c.constTicker = time.NewTicker(c.intervals.ConstructionInterval)
 	resetTimer := time.NewTimer(7 * 24 * time.Hour)
+	defer func() {
+		c.agentTicker.Stop()
+		c.jumpgateTicker.Stop()
+		c.constTicker.Stop()
+		resetTimer.Stop()
+		logging.Info("collector stopped")
+	}()
 
 	for {
 		select {
@@ -64,6 +129,7 @@ func (c *Collector) Run(ctx context.Context) {
 			if err != nil {
 				logging.Error("Error running updateStatus", err)
 			}
+			recordIngestion(ctx, metrics.CollectorAgentLastSuccess, metrics.CollectorAgentLastFailure, err)
 			timeUntilReset := time.Until(c.nextReset.Add(-3 * time.Minute))
 			if timeUntilReset > 0 {
 				resetTimer.Reset(timeUntilReset)
@@ -73,11 +139,13 @@ func (c *Collector) Run(ctx context.Context) {
 			if err != nil {
 				logging.Error("Error running updateJumpgates")
 			}
+			recordIngestion(ctx, metrics.CollectorJumpgateLastSuccess, metrics.CollectorJumpgateLastFailure, err)
 		case <-c.constTicker.C:
 			err = c.updateInactiveJumpgates(ctx)
 			if err != nil {
 				logging.Error("Error running updateJumpgates")
 			}
+			recordIngestion(ctx, metrics.CollectorJumpgateLastSuccess, metrics.CollectorJumpgateLastFailure, err)
 		case <-resetTimer.C:
 			logging.Info("reset timer emit, stopping tickers doing one last check and then looping until reset is complete")
 			c.agentTicker.Stop()
@@ -90,22 +158,53 @@ func (c *Collector) Run(ctx context.Context) {
 			}
 
 			logging.Info("checking for reset, this may take a while...")
-			time.Sleep(3 * time.Minute)
+			if !sleep(ctx, 3*time.Minute) {
+				return
+			}
 			if err := c.loopAtReset(ctx); err != nil {
 				logging.Error("Error in loopAtReset", err)
 			}
-			c.agentTicker = time.NewTicker(5 * time.Minute)
-			c.jumpgateTicker = time.NewTicker(30 * time.Minute)
-			c.constTicker = time.NewTicker(4 * 60 * time.Minute)
+			if ctx.Err() != nil {
+				return
+			}
+			c.agentTicker = time.NewTicker(c.intervals.AgentInterval)
+			c.jumpgateTicker = time.NewTicker(c.intervals.JumpgateInterval)
+			c.constTicker = time.NewTicker(c.intervals.ConstructionInterval)
 			logging.Info("restarted tickers")
 		}
 	}
 }
 
+// recordIngestion stamps success or failure with now for the health
+// checks, an update cut short by shutdown is neither
+func recordIngestion(ctx context.Context, success, failure *expvar.Int, err error) {
+	switch {
+	case ctx.Err() != nil:
+	case err != nil:
+		failure.Set(time.Now().Unix())
+	default:
+		success.Set(time.Now().Unix())
+	}
+}
+
+// sleep waits for d, it is false if ctx was cancelled first
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
 func (c *Collector) loopAtReset(ctx context.Context) error {
 	for {
 		logging.Info("Sleeping")
-		time.Sleep(time.Minute)
+		if !sleep(ctx, time.Minute) {
+			return ctx.Err()
+		}
 		logging.Info("Checking")
 		req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/", nil)
 		if err != nil {
@@ -150,14 +249,14 @@ func (c *Collector) loopAtReset(ctx context.Context) error {
 
 		if len(status.Leaderboards.MostSubmittedCharts) == 0 {
 			logging.Info("Credit leaderboard is empty, probably ready to go, sleep 1 more minute")
-			time.Sleep(time.Minute)
+			sleep(ctx, time.Minute)
 			return nil
 		}
 
 		if status.Leaderboards.MostCredits != nil && len(status.Leaderboards.MostCredits) > 0 {
 			if status.Leaderboards.MostCredits[0].Credits < 500000 {
 				logging.Info("Credit leaderboard not empty but low, probably ready to go, sleep 1 more minute")
-				time.Sleep(time.Minute)
+				sleep(ctx, time.Minute)
 				return nil
 			}
 		}
@@ -0,0 +1,350 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/papaburgs/fluffy-robot/internal/alerts"
+	"github.com/papaburgs/fluffy-robot/internal/config"
+	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+	"github.com/papaburgs/fluffy-robot/internal/events"
+	"github.com/papaburgs/fluffy-robot/internal/gate"
+)
+
+// fakeAPI stands in for the SpaceTraders endpoints the collector calls
+func fakeAPI(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{
+			"status": "ok",
+			"version": "v2.3.0",
+			"resetDate": "2026-01-04",
+			"serverResets": {"next": "2026-01-11T00:00:00Z", "frequency": "weekly"},
+			"stats": {"accounts": 10, "agents": 2, "ships": 5, "systems": 100, "waypoints": 1000},
+			"leaderboards": {
+				"mostCredits": [{"agentSymbol": "BRAVO", "credits": 250000}],
+				"mostSubmittedCharts": [{"agentSymbol": "ALPHA", "chartCount": 4}]
+			}
+		}`))
+	})
+	mux.HandleFunc("/agents", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"data": [
+				{"symbol": "ALPHA", "credits": 175000, "headquarters": "X1-AB12-A1", "shipCount": 2, "startingFaction": "COSMIC"},
+				{"symbol": "BRAVO", "credits": 250000, "headquarters": "X1-CD34-B2", "shipCount": 3, "startingFaction": "VOID"}
+			],
+			"meta": {"total": 2, "page": 1, "limit": 20}
+		}`))
+	})
+	// the first construction check finds a gate part built, later ones
+	// find it done
+	var constructionCalls atomic.Int32
+	mux.HandleFunc("/systems/", func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/construction") {
+			if constructionCalls.Add(1) == 1 {
+				w.Write([]byte(`{"data": {"symbol": "X1-CD34-I1", "isComplete": false, "materials": [
+					{"tradeSymbol": "FAB_MATS", "required": 1600, "fulfilled": 200},
+					{"tradeSymbol": "ADVANCED_CIRCUITRY", "required": 400, "fulfilled": 0},
+					{"tradeSymbol": "QUANTUM_STABILIZERS", "required": 10, "fulfilled": 1}
+				]}}`))
+				return
+			}
+			w.Write([]byte(`{"data": {"symbol": "X1-CD34-I1", "isComplete": true, "materials": [
+				{"tradeSymbol": "FAB_MATS", "required": 1600, "fulfilled": 1600},
+				{"tradeSymbol": "ADVANCED_CIRCUITRY", "required": 400, "fulfilled": 400},
+				{"tradeSymbol": "QUANTUM_STABILIZERS", "required": 10, "fulfilled": 10}
+			]}}`))
+			return
+		}
+		system := r.URL.Path[len("/systems/"):]
+		w.Write([]byte(`{"data": {"waypoints": [
+			{"symbol": "` + system + `-A1", "type": "PLANET"},
+			{"symbol": "` + system + `-I1", "type": "JUMP_GATE"}
+		]}}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCollector_StatusAndAgents(t *testing.T) {
+	api := fakeAPI(t)
+	store := ds.NewMemoryStore()
+	c := NewCollector(gate.New(20, 20), config.Collector{BaseURL: api.URL}, store)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := c.updateStatus(ctx); err != nil {
+		t.Fatalf("updateStatus: %v", err)
+	}
+	if store.CurrentReset() != "2026-01-04" {
+		t.Fatalf("expected reset 2026-01-04, got %q", store.CurrentReset())
+	}
+	credits, charts, err := store.GetLeaderboard(store.CurrentReset())
+	if err != nil {
+		t.Fatalf("GetLeaderboard: %v", err)
+	}
+	if len(credits) != 1 || len(charts) != 1 || credits[0].Symbol != "BRAVO" {
+		t.Fatalf("unexpected leaderboards: %+v %+v", credits, charts)
+	}
+
+	if err := c.updateAgents(ctx); err != nil {
+		t.Fatalf("updateAgents: %v", err)
+	}
+	agents, err := store.GetAgentList(store.CurrentReset())
+	if err != nil {
+		t.Fatalf("GetAgentList: %v", err)
+	}
+	if len(agents) != 2 {
+		t.Fatalf("expected 2 agents, got %d", len(agents))
+	}
+
+	jgs := ds.GetJumpgates(store, store.CurrentReset())
+	if len(jgs) != 2 {
+		t.Fatalf("expected 2 jumpgates, got %d", len(jgs))
+	}
+	if jg := jgs["X1-CD34"]; jg.Jumpgate != "X1-CD34-I1" || jg.Status != ds.Active {
+		t.Fatalf("expected BRAVO's gate to be active, got %+v", jg)
+	}
+	if jg := jgs["X1-AB12"]; jg.Status != ds.NoActivity {
+		t.Fatalf("expected ALPHA's gate to have no activity, got %+v", jg)
+	}
+}
+
+func TestCollector_Constructions(t *testing.T) {
+	api := fakeAPI(t)
+	store := ds.NewMemoryStore()
+	c := NewCollector(gate.New(20, 20), config.Collector{BaseURL: api.URL}, store)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := c.updateStatus(ctx); err != nil {
+		t.Fatalf("updateStatus: %v", err)
+	}
+	if err := c.updateAgents(ctx); err != nil {
+		t.Fatalf("updateAgents: %v", err)
+	}
+
+	// jumpgate records are stamped to the nearest minute, which can be ahead
+	// of now and so missed by reads up to now
+	c.now = func() time.Time { return time.Now().Add(-time.Minute).Truncate(time.Minute) }
+
+	// BRAVO's gate has deliveries so it is started, with the whole recipe
+	if err := c.updateInactiveJumpgates(ctx); err != nil {
+		t.Fatalf("updateInactiveJumpgates: %v", err)
+	}
+	if jg := ds.GetJumpgates(store, store.CurrentReset())["X1-CD34"]; jg.Status != ds.Const {
+		t.Fatalf("expected BRAVO's gate under construction, got %+v", jg)
+	}
+	cons, _ := store.GetConstructionsFor(store.CurrentReset(), []string{"X1-CD34-I1"}, 0, 0)
+	if len(cons) != 1 || len(cons[0].Materials) != 3 {
+		t.Fatalf("expected one record with 3 materials, got %+v", cons)
+	}
+	if q := cons[0].Materials.Get("QUANTUM_STABILIZERS"); q.Required != 10 || q.Fulfilled != 1 {
+		t.Fatalf("unexpected material %+v", q)
+	}
+
+	// finished on the next check, then kept topped up with the same recipe
+	for i := 0; i < 2; i++ {
+		if err := c.updateJumpgates(ctx); err != nil {
+			t.Fatalf("updateJumpgates: %v", err)
+		}
+	}
+	if jg := ds.GetJumpgates(store, store.CurrentReset())["X1-CD34"]; jg.Status != ds.Complete {
+		t.Fatalf("expected BRAVO's gate complete, got %+v", jg)
+	}
+	cons, _ = store.GetConstructionsFor(store.CurrentReset(), []string{"X1-CD34-I1"}, 0, 0)
+	if len(cons) != 3 {
+		t.Fatalf("expected 3 records, got %+v", cons)
+	}
+	last := cons[len(cons)-1].Materials
+	if len(last) != 3 || last.Get("QUANTUM_STABILIZERS").Fulfilled != 10 || last.Get("FAB_MATS").Fulfilled != 1600 {
+		t.Fatalf("unexpected synthetic record %+v", last)
+	}
+}
+
+func TestCollector_PublishesEvents(t *testing.T) {
+	api := fakeAPI(t)
+	store := ds.NewMemoryStore()
+	c := NewCollector(gate.New(20, 20), config.Collector{BaseURL: api.URL}, store)
+	bus := events.NewBus()
+	c.PublishTo(bus)
+	ch, stop := bus.Subscribe()
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	expect := func(want events.Type) {
+		t.Helper()
+		select {
+		case e := <-ch:
+			if e.Type != want || e.Reset != "2026-01-04" {
+				t.Fatalf("expected %s, got %+v", want, e)
+			}
+		default:
+			t.Fatalf("expected %s, got nothing", want)
+		}
+	}
+
+	// the first status is where we start, not a reset
+	if err := c.updateStatus(ctx); err != nil {
+		t.Fatalf("updateStatus: %v", err)
+	}
+	if len(ch) != 0 {
+		t.Fatalf("unexpected event %+v", <-ch)
+	}
+	if err := c.updateAgents(ctx); err != nil {
+		t.Fatalf("updateAgents: %v", err)
+	}
+	expect(events.AgentsUpdated)
+	if err := c.updateInactiveJumpgates(ctx); err != nil {
+		t.Fatalf("updateInactiveJumpgates: %v", err)
+	}
+	expect(events.ConstructionUpdated)
+
+	c.currentReset = "2025-12-28"
+	if err := c.updateStatus(ctx); err != nil {
+		t.Fatalf("updateStatus: %v", err)
+	}
+	expect(events.ResetDetected)
+}
+
+func TestCollector_ChecksAlerts(t *testing.T) {
+	api := fakeAPI(t)
+	var posts atomic.Int32
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts.Add(1)
+	}))
+	t.Cleanup(hook.Close)
+	rules, err := alerts.Parse([]byte(`
+webhooks: [{name: hook, url: "` + hook.URL + `"}]
+rules: [{name: reset, type: reset}]
+`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	store := ds.NewMemoryStore()
+	c := NewCollector(gate.New(20, 20), config.Collector{BaseURL: api.URL}, store)
+	c.AlertWith(alerts.NewEngine(store, rules))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := c.updateStatus(ctx); err != nil {
+		t.Fatalf("updateStatus: %v", err)
+	}
+	if err := c.updateAgents(ctx); err != nil {
+		t.Fatalf("updateAgents: %v", err)
+	}
+	if posts.Load() != 0 {
+		t.Fatalf("the first check alerted")
+	}
+	c.currentReset = "2026-01-11"
+	if err := c.updateInactiveJumpgates(ctx); err != nil {
+		t.Fatalf("updateInactiveJumpgates: %v", err)
+	}
+	// alerts are posted in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for posts.Load() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the reset to be posted, got %d posts", posts.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCollector_RunStops(t *testing.T) {
+	api := fakeAPI(t)
+	store := ds.NewMemoryStore()
+	c := NewCollector(gate.New(20, 20), config.Collector{BaseURL: api.URL}, store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(stopped)
+	}()
+
+	select {
+	case <-c.Ready():
+	case <-time.After(5 * time.Second):
+		t.Fatal("collector never became ready")
+	}
+	if store.CurrentReset() == "" {
+		t.Fatal("expected the reset to be known once ready")
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+func TestRecordIngestion(t *testing.T) {
+	var success, failure expvar.Int
+	ctx, cancel := context.WithCancel(context.Background())
+	recordIngestion(ctx, &success, &failure, nil)
+	if success.Value() == 0 || failure.Value() != 0 {
+		t.Fatalf("expected a success, got %d %d", success.Value(), failure.Value())
+	}
+	recordIngestion(ctx, &success, &failure, errors.New("boom"))
+	if failure.Value() == 0 {
+		t.Fatal("expected a failure")
+	}
+	// an update cut short by shutdown is not recorded
+	success.Set(0)
+	failure.Set(0)
+	cancel()
+	recordIngestion(ctx, &success, &failure, context.Canceled)
+	if success.Value() != 0 || failure.Value() != 0 {
+		t.Fatalf("expected nothing recorded, got %d %d", success.Value(), failure.Value())
+	}
+}
+
+func TestCollector_APIRoute(t *testing.T) {
+	c := &Collector{baseURL: "https://api.example"}
+	for url, want := range map[string]string{
+		"https://api.example/":                                                   "/",
+		"https://api.example/agents?limit=20&page=3":                             "/agents",
+		"https://api.example/systems/X1-AB12/waypoints/X1-AB12-I55/construction": "/systems/{system}/waypoints/{waypoint}/construction",
+	} {
+		if got := c.apiRoute(url); got != want {
+			t.Errorf("apiRoute(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestCollector_DoGETStopsRetrying(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(api.Close)
+	c := NewCollector(gate.New(20, 20), config.Collector{BaseURL: api.URL}, ds.NewMemoryStore())
+
+	start := time.Now()
+	_, err := c.doGET(ctx, api.URL+"/")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancel, got %v", err)
+	}
+	// the retry wait is a second, cancelling must cut it short
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("doGET took %v after cancel", d)
+	}
+}
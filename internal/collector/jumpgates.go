@@ -7,6 +7,7 @@ import (
 	"time"
 
 	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+	"github.com/papaburgs/fluffy-robot/internal/events"
 	"github.com/papaburgs/fluffy-robot/internal/logging"
 	"github.com/papaburgs/fluffy-robot/internal/metrics"
 )
@@ -15,60 +16,79 @@ func (c *Collector) updateJumpgatesFromAgents(ctx context.Context, agents []ds.P
 	logging.Info("start")
 
 	// logging.Debug("starting to merge agents with existing jumpgates")
-	c.currentTimestamp = time.Now().Round(time.Minute).Unix()
+	c.currentTimestamp = c.now().Round(time.Minute).Unix()
 	c.apiCalls = 0
 	c.ingestStart = time.Now()
 
-	jgs := ds.GetJumpgates(c.currentReset)
-
-	// logging.Debug("starting loop")
+	// look up the gates of new systems first, the API is too slow to call
+	// while the list is being changed
+	jgs := ds.GetJumpgates(c.store, c.currentReset)
+	found := make(map[string]ds.JGInfo)
 	for _, a := range agents {
-		// logging.Debug("looking at agent", a.Symbol)
 		thisSystem := ds.SystemFromWaypoint(a.Headquarters)
-		thisJG, ok := jgs[thisSystem]
-		if !ok {
-			// logging.Debug("Not found in current jumpgates")
-			jumpgateSymbol, err := c.findJumpgateSymbol(ctx, thisSystem)
-			if err != nil {
-				logging.Error("failed to find jumpgate symbol", thisSystem, err)
-				continue
-			}
-			thisJG = ds.JGInfo{
-				System:       thisSystem,
-				Headquarters: a.Headquarters,
-				Jumpgate:     jumpgateSymbol,
-				Status:       ds.NoActivity,
-			}
+		if _, ok := jgs[thisSystem]; ok {
+			continue
 		}
-		if a.Credits != 175000 && thisJG.Status == ds.NoActivity {
-			// logging.Debug("Marking agent as active")
-			thisJG.Status = ds.Active
+		if _, ok := found[thisSystem]; ok {
+			continue
+		}
+		// logging.Debug("Not found in current jumpgates")
+		jumpgateSymbol, err := c.findJumpgateSymbol(ctx, thisSystem)
+		if err != nil {
+			logging.Error("failed to find jumpgate symbol", thisSystem, err)
+			continue
+		}
+		found[thisSystem] = ds.JGInfo{
+			System:       thisSystem,
+			Headquarters: a.Headquarters,
+			Jumpgate:     jumpgateSymbol,
+			Status:       ds.NoActivity,
 		}
-		jgs[thisSystem] = thisJG
 	}
 
-	// logging.Debug("done scan")
-
-	jgList := []ds.JGInfo{}
-	for _, j := range jgs {
-		jgList = append(jgList, j)
+	err := c.store.ChangeJumpGates(func(current []ds.JGInfo) []ds.JGInfo {
+		jgs := make(map[string]ds.JGInfo, len(current))
+		for _, j := range current {
+			jgs[j.System] = j
+		}
+		for _, a := range agents {
+			thisSystem := ds.SystemFromWaypoint(a.Headquarters)
+			thisJG, ok := jgs[thisSystem]
+			if !ok {
+				if thisJG, ok = found[thisSystem]; !ok {
+					continue
+				}
+			}
+			if a.Credits != 175000 && thisJG.Status == ds.NoActivity {
+				// logging.Debug("Marking agent as active")
+				thisJG.Status = ds.Active
+			}
+			jgs[thisSystem] = thisJG
+		}
+		jgList := make([]ds.JGInfo, 0, len(jgs))
+		for _, j := range jgs {
+			jgList = append(jgList, j)
+		}
+		return jgList
+	})
+	if err != nil {
+		return err
 	}
-	ds.UpdateJumpGates(jgList)
 
 	logging.Info("Update complete construction complete", "apiCalls", c.apiCalls, "duration", time.Now().Sub(c.ingestStart))
-	jgList = nil
 	jgs = nil
+	found = nil
 	return nil
 }
 
 func (c *Collector) updateJumpgates(ctx context.Context) error {
 	logging.Info("start")
 
-	c.currentTimestamp = time.Now().Round(time.Minute).Unix()
+	c.currentTimestamp = c.now().Round(time.Minute).Unix()
 	c.apiCalls = 0
 	c.ingestStart = time.Now()
 
-	jgs := ds.GetJumpgatesUnderConst(c.currentReset)
+	jgs := ds.GetJumpgatesUnderConst(c.store, c.currentReset)
 
 	var constructions []ds.JGConstruction
 	var completions []string
@@ -82,20 +102,10 @@ func (c *Collector) updateJumpgates(ctx context.Context) error {
 			continue
 		}
 
-		var fabmat, advcct int
-		for _, m := range status.Materials {
-			if m.TradeSymbol == "FAB_MATS" {
-				fabmat = m.Fulfilled
-			} else if m.TradeSymbol == "ADVANCED_CIRCUITRY" {
-				advcct = m.Fulfilled
-			}
-		}
-
 		constructions = append(constructions, ds.JGConstruction{
 			Timestamp: c.currentTimestamp,
 			Jumpgate:  jg.Jumpgate,
-			Fabmat:    fabmat,
-			Advcct:    advcct,
+			Materials: status.materials(),
 		})
 
 		if status.IsComplete {
@@ -108,25 +118,40 @@ func (c *Collector) updateJumpgates(ctx context.Context) error {
 	}
 	// logging.Debug("done scan")
 
-	// Add synthetic records for completed jumpgates so charts stay up to date
-	completedJgs := ds.GetJumpgatesComplete(c.currentReset)
+	// Add synthetic records for completed jumpgates so charts stay up to date,
+	// fully delivering whatever recipe they were last seen with
+	completedJgs := ds.GetJumpgatesComplete(c.store, c.currentReset)
+	completedSymbols := make([]string, 0, len(completedJgs))
 	for _, jg := range completedJgs {
+		completedSymbols = append(completedSymbols, jg.Jumpgate)
+	}
+	latest, err := ds.LatestConstructions(c.store, c.currentReset, completedSymbols)
+	if err != nil {
+		logging.Error("error loading the last construction of complete jumpgates", err)
+	}
+	for jg, last := range latest {
 		constructions = append(constructions, ds.JGConstruction{
 			Timestamp: c.currentTimestamp,
-			Jumpgate:  jg.Jumpgate,
-			Fabmat:    1600,
-			Advcct:    400,
+			Jumpgate:  jg,
+			Materials: last.Materials.Completed(),
 		})
 	}
 	completedJgs = nil
+	completedSymbols = nil
 
 	if len(completions) > 0 {
-		ds.MarkJumpgatesComplete(completions, c.currentTimestamp)
+		if err := ds.MarkJumpgatesComplete(c.store, completions, c.currentTimestamp); err != nil {
+			logging.Error("failed to mark jumpgates complete", err)
+		}
 	}
 
 	if len(constructions) > 0 {
-		ds.AddConstructions(constructions, c.currentTimestamp)
+		if err := c.store.AddConstructions(constructions, c.currentTimestamp); err != nil {
+			return err
+		}
+		c.events.Publish(events.ConstructionUpdated, string(c.currentReset))
 	}
+	c.alerts.Check(ctx, c.currentReset)
 	metrics.CollectorJumpgateUpdates.Add(1)
 	metrics.CollectorLastTimestamp.Set(time.Now().Unix())
 
@@ -140,11 +165,11 @@ func (c *Collector) updateJumpgates(ctx context.Context) error {
 func (c *Collector) updateInactiveJumpgates(ctx context.Context) error {
 	logging.Info("start")
 
-	c.currentTimestamp = time.Now().Round(time.Minute).Unix()
+	c.currentTimestamp = c.now().Round(time.Minute).Unix()
 	c.apiCalls = 0
 	c.ingestStart = time.Now()
 
-	jgs := ds.GetJumpgatesNotStarted(c.currentReset)
+	jgs := ds.GetJumpgatesNotStarted(c.store, c.currentReset)
 
 	var constructions []ds.JGConstruction
 	var updateConst []string
@@ -158,20 +183,12 @@ func (c *Collector) updateInactiveJumpgates(ctx context.Context) error {
 			continue
 		}
 
-		var fabmat, advcct int
-		for _, m := range status.Materials {
-			if m.TradeSymbol == "FAB_MATS" {
-				fabmat = m.Fulfilled
-			} else if m.TradeSymbol == "ADVANCED_CIRCUITRY" {
-				advcct = m.Fulfilled
-			}
-		}
-		if fabmat > 0 || advcct > 0 {
+		materials := status.materials()
+		if materials.Started() {
 			constructions = append(constructions, ds.JGConstruction{
 				Timestamp: c.currentTimestamp,
 				Jumpgate:  jg.Jumpgate,
-				Fabmat:    fabmat,
-				Advcct:    advcct,
+				Materials: materials,
 			})
 			updateConst = append(updateConst, system)
 		}
@@ -179,12 +196,18 @@ func (c *Collector) updateInactiveJumpgates(ctx context.Context) error {
 	// logging.Debug("done scan")
 
 	if len(updateConst) > 0 {
-		ds.MarkJumpgatesStarted(updateConst)
+		if err := ds.MarkJumpgatesStarted(c.store, updateConst); err != nil {
+			logging.Error("failed to mark jumpgates started", err)
+		}
 	}
 
 	if len(constructions) > 0 {
-		ds.AddConstructions(constructions, c.currentTimestamp)
+		if err := c.store.AddConstructions(constructions, c.currentTimestamp); err != nil {
+			return err
+		}
+		c.events.Publish(events.ConstructionUpdated, string(c.currentReset))
 	}
+	c.alerts.Check(ctx, c.currentReset)
 	metrics.CollectorConstructionChecks.Add(1)
 	metrics.CollectorLastTimestamp.Set(time.Now().Unix())
 
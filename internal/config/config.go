@@ -0,0 +1,298 @@
+// Package config gathers every setting in one place. A setting comes from
+// its default, then the YAML file if there is one, then its FLUFFY_*
+// environment variable, so deployments configured through the environment
+// keep working as they are.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/papaburgs/fluffy-robot/internal/logging"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	// LogLevel is info or debug
+	LogLevel  string    `yaml:"logLevel"`
+	Collector Collector `yaml:"collector"`
+	Gate      Gate      `yaml:"gate"`
+	Datastore Datastore `yaml:"datastore"`
+	Frontend  Frontend  `yaml:"frontend"`
+	// AlertsFile is the alert rules, alerts are off when it is empty
+	AlertsFile string `yaml:"alertsFile"`
+}
+
+// Collector is where and how often the collector polls
+type Collector struct {
+	BaseURL              string        `yaml:"baseURL"`
+	AgentInterval        time.Duration `yaml:"agentInterval"`
+	JumpgateInterval     time.Duration `yaml:"jumpgateInterval"`
+	ConstructionInterval time.Duration `yaml:"constructionInterval"`
+}
+
+// Gate is the collector's rate limit, requests per second and the extra
+// burst allowed per minute, see gate.New
+type Gate struct {
+	PerSecond int `yaml:"perSecond"`
+	Burst     int `yaml:"burst"`
+}
+
+type Datastore struct {
+	// Backend is disk or bolt
+	Backend            string        `yaml:"backend"`
+	Path               string        `yaml:"path"`
+	WriteJSON          bool          `yaml:"writeJSON"`
+	CacheSizeMB        int64         `yaml:"cacheSizeMB"`
+	CacheDuration      time.Duration `yaml:"cacheDuration"`
+	CompactionInterval time.Duration `yaml:"compactionInterval"`
+	Retention          Retention     `yaml:"retention"`
+}
+
+// Retention is what older resets keep, see datastore.RetentionPolicy. The
+// zero value keeps everything
+type Retention struct {
+	FullResets int           `yaml:"fullResets"`
+	MaxResets  int           `yaml:"maxResets"`
+	DryRun     bool          `yaml:"dryRun"`
+	Interval   time.Duration `yaml:"interval"`
+}
+
+type Frontend struct {
+	Port        string `yaml:"port"`
+	TemplateDir string `yaml:"templateDir"`
+	StaticDir   string `yaml:"staticDir"`
+	// ImportToken guards POST /import, which is off while it is empty
+	ImportToken string        `yaml:"importToken"`
+	StallWindow time.Duration `yaml:"stallWindow"`
+	// StaleAfter is how old the newest collected data can get before
+	// /healthz and /readyz fail
+	StaleAfter time.Duration `yaml:"staleAfter"`
+}
+
+// Default is what runs without a file or any environment
+func Default() Config {
+	return Config{
+		LogLevel: "info",
+		Collector: Collector{
+			BaseURL:              "https://api.spacetraders.io/v2",
+			AgentInterval:        5 * time.Minute,
+			JumpgateInterval:     30 * time.Minute,
+			ConstructionInterval: 4 * time.Hour,
+		},
+		Gate: Gate{PerSecond: 2, Burst: 20},
+		Datastore: Datastore{
+			Backend:            "disk",
+			Path:               "./",
+			CacheSizeMB:        128,
+			CacheDuration:      5 * time.Minute,
+			CompactionInterval: 15 * time.Minute,
+			Retention:          Retention{Interval: time.Hour},
+		},
+		Frontend: Frontend{
+			Port:        "8845",
+			TemplateDir: "internal/frontend",
+			StaticDir:   "internal/frontend",
+			StallWindow: 12 * time.Hour,
+			StaleAfter:  time.Hour,
+		},
+	}
+}
+
+// Load reads path, if it is not empty, over the defaults, applies the
+// environment and validates the result
+func Load(path string) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return Config{}, err
+		}
+		defer f.Close()
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return Config{}, fmt.Errorf("parsing %s: %w", path, err)
+		}
+	}
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// FromEnv is Load of the file named by FLUFFY_CONFIG, for the tools
+func FromEnv() (Config, error) {
+	return Load(os.Getenv("FLUFFY_CONFIG"))
+}
+
+// applyEnv overrides settings with the FLUFFY_* variables that are set
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	vars := []struct {
+		name string
+		set  func(string) error
+	}{
+		{"FLUFFY_LOG_LEVEL", setString(&c.LogLevel)},
+		{"FLUFFY_BASE_URL", setString(&c.Collector.BaseURL)},
+		{"FLUFFY_AGENT_INTERVAL", setDuration(&c.Collector.AgentInterval)},
+		{"FLUFFY_JUMPGATE_INTERVAL", setDuration(&c.Collector.JumpgateInterval)},
+		{"FLUFFY_CONSTRUCTION_INTERVAL", setDuration(&c.Collector.ConstructionInterval)},
+		{"FLUFFY_GATE_PER_SECOND", setInt(&c.Gate.PerSecond)},
+		{"FLUFFY_GATE_BUCKET_SIZE", lenient("FLUFFY_GATE_BUCKET_SIZE", setInt(&c.Gate.Burst))},
+		{"FLUFFY_DATASTORE_BACKEND", setString(&c.Datastore.Backend)},
+		{"FLUFFY_STORAGE_PATH", setString(&c.Datastore.Path)},
+		{"FLUFFY_WRITE_JSON", setBool(&c.Datastore.WriteJSON)},
+		{"FLUFFY_CACHE_SIZE_MB", setInt64(&c.Datastore.CacheSizeMB)},
+		{"FLUFFY_CACHE_DURATION", setDuration(&c.Datastore.CacheDuration)},
+		{"FLUFFY_COMPACTION_INTERVAL", setDuration(&c.Datastore.CompactionInterval)},
+		{"FLUFFY_RETENTION_FULL_RESETS", setInt(&c.Datastore.Retention.FullResets)},
+		{"FLUFFY_RETENTION_MAX_RESETS", setInt(&c.Datastore.Retention.MaxResets)},
+		{"FLUFFY_RETENTION_DRY_RUN", setBool(&c.Datastore.Retention.DryRun)},
+		{"FLUFFY_RETENTION_INTERVAL", setDuration(&c.Datastore.Retention.Interval)},
+		{"FLUFFY_PORT", setString(&c.Frontend.Port)},
+		{"FLUFFY_TEMPLATE_DIR", setString(&c.Frontend.TemplateDir)},
+		{"FLUFFY_STATIC_DIR", setString(&c.Frontend.StaticDir)},
+		{"FLUFFY_IMPORT_TOKEN", setString(&c.Frontend.ImportToken)},
+		{"FLUFFY_STALL_WINDOW", setDuration(&c.Frontend.StallWindow)},
+		{"FLUFFY_STALE_AFTER", setDuration(&c.Frontend.StaleAfter)},
+		{"FLUFFY_ALERTS_FILE", setString(&c.AlertsFile)},
+	}
+	for _, v := range vars {
+		env, ok := lookup(v.name)
+		if !ok {
+			continue
+		}
+		if err := v.set(env); err != nil {
+			return fmt.Errorf("%s: %w", v.name, err)
+		}
+	}
+	return nil
+}
+
+// lenient logs a value set cannot parse and keeps the setting as it was,
+// for variables that never stopped startup before the config file existed
+func lenient(name string, set func(string) error) func(string) error {
+	return func(s string) error {
+		if err := set(s); err != nil {
+			logging.Error("error parsing "+name+", keeping the configured value", "error", err)
+		}
+		return nil
+	}
+}
+
+func setString(p *string) func(string) error {
+	return func(s string) error {
+		*p = s
+		return nil
+	}
+}
+
+func setInt(p *int) func(string) error {
+	return func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		*p = n
+		return nil
+	}
+}
+
+func setInt64(p *int64) func(string) error {
+	return func(s string) error {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		*p = n
+		return nil
+	}
+}
+
+// setBool takes yes, y and true as true, like the variables always have
+func setBool(p *bool) func(string) error {
+	return func(s string) error {
+		switch strings.ToLower(s) {
+		case "yes", "y", "true":
+			*p = true
+		default:
+			*p = false
+		}
+		return nil
+	}
+}
+
+func setDuration(p *time.Duration) func(string) error {
+	return func(s string) error {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*p = d
+		return nil
+	}
+}
+
+// Validate checks every setting is usable
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	switch strings.ToLower(c.LogLevel) {
+	case "info", "debug", "dbg":
+	default:
+		errs = append(errs, fmt.Errorf("logLevel must be info or debug, not %q", c.LogLevel))
+	}
+
+	check(c.Collector.BaseURL != "", "collector.baseURL is empty")
+	check(c.Collector.AgentInterval > 0, "collector.agentInterval must be above 0")
+	check(c.Collector.JumpgateInterval > 0, "collector.jumpgateInterval must be above 0")
+	check(c.Collector.ConstructionInterval > 0, "collector.constructionInterval must be above 0")
+	check(c.Gate.PerSecond > 0, "gate.perSecond must be above 0")
+	check(c.Gate.Burst >= 0, "gate.burst cannot be negative")
+
+	d := c.Datastore
+	switch strings.ToLower(d.Backend) {
+	case "disk", "bolt":
+	default:
+		errs = append(errs, fmt.Errorf("datastore.backend must be disk or bolt, not %q", d.Backend))
+	}
+	check(d.Path != "", "datastore.path is empty")
+	check(d.CacheSizeMB >= 0, "datastore.cacheSizeMB cannot be negative")
+	check(d.CacheDuration >= 0, "datastore.cacheDuration cannot be negative")
+	check(d.CompactionInterval > 0, "datastore.compactionInterval must be above 0")
+	r := d.Retention
+	check(r.FullResets >= 0 && r.MaxResets >= 0, "datastore.retention counts cannot be negative")
+	check(r.MaxResets == 0 || r.MaxResets >= r.FullResets, "datastore.retention.maxResets is below fullResets")
+	check(r.Interval > 0, "datastore.retention.interval must be above 0")
+
+	port := strings.TrimPrefix(c.Frontend.Port, ":")
+	n, err := strconv.Atoi(port)
+	check(err == nil && n > 0 && n < 65536, "frontend.port %q is not a port number", c.Frontend.Port)
+	check(c.Frontend.StallWindow > 0, "frontend.stallWindow must be above 0")
+	check(c.Frontend.StaleAfter > 0, "frontend.staleAfter must be above 0")
+	return errors.Join(errs...)
+}
+
+// String is the config as YAML with the import token hidden, for the log
+func (c Config) String() string {
+	if c.Frontend.ImportToken != "" {
+		c.Frontend.ImportToken = "<set>"
+	}
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
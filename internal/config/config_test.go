@@ -0,0 +1,128 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fluffy.yaml")
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Collector.AgentInterval != 5*time.Minute || cfg.Gate.Burst != 20 || cfg.Frontend.Port != "8845" {
+		t.Fatalf("unexpected defaults: %+v", cfg)
+	}
+	// an empty file changes nothing
+	cfg, err = Load(writeConfig(t, ""))
+	if err != nil || cfg != Default() {
+		t.Fatalf("empty file: %+v %v", cfg, err)
+	}
+}
+
+func TestLoad_FileAndEnv(t *testing.T) {
+	path := writeConfig(t, `
+logLevel: debug
+collector:
+  agentInterval: 1m
+gate:
+  burst: 5
+datastore:
+  backend: bolt
+  path: /data
+  retention:
+    fullResets: 2
+frontend:
+  port: "9000"
+`)
+	t.Setenv("FLUFFY_GATE_BUCKET_SIZE", "8")
+	t.Setenv("FLUFFY_WRITE_JSON", "yes")
+	t.Setenv("FLUFFY_STALL_WINDOW", "3h")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.LogLevel != "debug" || cfg.Collector.AgentInterval != time.Minute || cfg.Datastore.Backend != "bolt" ||
+		cfg.Datastore.Path != "/data" || cfg.Datastore.Retention.FullResets != 2 || cfg.Frontend.Port != "9000" {
+		t.Fatalf("file settings not applied: %+v", cfg)
+	}
+	// the environment wins over the file
+	if cfg.Gate.Burst != 8 || !cfg.Datastore.WriteJSON || cfg.Frontend.StallWindow != 3*time.Hour {
+		t.Fatalf("env settings not applied: %+v", cfg)
+	}
+	// untouched settings keep their defaults
+	if cfg.Collector.JumpgateInterval != 30*time.Minute || cfg.Datastore.CacheSizeMB != 128 {
+		t.Fatalf("defaults lost: %+v", cfg)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("missing file: expected an error")
+	}
+	if _, err := Load(writeConfig(t, "colector:\n  baseURL: x\n")); err == nil {
+		t.Error("misspelt key: expected an error")
+	}
+	t.Run("bad env", func(t *testing.T) {
+		t.Setenv("FLUFFY_AGENT_INTERVAL", "soon")
+		_, err := Load("")
+		if err == nil || !strings.Contains(err.Error(), "FLUFFY_AGENT_INTERVAL") {
+			t.Fatalf("expected an error naming the variable, got %v", err)
+		}
+	})
+	// a bad bucket size was only ever logged, it still is
+	t.Run("bad bucket size", func(t *testing.T) {
+		t.Setenv("FLUFFY_GATE_BUCKET_SIZE", "lots")
+		cfg, err := Load("")
+		if err != nil || cfg.Gate.Burst != 20 {
+			t.Fatalf("expected the default burst, got %d and %v", cfg.Gate.Burst, err)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	for name, change := range map[string]func(*Config){
+		"log level":      func(c *Config) { c.LogLevel = "loud" },
+		"base url":       func(c *Config) { c.Collector.BaseURL = "" },
+		"agent interval": func(c *Config) { c.Collector.AgentInterval = 0 },
+		"gate":           func(c *Config) { c.Gate.PerSecond = 0 },
+		"backend":        func(c *Config) { c.Datastore.Backend = "s3" },
+		"retention":      func(c *Config) { c.Datastore.Retention = Retention{FullResets: 3, MaxResets: 2, Interval: time.Hour} },
+		"port":           func(c *Config) { c.Frontend.Port = "http" },
+	} {
+		cfg := Default()
+		change(&cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	cfg := Default()
+	cfg.Frontend.Port = ":8080"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("port with a colon: %v", err)
+	}
+}
+
+func TestString_HidesToken(t *testing.T) {
+	cfg := Default()
+	cfg.Frontend.ImportToken = "s3cret"
+	out := cfg.String()
+	if strings.Contains(out, "s3cret") || !strings.Contains(out, "importToken: <set>") {
+		t.Fatalf("token not hidden:\n%s", out)
+	}
+	if cfg.Frontend.ImportToken != "s3cret" {
+		t.Fatal("String changed the config")
+	}
+}
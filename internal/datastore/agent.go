@@ -1,7 +1,6 @@
 package datastore
 
 import (
-	"encoding/gob"
 	"fmt"
 	"sort"
 	"time"
@@ -9,7 +8,8 @@ import (
 	"github.com/papaburgs/fluffy-robot/internal/logging"
 )
 
-func StoreAgents(apiAgents []PublicAgent, now int64) {
+// agentRecords splits the api response into the agent list and the status snapshot
+func agentRecords(apiAgents []PublicAgent, now int64) ([]Agent, []AgentStatus) {
 	var (
 		agentList  = []Agent{}
 		statusList = []AgentStatus{}
@@ -32,18 +32,27 @@ func StoreAgents(apiAgents []PublicAgent, now int64) {
 		}
 		statusList = append(statusList, as)
 	}
-	writeData("agents", 0, agentList)
-	writeData("agentsStatus", now, statusList)
-	statusList = nil
+	return agentList, statusList
 }
 
-func GetAgentList(thisReset Reset) ([]Agent, error) {
-	for consolidating {
-		fmt.Println("still consolidating")
-		time.Sleep(time.Second)
+func (d *DiskStore) StoreAgents(apiAgents []PublicAgent, now int64) error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	agentList, statusList := agentRecords(apiAgents, now)
+	if err := d.writeData("agents", 0, agentList); err != nil {
+		return err
+	}
+	if err := d.writeData("agentsStatus", now, statusList); err != nil {
+		return err
 	}
+	err := d.updateAgentRollups(statusList, now)
+	statusList = nil
+	return err
+}
+
+func (d *DiskStore) GetAgentList(thisReset Reset) ([]Agent, error) {
 	res := []Agent{}
-	m, err := readData("agents.", thisReset)
+	m, err := readDecoded[[]Agent](d, "agents.", thisReset)
 	if err != nil {
 		logging.Error("Failed to load agents:", err)
 		return res, err
@@ -53,53 +62,79 @@ func GetAgentList(thisReset Reset) ([]Agent, error) {
 		return res, fmt.Errorf("invalid read")
 	}
 
-	for _, b := range m {
-		gobDec := gob.NewDecoder(b)
-		if err := gobDec.Decode(&res); err != nil {
-			logging.Error("error decoding gob", err)
-			return res, err
-		}
+	for _, v := range m {
+		res = append(res, v...)
 	}
 	m = nil
 	return res, nil
 }
 
-func GetAgentHistory(thisReset Reset, start, end int64) ([]AgentStatus, error) {
-	for consolidating {
-		fmt.Println("still consolidating")
-		time.Sleep(time.Second)
-	}
+func (d *DiskStore) GetAgentHistory(thisReset Reset, start, end int64) ([]AgentStatus, error) {
 	if end == 0 {
 		end = time.Now().Unix()
 	}
 	res := []AgentStatus{}
-	m, err := readData("agentsStatus-", thisReset)
+	m, err := readDecoded[[]AgentStatus](d, "agentsStatus-", thisReset)
 	if err != nil {
 		logging.Error("Failed to load agent history:", err)
 		return res, err
 	}
 
 	allRecords := make([]AgentStatus, 0, len(m)*2)
-	for _, b := range m {
-		gobDec := gob.NewDecoder(b)
-		var v []AgentStatus
-		if err := gobDec.Decode(&v); err != nil {
-			logging.Error("error decoding gob:", err)
-			return res, err
-		}
+	for _, v := range m {
 		allRecords = append(allRecords, v...)
 	}
+	m = nil
+
+	return filterAgentHistory(allRecords, start, end), nil
+}
+
+func (d *DiskStore) GetAgentHistoryFor(thisReset Reset, symbols []string, start, end int64) ([]AgentStatus, error) {
+	res, err := d.GetAgentHistory(thisReset, start, end)
+	return filterAgentSymbols(res, symbols), err
+}
+
+func (d *DiskStore) WalkAgentHistory(thisReset Reset, symbols []string, start, end int64, fn func(AgentStatus) error) error {
+	want := symbolSet(symbols)
+	return walkSeries(d, thisReset, "agentsStatus", start, end, func(a AgentStatus) int64 { return a.Timestamp }, func(a AgentStatus) error {
+		if want != nil && !want[a.Symbol] {
+			return nil
+		}
+		return fn(a)
+	})
+}
+
+// symbolSet is symbols as a set, nil for nil
+func symbolSet(symbols []string) map[string]bool {
+	if symbols == nil {
+		return nil
+	}
+	want := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		want[s] = true
+	}
+	return want
+}
 
-	if len(m) > 10 {
-		consolidating = true
-		// consolidate("agentsStatus", allRecords, m)
-		consolidating = false
-		m = nil
-	} else {
-		m = nil
+// filterAgentSymbols keeps records for the given symbols, order is preserved
+func filterAgentSymbols(records []AgentStatus, symbols []string) []AgentStatus {
+	want := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		want[s] = true
 	}
+	res := []AgentStatus{}
+	for _, r := range records {
+		if want[r.Symbol] {
+			res = append(res, r)
+		}
+	}
+	return res
+}
 
-	for _, r := range allRecords {
+// filterAgentHistory keeps records inside [start, end] ordered by timestamp
+func filterAgentHistory(records []AgentStatus, start, end int64) []AgentStatus {
+	res := []AgentStatus{}
+	for _, r := range records {
 		if r.Timestamp >= start && r.Timestamp <= end {
 			res = append(res, r)
 		}
@@ -107,5 +142,5 @@ func GetAgentHistory(thisReset Reset, start, end int64) ([]AgentStatus, error) {
 	sort.Slice(res, func(i, j int) bool {
 		return res[i].Timestamp < res[j].Timestamp
 	})
-	return res, nil
+	return res
 }
@@ -0,0 +1,682 @@
+package datastore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/papaburgs/fluffy-robot/internal/logging"
+	"github.com/papaburgs/fluffy-robot/internal/metrics"
+	bolt "go.etcd.io/bbolt"
+)
+
+const boltFilename = "timeseries.db"
+
+var (
+	agentStatusBucket  = []byte("agentsStatus")
+	constructionBucket = []byte("construction")
+)
+
+// rollupBucket holds series at tier, keyed by bucket start under
+// reset/symbol the same way as the raw rows
+func rollupBucket(series string, tier Tier) []byte {
+	return []byte(rollupBasename(series, tier))
+}
+
+// BoltStore keeps agent status and construction history in a bbolt database
+// so range queries are index lookups instead of decoding every snapshot file.
+// Rows are nested as bucket/reset/symbol/timestamp. Everything that is a
+// single overwritten snapshot (agents, jumpgates, stats...) stays with the
+// embedded DiskStore.
+type BoltStore struct {
+	*DiskStore
+	db *bolt.DB
+}
+
+// NewBoltStore opens (or creates) timeseries.db under path. History files
+// from resets the database has not seen yet are imported on open.
+func NewBoltStore(path string, writeJSON bool) (*BoltStore, error) {
+	disk, err := NewDiskStore(path, writeJSON)
+	if err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filepath.Join(path, boltFilename), 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		buckets := [][]byte{agentStatusBucket, constructionBucket}
+		for _, tier := range rollupTiers {
+			buckets = append(buckets, rollupBucket("agents", tier), rollupBucket("construction", tier))
+		}
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	b := &BoltStore{DiskStore: disk, db: db}
+	b.backfill()
+	return b, nil
+}
+
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+// backfill imports agentsStatus and construction files for every reset
+// directory that has no rows in the database yet
+func (b *BoltStore) backfill() {
+	for _, r := range b.AllResets() {
+		thisReset := Reset(r)
+		if b.hasReset(agentStatusBucket, thisReset) {
+			continue
+		}
+		start := time.Now()
+		status, err := b.DiskStore.GetAgentHistory(thisReset, 0, 0)
+		if err != nil {
+			logging.Warn("backfill: failed to read agent history for", r, err)
+		}
+		if err := b.putAgentStatus(thisReset, status); err != nil {
+			logging.Error("backfill: failed to import agent history for", r, err)
+			continue
+		}
+		constructions, err := b.DiskStore.GetConstructions(thisReset, 0, 0)
+		if err != nil {
+			logging.Warn("backfill: failed to read constructions for", r, err)
+		}
+		if err := b.putConstructions(thisReset, constructions); err != nil {
+			logging.Error("backfill: failed to import constructions for", r, err)
+			continue
+		}
+		logging.Info("backfill: imported reset", r, "agentStatus", len(status), "constructions", len(constructions), "duration", time.Since(start))
+	}
+}
+
+func (b *BoltStore) hasReset(bucket []byte, thisReset Reset) bool {
+	found := false
+	b.db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket(bucket).Bucket([]byte(thisReset)) != nil
+		return nil
+	})
+	return found
+}
+
+// resetOrCurrent maps an empty reset to the one being written
+func (b *BoltStore) resetOrCurrent(thisReset Reset) Reset {
+	if thisReset == "" {
+		return b.CurrentReset()
+	}
+	return thisReset
+}
+
+func timestampKey(ts int64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(ts))
+	return k
+}
+
+func encodeAgentStatus(a AgentStatus) []byte {
+	v := make([]byte, 16)
+	binary.BigEndian.PutUint64(v[0:8], uint64(a.Credits))
+	binary.BigEndian.PutUint64(v[8:16], uint64(a.Ships))
+	return v
+}
+
+func decodeAgentStatus(symbol string, k, v []byte) (AgentStatus, error) {
+	if len(k) != 8 || len(v) != 16 {
+		return AgentStatus{}, fmt.Errorf("invalid agent status row for %s", symbol)
+	}
+	return AgentStatus{
+		Symbol:    symbol,
+		Timestamp: int64(binary.BigEndian.Uint64(k)),
+		Credits:   int64(binary.BigEndian.Uint64(v[0:8])),
+		Ships:     int64(binary.BigEndian.Uint64(v[8:16])),
+	}, nil
+}
+
+// decodeConstruction reads a construction row. Rows are not versioned, this
+// reads both layouts
+func decodeConstruction(_ string, _, v []byte) (JGConstruction, error) {
+	var c legacyConstruction
+	if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&c); err != nil {
+		return JGConstruction{}, err
+	}
+	return c.current(), nil
+}
+
+// decodeConstructionRollup reads a construction rollup row in either layout,
+// like decodeConstruction
+func decodeConstructionRollup(_ string, _, v []byte) (ConstructionRollup, error) {
+	var c legacyConstructionRollup
+	if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&c); err != nil {
+		return ConstructionRollup{}, err
+	}
+	return c.current(), nil
+}
+
+func encodeAgentRollup(a AgentRollup) []byte {
+	v := make([]byte, 56)
+	for i, n := range []int64{a.LastTimestamp, a.Credits.Min, a.Credits.Max, a.Credits.Last, a.Ships.Min, a.Ships.Max, a.Ships.Last} {
+		binary.BigEndian.PutUint64(v[i*8:i*8+8], uint64(n))
+	}
+	return v
+}
+
+func decodeAgentRollup(symbol string, k, v []byte) (AgentRollup, error) {
+	if len(k) != 8 || len(v) != 56 {
+		return AgentRollup{}, fmt.Errorf("invalid agent rollup row for %s", symbol)
+	}
+	n := func(i int) int64 {
+		return int64(binary.BigEndian.Uint64(v[i*8 : i*8+8]))
+	}
+	return AgentRollup{
+		Symbol:        symbol,
+		Timestamp:     int64(binary.BigEndian.Uint64(k)),
+		LastTimestamp: n(0),
+		Credits:       Rollup{Min: n(1), Max: n(2), Last: n(3)},
+		Ships:         Rollup{Min: n(4), Max: n(5), Last: n(6)},
+	}, nil
+}
+
+// symbolBucket returns bucket/reset/symbol, creating it as needed
+func symbolBucket(tx *bolt.Tx, bucket []byte, thisReset Reset, symbol string) (*bolt.Bucket, error) {
+	resetBucket, err := tx.Bucket(bucket).CreateBucketIfNotExists([]byte(thisReset))
+	if err != nil {
+		return nil, err
+	}
+	return resetBucket.CreateBucketIfNotExists([]byte(symbol))
+}
+
+// putAgentStatus writes the raw rows and folds them into every rollup tier
+// in the same transaction
+func (b *BoltStore) putAgentStatus(thisReset Reset, statusList []AgentStatus) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		for _, s := range statusList {
+			agentBucket, err := symbolBucket(tx, agentStatusBucket, thisReset, s.Symbol)
+			if err != nil {
+				return err
+			}
+			if err := put(agentBucket, timestampKey(s.Timestamp), encodeAgentStatus(s)); err != nil {
+				return err
+			}
+			for _, tier := range rollupTiers {
+				tierBucket, err := symbolBucket(tx, rollupBucket("agents", tier), thisReset, s.Symbol)
+				if err != nil {
+					return err
+				}
+				k := timestampKey(tier.bucketStart(s.Timestamp))
+				a := AgentRollup{Symbol: s.Symbol, Timestamp: tier.bucketStart(s.Timestamp)}
+				if v := tierBucket.Get(k); v != nil {
+					if a, err = decodeAgentRollup(s.Symbol, k, v); err != nil {
+						return err
+					}
+				}
+				if err := put(tierBucket, k, encodeAgentRollup(a.addAgentStatus(s))); err != nil {
+					return err
+				}
+			}
+		}
+		metrics.DatastoreWrites.Add(1)
+		return nil
+	})
+}
+
+func (b *BoltStore) putConstructions(thisReset Reset, cList []JGConstruction) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		for _, c := range cList {
+			jgBucket, err := symbolBucket(tx, constructionBucket, thisReset, c.Jumpgate)
+			if err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			if err := gob.NewEncoder(&buf).Encode(c); err != nil {
+				return err
+			}
+			if err := put(jgBucket, timestampKey(c.Timestamp), buf.Bytes()); err != nil {
+				return err
+			}
+			for _, tier := range rollupTiers {
+				tierBucket, err := symbolBucket(tx, rollupBucket("construction", tier), thisReset, c.Jumpgate)
+				if err != nil {
+					return err
+				}
+				k := timestampKey(tier.bucketStart(c.Timestamp))
+				cr := ConstructionRollup{Jumpgate: c.Jumpgate, Timestamp: tier.bucketStart(c.Timestamp)}
+				if v := tierBucket.Get(k); v != nil {
+					var old legacyConstructionRollup
+					if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&old); err != nil {
+						return err
+					}
+					cr = old.current()
+				}
+				var rbuf bytes.Buffer
+				if err := gob.NewEncoder(&rbuf).Encode(cr.addConstruction(c)); err != nil {
+					return err
+				}
+				if err := put(tierBucket, k, rbuf.Bytes()); err != nil {
+					return err
+				}
+			}
+		}
+		metrics.DatastoreWrites.Add(1)
+		return nil
+	})
+}
+
+// put is Bucket.Put, counting the bytes written
+func put(b *bolt.Bucket, k, v []byte) error {
+	metrics.DatastoreBytesWritten.Add(int64(len(k) + len(v)))
+	return b.Put(k, v)
+}
+
+// scanRange calls fn for each row of the symbol buckets under bucket/reset
+// with a timestamp in [start, end]. A nil symbols slice scans every symbol.
+func (b *BoltStore) scanRange(bucket []byte, thisReset Reset, symbols []string, start, end int64, fn func(symbol string, k, v []byte) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		resetBucket := tx.Bucket(bucket).Bucket([]byte(thisReset))
+		if resetBucket == nil {
+			return nil
+		}
+		if symbols == nil {
+			resetBucket.ForEachBucket(func(k []byte) error {
+				symbols = append(symbols, string(k))
+				return nil
+			})
+		}
+		endKey := timestampKey(end)
+		for _, symbol := range symbols {
+			symBucket := resetBucket.Bucket([]byte(symbol))
+			if symBucket == nil {
+				continue
+			}
+			c := symBucket.Cursor()
+			for k, v := c.Seek(timestampKey(start)); k != nil && bytes.Compare(k, endKey) <= 0; k, v = c.Next() {
+				metrics.DatastoreBytesRead.Add(int64(len(k) + len(v)))
+				if err := fn(symbol, k, v); err != nil {
+					return err
+				}
+			}
+		}
+		metrics.DatastoreReads.Add(1)
+		return nil
+	})
+}
+
+// walkStep is how much history walkRows reads per transaction
+const walkStep = 86400
+
+// walkRows calls fn with the rows of bucket/reset in [start, end] in
+// timestamp order. It reads a day at a time, each in its own transaction, so
+// neither the whole range nor a read transaction is held while fn runs
+func walkRows[T any](b *BoltStore, bucket []byte, thisReset Reset, symbols []string, start, end int64, decode func(symbol string, k, v []byte) (T, error), stamp func(T) int64, fn func(T) error) error {
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	thisReset = b.resetOrCurrent(thisReset)
+	for from := start; from <= end; {
+		to := min(from+walkStep-1, end)
+		// next is the first row after this window, so empty days are skipped
+		next := int64(math.MaxInt64)
+		var rows []T
+		err := b.db.View(func(tx *bolt.Tx) error {
+			resetBucket := tx.Bucket(bucket).Bucket([]byte(thisReset))
+			if resetBucket == nil {
+				return nil
+			}
+			syms := symbols
+			if syms == nil {
+				resetBucket.ForEachBucket(func(k []byte) error {
+					syms = append(syms, string(k))
+					return nil
+				})
+			}
+			for _, symbol := range syms {
+				symBucket := resetBucket.Bucket([]byte(symbol))
+				if symBucket == nil {
+					continue
+				}
+				c := symBucket.Cursor()
+				for k, v := c.Seek(timestampKey(from)); k != nil; k, v = c.Next() {
+					if ts := int64(binary.BigEndian.Uint64(k)); ts > to {
+						next = min(next, ts)
+						break
+					}
+					metrics.DatastoreBytesRead.Add(int64(len(k) + len(v)))
+					r, err := decode(symbol, k, v)
+					if err != nil {
+						return err
+					}
+					rows = append(rows, r)
+				}
+			}
+			metrics.DatastoreReads.Add(1)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			return stamp(rows[i]) < stamp(rows[j])
+		})
+		for _, r := range rows {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		if next > end {
+			break
+		}
+		from = next
+	}
+	return nil
+}
+
+func (b *BoltStore) WalkAgentHistory(thisReset Reset, symbols []string, start, end int64, fn func(AgentStatus) error) error {
+	return walkRows(b, agentStatusBucket, thisReset, symbols, start, end, decodeAgentStatus, func(a AgentStatus) int64 { return a.Timestamp }, fn)
+}
+
+func (b *BoltStore) WalkConstructions(thisReset Reset, jumpgates []string, start, end int64, fn func(JGConstruction) error) error {
+	return walkRows(b, constructionBucket, thisReset, jumpgates, start, end, decodeConstruction, func(c JGConstruction) int64 { return c.Timestamp }, fn)
+}
+
+func (b *BoltStore) StoreAgents(apiAgents []PublicAgent, now int64) error {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	agentList, statusList := agentRecords(apiAgents, now)
+	if err := b.writeData("agents", 0, agentList); err != nil {
+		return err
+	}
+	return b.putAgentStatus(b.CurrentReset(), statusList)
+}
+
+func (b *BoltStore) GetAgentHistory(thisReset Reset, start, end int64) ([]AgentStatus, error) {
+	return b.agentHistory(thisReset, nil, start, end)
+}
+
+func (b *BoltStore) GetAgentHistoryFor(thisReset Reset, symbols []string, start, end int64) ([]AgentStatus, error) {
+	if symbols == nil {
+		symbols = []string{}
+	}
+	return b.agentHistory(thisReset, symbols, start, end)
+}
+
+func (b *BoltStore) agentHistory(thisReset Reset, symbols []string, start, end int64) ([]AgentStatus, error) {
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	res := []AgentStatus{}
+	err := b.scanRange(agentStatusBucket, b.resetOrCurrent(thisReset), symbols, start, end, func(symbol string, k, v []byte) error {
+		as, err := decodeAgentStatus(symbol, k, v)
+		if err != nil {
+			return err
+		}
+		res = append(res, as)
+		return nil
+	})
+	if err != nil {
+		logging.Error("Failed to load agent history:", err)
+		return []AgentStatus{}, err
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Timestamp < res[j].Timestamp
+	})
+	return res, nil
+}
+
+func (b *BoltStore) AddConstructions(cList []JGConstruction, ts int64) error {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	return b.putConstructions(b.CurrentReset(), cList)
+}
+
+func (b *BoltStore) GetConstructions(thisReset Reset, start, end int64) ([]JGConstruction, error) {
+	return b.constructions(thisReset, nil, start, end)
+}
+
+func (b *BoltStore) GetConstructionsFor(thisReset Reset, jumpgates []string, start, end int64) ([]JGConstruction, error) {
+	if jumpgates == nil {
+		jumpgates = []string{}
+	}
+	return b.constructions(thisReset, jumpgates, start, end)
+}
+
+func (b *BoltStore) constructions(thisReset Reset, jumpgates []string, start, end int64) ([]JGConstruction, error) {
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	res := []JGConstruction{}
+	err := b.scanRange(constructionBucket, b.resetOrCurrent(thisReset), jumpgates, start, end, func(symbol string, k, v []byte) error {
+		c, err := decodeConstruction(symbol, k, v)
+		if err != nil {
+			return err
+		}
+		res = append(res, c)
+		return nil
+	})
+	if err != nil {
+		return []JGConstruction{}, err
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Timestamp < res[j].Timestamp
+	})
+	return res, nil
+}
+
+// GetAgentRollups reads the tier buckets, resets imported before rollups
+// existed have none and are rolled up from the raw rows instead
+func (b *BoltStore) GetAgentRollups(thisReset Reset, symbols []string, tier Tier, start, end int64) ([]AgentRollup, error) {
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	thisReset = b.resetOrCurrent(thisReset)
+	if tier == TierRaw {
+		hist, err := b.agentHistory(thisReset, symbols, start, end)
+		return filterAgentRollups(rawAgentRollups(hist), symbols, tier, start, end), err
+	}
+	if !b.hasReset(rollupBucket("agents", tier), thisReset) {
+		hist, err := b.agentHistory(thisReset, symbols, tier.bucketStart(start), end)
+		return filterAgentRollups(mergeAgentRollups(nil, hist, tier), symbols, tier, start, end), err
+	}
+	res := []AgentRollup{}
+	err := b.scanRange(rollupBucket("agents", tier), thisReset, symbols, tier.bucketStart(start), end, func(symbol string, k, v []byte) error {
+		a, err := decodeAgentRollup(symbol, k, v)
+		if err != nil {
+			return err
+		}
+		res = append(res, a)
+		return nil
+	})
+	if err != nil {
+		return []AgentRollup{}, err
+	}
+	sortAgentRollups(res)
+	return res, nil
+}
+
+func (b *BoltStore) GetConstructionRollups(thisReset Reset, jumpgates []string, tier Tier, start, end int64) ([]ConstructionRollup, error) {
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	thisReset = b.resetOrCurrent(thisReset)
+	if tier == TierRaw {
+		cons, err := b.constructions(thisReset, jumpgates, start, end)
+		return filterConstructionRollups(rawConstructionRollups(cons), jumpgates, tier, start, end), err
+	}
+	if !b.hasReset(rollupBucket("construction", tier), thisReset) {
+		cons, err := b.constructions(thisReset, jumpgates, tier.bucketStart(start), end)
+		return filterConstructionRollups(mergeConstructionRollups(nil, cons, tier), jumpgates, tier, start, end), err
+	}
+	res := []ConstructionRollup{}
+	err := b.scanRange(rollupBucket("construction", tier), thisReset, jumpgates, tier.bucketStart(start), end, func(symbol string, k, v []byte) error {
+		c, err := decodeConstructionRollup(symbol, k, v)
+		if err != nil {
+			return err
+		}
+		res = append(res, c)
+		return nil
+	})
+	if err != nil {
+		return []ConstructionRollup{}, err
+	}
+	sortConstructionRollups(res)
+	return res, nil
+}
+
+func (b *BoltStore) putAgentRollups(tier Tier, thisReset Reset, rollups []AgentRollup) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		for _, a := range rollups {
+			tierBucket, err := symbolBucket(tx, rollupBucket("agents", tier), thisReset, a.Symbol)
+			if err != nil {
+				return err
+			}
+			if err := put(tierBucket, timestampKey(a.Timestamp), encodeAgentRollup(a)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *BoltStore) putConstructionRollups(tier Tier, thisReset Reset, rollups []ConstructionRollup) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		for _, c := range rollups {
+			tierBucket, err := symbolBucket(tx, rollupBucket("construction", tier), thisReset, c.Jumpgate)
+			if err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			if err := gob.NewEncoder(&buf).Encode(c); err != nil {
+				return err
+			}
+			if err := put(tierBucket, timestampKey(c.Timestamp), buf.Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Prune applies p to the database rows and then to the files of the
+// embedded DiskStore
+func (b *BoltStore) Prune(p RetentionPolicy) ([]PruneResult, error) {
+	b.compactMu.Lock()
+	defer b.compactMu.Unlock()
+	results := []PruneResult{}
+	for _, step := range p.plan(b.AllResets(), b.CurrentReset()) {
+		var (
+			res PruneResult
+			err error
+		)
+		switch step.action {
+		case RetainRollups:
+			res, err = b.DiskStore.trimReset(step.reset, p.DryRun)
+		case RetainNone:
+			res, err = b.DiskStore.deleteReset(step.reset, p.DryRun)
+		default:
+			continue
+		}
+		if err != nil {
+			return results, fmt.Errorf("%s %s: %w", step.action, step.reset, err)
+		}
+		if res.Rows, err = b.pruneRows(step.reset, step.action, p.DryRun); err != nil {
+			return results, fmt.Errorf("%s %s: %w", step.action, step.reset, err)
+		}
+		if res.Files > 0 || res.Rows > 0 || step.action == RetainNone {
+			results = append(results, res)
+		}
+	}
+	return results, nil
+}
+
+// pruneRows drops the rows of thisReset that action does not keep and
+// returns how many there were. Trimmed resets keep an empty raw bucket so
+// backfill does not import them again
+func (b *BoltStore) pruneRows(thisReset Reset, action RetentionAction, dryRun bool) (int, error) {
+	emptied := [][]byte{agentStatusBucket, constructionBucket}
+	dropped := [][]byte{rollupBucket("agents", TierHour), rollupBucket("construction", TierHour)}
+	if action == RetainNone {
+		dropped = append(dropped, emptied...)
+		dropped = append(dropped, rollupBucket("agents", TierDay), rollupBucket("construction", TierDay))
+		emptied = nil
+	}
+	if action == RetainRollups && !dryRun {
+		if err := b.ensureDailyRollups(thisReset); err != nil {
+			return 0, err
+		}
+	}
+
+	rows := 0
+	count := func(tx *bolt.Tx, name []byte) int {
+		if rb := tx.Bucket(name).Bucket([]byte(thisReset)); rb != nil {
+			return rb.Stats().KeyN
+		}
+		return 0
+	}
+	if dryRun {
+		err := b.db.View(func(tx *bolt.Tx) error {
+			for _, name := range append(dropped, emptied...) {
+				rows += count(tx, name)
+			}
+			return nil
+		})
+		return rows, err
+	}
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range append(dropped, emptied...) {
+			n := count(tx, name)
+			if n == 0 {
+				continue
+			}
+			rows += n
+			if err := tx.Bucket(name).DeleteBucket([]byte(thisReset)); err != nil {
+				return err
+			}
+		}
+		for _, name := range emptied {
+			if _, err := tx.Bucket(name).CreateBucketIfNotExists([]byte(thisReset)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return rows, err
+}
+
+// ensureDailyRollups folds every raw row into the daily tier, so days with
+// no daily row, from before rollups or before this reset's began, keep one
+// once the raw rows go. Folding is idempotent, rows already up to date come
+// out the same
+func (b *BoltStore) ensureDailyRollups(thisReset Reset) error {
+	now := time.Now().Unix()
+	hist, err := b.agentHistory(thisReset, nil, 0, now)
+	if err != nil {
+		return err
+	}
+	days, err := b.GetAgentRollups(thisReset, nil, TierDay, 0, now)
+	if err != nil {
+		return err
+	}
+	if err := b.putAgentRollups(TierDay, thisReset, mergeAgentRollups(days, hist, TierDay)); err != nil {
+		return err
+	}
+	cons, err := b.constructions(thisReset, nil, 0, now)
+	if err != nil {
+		return err
+	}
+	consDays, err := b.GetConstructionRollups(thisReset, nil, TierDay, 0, now)
+	if err != nil {
+		return err
+	}
+	return b.putConstructionRollups(TierDay, thisReset, mergeConstructionRollups(consDays, cons, TierDay))
+}
@@ -0,0 +1,168 @@
+package datastore
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/papaburgs/fluffy-robot/internal/metrics"
+)
+
+const (
+	defaultCacheBytes    int64 = 128 << 20
+	defaultCacheDuration       = 5 * time.Minute
+)
+
+type cacheKey struct {
+	reset Reset
+	file  string
+}
+
+type cacheEntry struct {
+	key    cacheKey
+	value  any
+	size   int64
+	loaded time.Time
+}
+
+// fileCache is an LRU of decoded snapshot files. Size is the decompressed
+// gob length, which is close enough to the decoded size to bound memory.
+// Values are shared between readers and must not be modified.
+//
+// A reader opens a file under filesMu but adds it after the lock is gone, so
+// a write can land in between. Every invalidation stamps the key, its reset
+// or the whole cache with the next seq, and add drops values read before the
+// latest stamp, see generation.
+type fileCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	ttl      time.Duration
+	used     int64
+	ll       *list.List
+	items    map[cacheKey]*list.Element
+
+	seq       uint64
+	gens      map[cacheKey]uint64
+	resetGens map[Reset]uint64
+	purged    uint64
+}
+
+func newFileCache(maxBytes int64, ttl time.Duration) *fileCache {
+	return &fileCache{
+		maxBytes:  maxBytes,
+		ttl:       ttl,
+		ll:        list.New(),
+		items:     make(map[cacheKey]*list.Element),
+		gens:      make(map[cacheKey]uint64),
+		resetGens: make(map[Reset]uint64),
+	}
+}
+
+// generation is the stamp of the last invalidation covering k. Readers take
+// it while holding filesMu, together with opening the file, and hand it to add
+func (c *fileCache) generation(k cacheKey) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen(k)
+}
+
+// gen is generation for callers holding mu
+func (c *fileCache) gen(k cacheKey) uint64 {
+	return max(c.gens[k], c.resetGens[k.reset], c.purged)
+}
+
+func (c *fileCache) get(k cacheKey) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[k]
+	if !ok {
+		metrics.DatastoreCacheMisses.Add(1)
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if c.ttl > 0 && time.Since(e.loaded) > c.ttl {
+		c.remove(el)
+		metrics.DatastoreCacheEvictions.Add(1)
+		metrics.DatastoreCacheMisses.Add(1)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	metrics.DatastoreCacheHits.Add(1)
+	return e.value, true
+}
+
+// add caches v unless k was invalidated since gen was taken, in which case
+// v may already be out of date
+func (c *fileCache) add(k cacheKey, v any, size int64, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.maxBytes <= 0 || size > c.maxBytes || c.gen(k) != gen {
+		return
+	}
+	if el, ok := c.items[k]; ok {
+		c.remove(el)
+	}
+	c.items[k] = c.ll.PushFront(&cacheEntry{key: k, value: v, size: size, loaded: time.Now()})
+	c.used += size
+	for c.used > c.maxBytes {
+		c.remove(c.ll.Back())
+		metrics.DatastoreCacheEvictions.Add(1)
+	}
+	metrics.DatastoreCacheBytes.Set(c.used)
+}
+
+func (c *fileCache) invalidate(k cacheKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	c.gens[k] = c.seq
+	if el, ok := c.items[k]; ok {
+		c.remove(el)
+		metrics.DatastoreCacheBytes.Set(c.used)
+	}
+}
+
+// invalidateReset drops every file of thisReset
+func (c *fileCache) invalidateReset(thisReset Reset) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	c.resetGens[thisReset] = c.seq
+	// the reset stamp covers these now
+	for k := range c.gens {
+		if k.reset == thisReset {
+			delete(c.gens, k)
+		}
+	}
+	for k, el := range c.items {
+		if k.reset == thisReset {
+			c.remove(el)
+		}
+	}
+	metrics.DatastoreCacheBytes.Set(c.used)
+}
+
+// purge drops everything, used when the limits change
+func (c *fileCache) purge(maxBytes int64, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	c.items = make(map[cacheKey]*list.Element)
+	c.seq++
+	c.purged = c.seq
+	c.gens = make(map[cacheKey]uint64)
+	c.resetGens = make(map[Reset]uint64)
+	c.used = 0
+	c.maxBytes = maxBytes
+	c.ttl = ttl
+	metrics.DatastoreCacheResets.Add(1)
+	metrics.DatastoreCacheBytes.Set(0)
+}
+
+// remove unlinks el, callers hold mu
+func (c *fileCache) remove(el *list.Element) {
+	e := el.Value.(*cacheEntry)
+	c.ll.Remove(el)
+	delete(c.items, e.key)
+	c.used -= e.size
+}
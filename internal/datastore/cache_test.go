@@ -0,0 +1,154 @@
+package datastore
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/papaburgs/fluffy-robot/internal/metrics"
+)
+
+func TestFileCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newFileCache(100, 0)
+	c.add(cacheKey{"r", "a"}, "a", 40, 0)
+	c.add(cacheKey{"r", "b"}, "b", 40, 0)
+	if _, ok := c.get(cacheKey{"r", "a"}); !ok {
+		t.Fatalf("expected a to be cached")
+	}
+	c.add(cacheKey{"r", "c"}, "c", 40, 0)
+
+	if _, ok := c.get(cacheKey{"r", "b"}); ok {
+		t.Fatalf("expected b to be evicted")
+	}
+	if _, ok := c.get(cacheKey{"r", "a"}); !ok {
+		t.Fatalf("expected a to survive, it was used more recently than b")
+	}
+	if c.used != 80 {
+		t.Fatalf("expected 80 bytes used, got %d", c.used)
+	}
+}
+
+func TestFileCache_Expires(t *testing.T) {
+	c := newFileCache(100, time.Millisecond)
+	c.add(cacheKey{"r", "a"}, "a", 10, 0)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.get(cacheKey{"r", "a"}); ok {
+		t.Fatalf("expected a to have expired")
+	}
+	if c.used != 0 {
+		t.Fatalf("expected expired entry to be released, %d bytes used", c.used)
+	}
+}
+
+func TestDiskStore_CacheInvalidatedOnWrite(t *testing.T) {
+	d, err := NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	if err := d.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	agents := []PublicAgent{{Symbol: "ALPHA", Credits: 175000, Headquarters: "X1-AB12-A1"}}
+	if err := d.StoreAgents(agents, time.Now().Unix()); err != nil {
+		t.Fatalf("StoreAgents: %v", err)
+	}
+
+	hits := metrics.DatastoreCacheHits.Value()
+	for i := 0; i < 2; i++ {
+		if _, err := d.GetAgentList(""); err != nil {
+			t.Fatalf("GetAgentList: %v", err)
+		}
+	}
+	if metrics.DatastoreCacheHits.Value() != hits+1 {
+		t.Fatalf("expected the second read to hit the cache")
+	}
+
+	agents[0].Credits = 500000
+	if err := d.StoreAgents(agents, time.Now().Unix()+1); err != nil {
+		t.Fatalf("StoreAgents: %v", err)
+	}
+	list, err := d.GetAgentList("")
+	if err != nil {
+		t.Fatalf("GetAgentList: %v", err)
+	}
+	if list[0].Credits != 500000 {
+		t.Fatalf("expected the rewrite to invalidate the cache, got %d credits", list[0].Credits)
+	}
+}
+
+func TestFileCache_DropsValuesReadBeforeInvalidation(t *testing.T) {
+	c := newFileCache(100, 0)
+	k := cacheKey{"r", "a"}
+	for name, invalidate := range map[string]func(){
+		"key":   func() { c.invalidate(k) },
+		"reset": func() { c.invalidateReset("r") },
+		"purge": func() { c.purge(100, 0) },
+	} {
+		gen := c.generation(k)
+		invalidate()
+		c.add(k, "old", 10, gen)
+		if _, ok := c.get(k); ok {
+			t.Fatalf("%s: expected a value read before the invalidation to be dropped", name)
+		}
+		c.add(k, "new", 10, c.generation(k))
+		if _, ok := c.get(k); !ok {
+			t.Fatalf("%s: expected a value read after the invalidation to be cached", name)
+		}
+	}
+}
+
+// run with -race, readers fill the cache while every write invalidates it
+func TestDiskStore_CacheConcurrentReadWrite(t *testing.T) {
+	d, err := NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	if err := d.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	const writes = 50
+	hour := int64(1767528000)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				d.GetAgentList("")
+				d.GetAgentRollups("", nil, TierHour, 0, 0)
+			}
+		}()
+	}
+	for i := int64(1); i <= writes; i++ {
+		agents := []PublicAgent{{Symbol: "ALPHA", Credits: i * 1000, Headquarters: "X1-AB12-A1"}}
+		if err := d.StoreAgents(agents, hour+i); err != nil {
+			t.Fatalf("StoreAgents: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	list, err := d.GetAgentList("")
+	if err != nil {
+		t.Fatalf("GetAgentList: %v", err)
+	}
+	if list[0].Credits != writes*1000 {
+		t.Fatalf("expected the last write in the cache, got %d credits", list[0].Credits)
+	}
+	for _, tier := range rollupTiers {
+		rows, err := d.GetAgentRollups("", nil, tier, 0, 0)
+		if err != nil {
+			t.Fatalf("GetAgentRollups: %v", err)
+		}
+		if len(rows) != 1 || rows[0].Credits.Min != 1000 || rows[0].Credits.Max != writes*1000 {
+			t.Fatalf("%s: expected every write in the rollup, got %+v", tier.Name, rows)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package datastore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/papaburgs/fluffy-robot/internal/logging"
+	"github.com/papaburgs/fluffy-robot/internal/metrics"
+)
+
+// Per-tick history files are merged into hourly segments once their hour is
+// over, and hourly segments into daily segments once their (UTC) day is over.
+// Segments keep the "<basename>-" prefix so readData picks them up as-is:
+//
+//	agentsStatus-1767225900.gob.zst       one tick
+//	agentsStatus-hour-1767225600.gob.zst  every tick of that hour
+//	agentsStatus-day-1767139200.gob.zst   every tick of that day
+//
+// A segment is written to a temp file and renamed into place, then its inputs
+// are removed, both under filesMu so readers never see half of a swap. If we
+// die between the rename and the removals the inputs are merged again on the
+// next run and the duplicate rows are dropped.
+
+const (
+	levelTick = ""
+	levelHour = "hour"
+	levelDay  = "day"
+)
+
+// Compactor is implemented by stores that keep history as snapshot files
+type Compactor interface {
+	RunCompaction(ctx context.Context, interval time.Duration)
+}
+
+// RunCompaction compacts every reset directory now and then every interval
+// until ctx is cancelled
+func (d *DiskStore) RunCompaction(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		d.Compact(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Compact merges every hour and day that is over as of now
+func (d *DiskStore) Compact(now time.Time) {
+	d.compactMu.Lock()
+	defer d.compactMu.Unlock()
+	start := time.Now()
+	metrics.DatastoreCompactionRuns.Add(1)
+	for _, r := range d.AllResets() {
+		dir := filepath.Join(d.path, r)
+		compactSeries(d, Reset(r), dir, "agentsStatus", now, func(a AgentStatus) string {
+			return fmt.Sprintf("%s/%d", a.Symbol, a.Timestamp)
+		})
+		compactSeries(d, Reset(r), dir, "construction", now, func(c JGConstruction) string {
+			return fmt.Sprintf("%s/%d", c.Jumpgate, c.Timestamp)
+		})
+		compactSeries(d, Reset(r), dir, "leaderboardHistory", now, leaderboardKey)
+		compactSeries(d, Reset(r), dir, "statsHistory", now, statsKey)
+		// hourly rollups are already one file per hour so the hour pass only
+		// renames them, daily rollups are rewritten all day and never merged
+		compactSeries(d, Reset(r), dir, rollupBasename("agents", TierHour), now, agentRollupKey)
+		compactSeries(d, Reset(r), dir, rollupBasename("construction", TierHour), now, constructionRollupKey)
+	}
+	logging.Debug("compaction complete, took", time.Since(start))
+}
+
+type snapshotFile struct {
+	name  string
+	level string
+	ts    int64
+	size  int64
+}
+
+// parseSnapshotName splits <basename>-[level-]<ts>.gob.zst
+func parseSnapshotName(basename, name string) (snapshotFile, bool) {
+	rest, ok := strings.CutPrefix(name, basename+"-")
+	if !ok {
+		return snapshotFile{}, false
+	}
+	rest, ok = strings.CutSuffix(rest, ".gob.zst")
+	if !ok {
+		return snapshotFile{}, false
+	}
+	level := levelTick
+	if l, ts, found := strings.Cut(rest, "-"); found {
+		level, rest = l, ts
+	}
+	if level != levelTick && level != levelHour && level != levelDay {
+		return snapshotFile{}, false
+	}
+	ts, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil {
+		return snapshotFile{}, false
+	}
+	return snapshotFile{name: name, level: level, ts: ts}, true
+}
+
+// snapshotSpan is how far past its timestamp a file's records can go
+func snapshotSpan(level string) int64 {
+	switch level {
+	case levelHour:
+		return 3600 - 1
+	case levelDay:
+		return 86400 - 1
+	}
+	return 0
+}
+
+func listSnapshots(dir, basename string) ([]snapshotFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	res := []snapshotFile{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		f, ok := parseSnapshotName(basename, e.Name())
+		if !ok {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			f.size = info.Size()
+		}
+		res = append(res, f)
+	}
+	return res, nil
+}
+
+// compactSeries runs the hourly pass then the daily pass over one basename
+func compactSeries[T any](d *DiskStore, thisReset Reset, dir, basename string, now time.Time, key func(T) string) {
+	hourCutoff := now.Unix() - now.Unix()%3600
+	dayCutoff := now.Unix() - now.Unix()%86400
+
+	passes := []struct {
+		from, to string
+		span     int64
+		cutoff   int64
+	}{
+		{levelTick, levelHour, 3600, hourCutoff},
+		{levelHour, levelDay, 86400, dayCutoff},
+	}
+	for _, p := range passes {
+		files, err := listSnapshots(dir, basename)
+		if err != nil {
+			logging.Error("compaction: failed to list", dir, err)
+			return
+		}
+		groups := make(map[int64][]snapshotFile)
+		existing := make(map[int64]snapshotFile)
+		for _, f := range files {
+			bucket := f.ts - f.ts%p.span
+			if bucket+p.span > p.cutoff {
+				continue
+			}
+			switch f.level {
+			case p.from:
+				groups[bucket] = append(groups[bucket], f)
+			case p.to:
+				existing[bucket] = f
+			}
+		}
+		for bucket, inputs := range groups {
+			if seg, ok := existing[bucket]; ok {
+				inputs = append(inputs, seg)
+			}
+			if err := mergeSegment(d, thisReset, dir, basename, p.to, bucket, inputs, key); err != nil {
+				metrics.DatastoreCompactionErrors.Add(1)
+				logging.Error("compaction: failed to merge", basename, p.to, bucket, "in", dir, err)
+			}
+		}
+	}
+}
+
+// mergeSegment decodes inputs, writes them as a single segment and swaps it in
+func mergeSegment[T any](d *DiskStore, thisReset Reset, dir, basename, level string, ts int64, inputs []snapshotFile, key func(T) string) error {
+	seen := make(map[string]bool)
+	merged := []T{}
+	var inBytes int64
+	for _, f := range inputs {
+		var v []T
+		if err := decodeSnapshot(filepath.Join(dir, f.name), &v); err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+		for _, r := range v {
+			k := key(r)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			merged = append(merged, r)
+		}
+		inBytes += f.size
+	}
+
+	target := fmt.Sprintf("%s-%s-%d.gob.zst", basename, level, ts)
+	tmpGob, outBytes, err := writeTemp(dir, target, func(w io.Writer) error {
+		return encodeSnapshot(w, merged)
+	})
+	if err != nil {
+		return err
+	}
+	jsonTarget := strings.TrimSuffix(target, ".gob.zst") + ".json"
+	tmpJSON := ""
+	if d.writeJSON {
+		tmpJSON, _, err = writeTemp(dir, jsonTarget, func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(merged)
+		})
+		if err != nil {
+			os.Remove(tmpGob)
+			return err
+		}
+	}
+
+	d.filesMu.Lock()
+	err = os.Rename(tmpGob, filepath.Join(dir, target))
+	if err == nil && tmpJSON != "" {
+		err = os.Rename(tmpJSON, filepath.Join(dir, jsonTarget))
+	}
+	if err == nil {
+		d.cache.invalidate(cacheKey{thisReset, target})
+		for _, f := range inputs {
+			if f.name == target {
+				continue
+			}
+			d.cache.invalidate(cacheKey{thisReset, f.name})
+			fullPath := filepath.Join(dir, f.name)
+			if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+				logging.Error("compaction: failed to remove", fullPath, err)
+			}
+			jsonPath := strings.TrimSuffix(fullPath, ".gob.zst") + ".json"
+			if err := os.Remove(jsonPath); err != nil && !os.IsNotExist(err) {
+				logging.Error("compaction: failed to remove", jsonPath, err)
+			}
+		}
+	}
+	d.filesMu.Unlock()
+	if err != nil {
+		os.Remove(tmpGob)
+		if tmpJSON != "" {
+			os.Remove(tmpJSON)
+		}
+		return err
+	}
+
+	metrics.DatastoreCompactionFilesMerged.Add(int64(len(inputs)))
+	metrics.DatastoreCompactionBytesSaved.Add(inBytes - outBytes)
+	metrics.DatastoreWrites.Add(1)
+	return nil
+}
+
+func decodeSnapshot(filename string, v any) error {
+	b, err := readSnapshotBytes(filename)
+	if err != nil {
+		return err
+	}
+	metrics.DatastoreReads.Add(1)
+	return decodePayload(filepath.Base(filename), b, v)
+}
+
+// writeTemp writes a hidden temp file next to target and syncs it, the
+// caller renames it into place. Returns the temp path and its size
+func writeTemp(dir, target string, write func(io.Writer) error) (string, int64, error) {
+	tmp, err := os.CreateTemp(dir, "."+target+".*.tmp")
+	if err != nil {
+		return "", 0, err
+	}
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", 0, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", 0, err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", 0, err
+	}
+	metrics.DatastoreBytesWritten.Add(info.Size())
+	return tmp.Name(), info.Size(), nil
+}
+
+// countingReader adds whatever is read through it to DatastoreBytesRead
+type countingReader struct {
+	r io.Reader
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	metrics.DatastoreBytesRead.Add(int64(n))
+	return n, err
+}
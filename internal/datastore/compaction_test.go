@@ -0,0 +1,124 @@
+package datastore
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func snapshotNames(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestDiskStore_Compact(t *testing.T) {
+	d, err := NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	if err := d.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	agents := []PublicAgent{
+		{Symbol: "ALPHA", Credits: 175000, Headquarters: "X1-AB12-A1", ShipCount: 2},
+		{Symbol: "BRAVO", Credits: 250000, Headquarters: "X1-CD34-B2", ShipCount: 3},
+	}
+
+	now := time.Date(2026, 1, 10, 12, 30, 0, 0, time.UTC)
+	ticks := 0
+	// 23:00 on the 9th to 01:00 on the 10th, then the current hour
+	for ts := time.Date(2026, 1, 9, 23, 0, 0, 0, time.UTC); !ts.After(time.Date(2026, 1, 10, 1, 0, 0, 0, time.UTC)); ts = ts.Add(5 * time.Minute) {
+		if err := d.StoreAgents(agents, ts.Unix()); err != nil {
+			t.Fatalf("StoreAgents: %v", err)
+		}
+		ticks++
+	}
+	for ts := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC); ts.Before(now); ts = ts.Add(5 * time.Minute) {
+		if err := d.StoreAgents(agents, ts.Unix()); err != nil {
+			t.Fatalf("StoreAgents: %v", err)
+		}
+		ticks++
+	}
+
+	d.Compact(now)
+	d.Compact(now)
+
+	dir := filepath.Join(d.DataPath(), "2026-01-04")
+	want := []string{
+		"agents.gob.zst",
+		"agentsRollup1d-1767916800.gob.zst",
+		"agentsRollup1d-1768003200.gob.zst",
+		"agentsRollup1h-1768046400.gob.zst",
+		"agentsRollup1h-day-1767916800.gob.zst",
+		"agentsRollup1h-hour-1768003200.gob.zst",
+		"agentsRollup1h-hour-1768006800.gob.zst",
+		"agentsStatus-1768046400.gob.zst",
+		"agentsStatus-1768046700.gob.zst",
+		"agentsStatus-1768047000.gob.zst",
+		"agentsStatus-1768047300.gob.zst",
+		"agentsStatus-1768047600.gob.zst",
+		"agentsStatus-1768047900.gob.zst",
+		"agentsStatus-day-1767916800.gob.zst",
+		"agentsStatus-hour-1768003200.gob.zst",
+		"agentsStatus-hour-1768006800.gob.zst",
+	}
+	got := snapshotNames(t, dir)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected files after compaction\n got: %v\nwant: %v", got, want)
+	}
+
+	hist, err := d.GetAgentHistory("2026-01-04", 0, 0)
+	if err != nil {
+		t.Fatalf("GetAgentHistory: %v", err)
+	}
+	if len(hist) != ticks*len(agents) {
+		t.Fatalf("expected %d records, got %d", ticks*len(agents), len(hist))
+	}
+}
+
+func TestDiskStore_CompactDropsDuplicates(t *testing.T) {
+	d, err := NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	if err := d.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	agents := []PublicAgent{{Symbol: "ALPHA", Credits: 175000, Headquarters: "X1-AB12-A1", ShipCount: 2}}
+	ts := time.Date(2026, 1, 9, 10, 5, 0, 0, time.UTC).Unix()
+	if err := d.StoreAgents(agents, ts); err != nil {
+		t.Fatalf("StoreAgents: %v", err)
+	}
+	tick := filepath.Join(d.DataPath(), "2026-01-04", "agentsStatus-1767953100.gob.zst")
+	saved, err := os.ReadFile(tick)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	now := time.Date(2026, 1, 9, 12, 0, 0, 0, time.UTC)
+	d.Compact(now)
+	// a crash between the rename and the removal leaves the input behind
+	if err := os.WriteFile(tick, saved, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	d.Compact(now)
+
+	hist, err := d.GetAgentHistory("2026-01-04", 0, 0)
+	if err != nil {
+		t.Fatalf("GetAgentHistory: %v", err)
+	}
+	if len(hist) != 1 {
+		t.Fatalf("expected duplicate to be dropped, got %d records", len(hist))
+	}
+}
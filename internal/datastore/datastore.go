@@ -2,137 +2,398 @@ package datastore
 
 import (
 	"bytes"
-	"encoding/gob"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/klauspost/compress/zstd"
+	"github.com/papaburgs/fluffy-robot/internal/config"
 	"github.com/papaburgs/fluffy-robot/internal/logging"
 	"github.com/papaburgs/fluffy-robot/internal/metrics"
 )
 
-var path = "./"
-var currentReset Reset = ""
-var resetPath = ""
-var writeJSON = false
+// DiskStore writes every snapshot as a gob+zstd file in a directory per reset
+type DiskStore struct {
+	path      string
+	writeJSON bool
 
-func Init() {
-	env, ok := os.LookupEnv("FLUFFY_STORAGE_PATH")
-	if ok {
-		path = env
-	}
-	err := os.MkdirAll(path, 0755)
-	if err != nil {
+	mu           sync.RWMutex
+	currentReset Reset
+	resetPath    string
+
+	// filesMu guards the set of files in the reset directories. Compaction
+	// swaps files in and out under the write lock, readers list and open
+	// under the read lock, so a reader sees either the old or the new set
+	filesMu sync.RWMutex
+	// compactMu keeps compaction runs from overlapping
+	compactMu sync.Mutex
+	// writeMu serialises writers, so an import and a collector tick never
+	// both read, merge and rewrite the same rollup or history file
+	writeMu sync.Mutex
+
+	cache *fileCache
+}
+
+func NewDiskStore(path string, writeJSON bool) (*DiskStore, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
 		logging.Error("Failed to create directory at", path)
-		os.Exit(1)
+		return nil, err
 	}
-	env, ok = os.LookupEnv("FLUFFY_WRITE_JSON")
-	if ok {
-		for _, a := range []string{"yes", "y", "true"} {
-			if strings.ToLower(env) == a {
-				writeJSON = true
-			}
+	return &DiskStore{
+		path:      path,
+		writeJSON: writeJSON,
+		cache:     newFileCache(defaultCacheBytes, defaultCacheDuration),
+	}, nil
+}
+
+// ConfigureCache sets the decoded file cache limits and empties it.
+// maxBytes of 0 disables the cache, ttl of 0 keeps entries until evicted
+func (d *DiskStore) ConfigureCache(maxBytes int64, ttl time.Duration) {
+	d.cache.purge(maxBytes, ttl)
+}
+
+// NewStore opens the backend named by cfg.Backend, "disk" (the default) or
+// "bolt"
+func NewStore(cfg config.Datastore) (Store, error) {
+	switch strings.ToLower(cfg.Backend) {
+	case "", "disk":
+		return OpenDiskStore(cfg)
+	case "bolt":
+		b, err := NewBoltStore(cfg.Path, cfg.WriteJSON)
+		if err != nil {
+			return nil, err
 		}
+		b.ConfigureCache(cfg.CacheSizeMB<<20, cfg.CacheDuration)
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unknown datastore backend %q", cfg.Backend)
 	}
+}
 
+// OpenDiskStore opens the disk store at cfg.Path with cfg's cache settings
+func OpenDiskStore(cfg config.Datastore) (*DiskStore, error) {
+	d, err := NewDiskStore(cfg.Path, cfg.WriteJSON)
+	if err != nil {
+		return nil, err
+	}
+	d.ConfigureCache(cfg.CacheSizeMB<<20, cfg.CacheDuration)
+	return d, nil
 }
 
-func UpdateReset(r Reset) {
-	currentReset = r
-	resetPath = filepath.Join(path, string(currentReset))
+func (d *DiskStore) UpdateReset(r Reset) error {
+	resetPath := filepath.Join(d.path, string(r))
 	err := os.MkdirAll(resetPath, 0755)
 	if err != nil {
-		logging.Error("Failed to create directory:", path)
-		os.Exit(1)
+		logging.Error("Failed to create directory:", resetPath)
+		return err
 	}
+	d.mu.Lock()
+	d.currentReset = r
+	d.resetPath = resetPath
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *DiskStore) CurrentReset() Reset {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.currentReset
 }
 
-func writeData(basename string, timestamp int64, v any) error {
-	var filename string
-	if writeJSON {
-		if timestamp > 0 {
-			filename = filepath.Join(resetPath, fmt.Sprintf("%s-%v.json", basename, timestamp))
-		} else {
-			filename = filepath.Join(resetPath, fmt.Sprintf("%s.json", basename))
+func (d *DiskStore) currentPath() string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.resetPath
+}
+
+func (d *DiskStore) AllResets() []string {
+	resets := []string{}
+	files, err := os.ReadDir(d.path)
+	if err != nil {
+		logging.Error("Failed to read resets directory", err)
+		return resets
+	}
+
+	for _, f := range files {
+		if f.IsDir() {
+			resets = append(resets, f.Name())
 		}
-		jsonFile, err := os.Create(filename)
+	}
+	sort.Slice(resets, func(i, j int) bool {
+		return resets[i] > resets[j]
+	})
+	return resets
+}
+
+// writeData writes v as <basename>[-<timestamp>].gob.zst, and .json when
+// enabled, in the current reset directory. Each file goes through a temp
+// file so a crash part way leaves the previous version in place
+func (d *DiskStore) writeData(basename string, timestamp int64, v any) error {
+	name := basename
+	if timestamp > 0 {
+		name = fmt.Sprintf("%s-%v", basename, timestamp)
+	}
+	return d.writeNamed(d.CurrentReset(), name, v)
+}
+
+// writeNamed is writeData for any reset and file name, without the extension
+func (d *DiskStore) writeNamed(thisReset Reset, name string, v any) error {
+	if d.writeJSON {
+		err := d.replaceFile(thisReset, name+".json", func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(v)
+		})
 		if err != nil {
 			return err
 		}
-		defer jsonFile.Close()
-		enc := json.NewEncoder(jsonFile)
-		enc.SetIndent("", "  ")
-		if err := enc.Encode(v); err != nil {
-			return err
-		}
-		metrics.DatastoreWrites.Add(1)
 	}
-	if timestamp > 0 {
-		filename = filepath.Join(resetPath, fmt.Sprintf("%s-%v.gob.zst", basename, timestamp))
-	} else {
-		filename = filepath.Join(resetPath, fmt.Sprintf("%s.gob.zst", basename))
-	}
-	gobFile, err := os.Create(filename)
+	return d.writeSnapshot(thisReset, name+".gob.zst", v)
+}
+
+// writeSnapshot writes v to target in thisReset's directory through a
+// temp file, so readers see the old file or the new one and never part of it
+func (d *DiskStore) writeSnapshot(thisReset Reset, target string, v any) error {
+	return d.replaceFile(thisReset, target, func(w io.Writer) error {
+		return encodeSnapshot(w, v)
+	})
+}
+
+// replaceFile writes target with write into a synced temp file and renames
+// it over the old one, then syncs the directory so the rename is durable
+func (d *DiskStore) replaceFile(thisReset Reset, target string, write func(io.Writer) error) error {
+	dir := filepath.Join(d.path, string(thisReset))
+	tmp, _, err := writeTemp(dir, target, write)
 	if err != nil {
 		return err
 	}
-	defer gobFile.Close()
-	encoder, err := zstd.NewWriter(gobFile)
+	d.filesMu.Lock()
+	err = os.Rename(tmp, filepath.Join(dir, target))
+	d.cache.invalidate(cacheKey{thisReset, target})
+	d.filesMu.Unlock()
 	if err != nil {
-		logging.Error("encoder Newwriter error:", err)
+		os.Remove(tmp)
 		return err
 	}
-	defer encoder.Close()
-	gobEnc := gob.NewEncoder(encoder)
-	if err := gobEnc.Encode(v); err != nil {
-		return err
+	if err := syncDir(dir); err != nil {
+		logging.Warn("failed to sync directory", dir, err)
 	}
-	encoder.Close()
 	metrics.DatastoreWrites.Add(1)
 	return nil
 }
 
-func readData(prefix string, thisReset Reset) (map[string]*bytes.Buffer, error) {
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// readData returns the decompressed contents of every file in the reset
+// directory starting with prefix, and the cache generation of each taken
+// when it was opened. Files for which skip returns true are left out, skip
+// may be nil
+func (d *DiskStore) readData(prefix string, thisReset Reset, skip func(name string) bool) (map[string]*bytes.Buffer, map[string]uint64, error) {
 	res := make(map[string]*bytes.Buffer)
+	gens := make(map[string]uint64)
 
+	resetPath := d.currentPath()
 	thisPath := resetPath
 	if thisReset != "" {
-		thisPath = filepath.Join(path, string(thisReset))
+		thisPath = filepath.Join(d.path, string(thisReset))
 	}
+
+	// open everything under the lock, an open file survives being removed
+	// by compaction so decoding can happen after it is released
+	opened := make(map[string]*os.File)
+	d.filesMu.RLock()
 	files, err := os.ReadDir(thisPath)
 	if err != nil {
-		return res, err
+		d.filesMu.RUnlock()
+		return res, gens, err
 	}
-
 	for _, f := range files {
 		if !f.IsDir() && strings.HasPrefix(f.Name(), prefix) && strings.HasSuffix(f.Name(), ".gob.zst") {
-			file, err := os.Open(filepath.Join(resetPath, f.Name()))
+			if skip != nil && skip(f.Name()) {
+				continue
+			}
+			file, err := os.Open(filepath.Join(thisPath, f.Name()))
 			if err != nil {
+				d.filesMu.RUnlock()
 				logging.Error("Error opening file:", f.Name(), err)
-				return res, err
+				for _, o := range opened {
+					o.Close()
+				}
+				return res, gens, err
 			}
-			defer file.Close()
+			opened[f.Name()] = file
+			gens[f.Name()] = d.cache.generation(cacheKey{thisReset, f.Name()})
+		}
+	}
+	d.filesMu.RUnlock()
 
-			decoder, err := zstd.NewReader(file)
-			if err != nil {
-				logging.Error("decoder error:", f.Name(), err)
-				return res, err
+	for name, file := range opened {
+		defer file.Close()
+
+		decoder, err := zstd.NewReader(countingReader{file})
+		if err != nil {
+			logging.Error("decoder error:", name, err)
+			return res, gens, err
+		}
+		defer decoder.Close()
+		b := new(bytes.Buffer)
+		_, err = decoder.WriteTo(b)
+		if err != nil {
+			logging.Error("decode to writer error:", err, " filename: ", name)
+			return res, gens, fmt.Errorf("decompressing %s: %w", name, err)
+		}
+		res[name] = b
+		metrics.DatastoreReads.Add(1)
+	}
+	return res, gens, nil
+}
+
+// readDecoded is readData followed by gob decoding into T, keyed by
+// filename. Decoded files are served from and added to the cache, so the
+// values are shared and must not be modified by the caller
+func readDecoded[T any](d *DiskStore, prefix string, thisReset Reset) (map[string]T, error) {
+	return decodeFiles[T](d, prefix, thisReset, true)
+}
+
+// readUncached is readDecoded straight from disk, for read-modify-write
+// cycles that must not merge into a value cached before the last write
+func readUncached[T any](d *DiskStore, prefix string, thisReset Reset) (map[string]T, error) {
+	return decodeFiles[T](d, prefix, thisReset, false)
+}
+
+func decodeFiles[T any](d *DiskStore, prefix string, thisReset Reset, cached bool) (map[string]T, error) {
+	if thisReset == "" {
+		thisReset = d.CurrentReset()
+	}
+	res := make(map[string]T)
+	var skip func(name string) bool
+	if cached {
+		skip = func(name string) bool {
+			v, ok := d.cache.get(cacheKey{thisReset, name})
+			if ok {
+				res[name] = v.(T)
 			}
-			defer decoder.Close()
-			b := new(bytes.Buffer)
-			_, err = decoder.WriteTo(b)
+			return ok
+		}
+	}
+	m, gens, err := d.readData(prefix, thisReset, skip)
+	if err != nil {
+		return res, err
+	}
+	for name, b := range m {
+		size := int64(b.Len())
+		var v T
+		if err := decodePayload(name, b.Bytes(), &v); err != nil {
+			logging.Error("error decoding gob:", name, err)
+			return res, err
+		}
+		res[name] = v
+		if cached {
+			d.cache.add(cacheKey{thisReset, name}, v, size, gens[name])
+		}
+	}
+	m = nil
+	return res, nil
+}
+
+// walkSeries calls fn with every record of the basename history files in
+// [start, end], oldest first. Files are decoded a few at a time instead of
+// all at once: those whose time ranges overlap, usually just one, are merged
+// and sorted together. Nothing goes through the cache
+func walkSeries[T any](d *DiskStore, thisReset Reset, basename string, start, end int64, stamp func(T) int64, fn func(T) error) error {
+	if thisReset == "" {
+		thisReset = d.CurrentReset()
+	}
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	dir := filepath.Join(d.path, string(thisReset))
+
+	type openSnapshot struct {
+		snapshotFile
+		file *os.File
+	}
+	// open under the lock as readData does, an open file survives compaction
+	var opened []openSnapshot
+	d.filesMu.RLock()
+	files, err := listSnapshots(dir, basename)
+	if err != nil {
+		d.filesMu.RUnlock()
+		return err
+	}
+	for _, f := range files {
+		if f.ts > end || f.ts+snapshotSpan(f.level) < start {
+			continue
+		}
+		file, err := os.Open(filepath.Join(dir, f.name))
+		if err != nil {
+			d.filesMu.RUnlock()
+			for _, o := range opened {
+				o.file.Close()
+			}
+			return err
+		}
+		opened = append(opened, openSnapshot{f, file})
+	}
+	d.filesMu.RUnlock()
+
+	next := 0
+	defer func() {
+		for _, o := range opened[next:] {
+			o.file.Close()
+		}
+	}()
+	sort.Slice(opened, func(i, j int) bool {
+		return opened[i].ts < opened[j].ts
+	})
+	for next < len(opened) {
+		group := next + 1
+		last := opened[next].ts + snapshotSpan(opened[next].level)
+		for ; group < len(opened) && opened[group].ts <= last; group++ {
+			last = max(last, opened[group].ts+snapshotSpan(opened[group].level))
+		}
+		var records []T
+		for ; next < group; next++ {
+			o := opened[next]
+			b, err := decompressSnapshot(countingReader{o.file})
+			o.file.Close()
 			if err != nil {
-				logging.Error("decode to writer error:", err, " filename: ", f.Name())
+				logging.Error("decode to writer error:", err, " filename: ", o.name)
 				continue
 			}
-			res[f.Name()] = b
 			metrics.DatastoreReads.Add(1)
+			var v []T
+			if err := decodePayload(o.name, b, &v); err != nil {
+				logging.Error("error decoding gob:", o.name, err)
+				return err
+			}
+			records = append(records, v...)
+		}
+		sort.SliceStable(records, func(i, j int) bool {
+			return stamp(records[i]) < stamp(records[j])
+		})
+		for _, r := range records {
+			if ts := stamp(r); ts >= start && ts <= end {
+				if err := fn(r); err != nil {
+					return err
+				}
+			}
 		}
 	}
-	return res, err
+	return nil
 }
 
 func SystemFromWaypoint(w string) string {
@@ -140,6 +401,7 @@ func SystemFromWaypoint(w string) string {
 	return fmt.Sprintf("%s-%s", split[0], split[1])
 }
 
-func DataPath() string {
-	return path
+// DataPath is the root directory holding one subdirectory per reset
+func (d *DiskStore) DataPath() string {
+	return d.path
 }
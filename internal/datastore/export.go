@@ -0,0 +1,180 @@
+package datastore
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Exporter is implemented by stores that can write an archive Import reads
+// back
+type Exporter interface {
+	Export(w io.Writer) error
+}
+
+// Export writes the data directory to w as a .tar.gz
+func (d *DiskStore) Export(w io.Writer) error {
+	return d.exportArchive(w, func(string) bool { return false }, func(*tar.Writer) error { return nil })
+}
+
+// Export writes the files of the embedded DiskStore and, in place of the
+// database file, the history rows as the snapshot files a DiskStore keeps,
+// one per reset, series and day. The rows are read in one transaction so
+// the archive is a consistent copy of them
+func (b *BoltStore) Export(w io.Writer) error {
+	// history files left from before the database are in it already
+	skip := func(rel string) bool {
+		if rel == boltFilename {
+			return true
+		}
+		k, ok := kindForFile(path.Base(rel))
+		return ok && path.Dir(rel) != "." && isHistoryKind(k.name)
+	}
+	return b.exportArchive(w, skip, func(tw *tar.Writer) error {
+		return b.db.View(func(tx *bolt.Tx) error {
+			if err := exportRows(tx, tw, agentStatusBucket, "agentsStatus", decodeAgentStatus, func(a AgentStatus) int64 { return a.Timestamp }); err != nil {
+				return err
+			}
+			if err := exportRows(tx, tw, constructionBucket, "construction", decodeConstruction, func(c JGConstruction) int64 { return c.Timestamp }); err != nil {
+				return err
+			}
+			for _, tier := range rollupTiers {
+				basename := rollupBasename("agents", tier)
+				if err := exportRows(tx, tw, rollupBucket("agents", tier), basename, decodeAgentRollup, func(a AgentRollup) int64 { return a.Timestamp }); err != nil {
+					return err
+				}
+				basename = rollupBasename("construction", tier)
+				if err := exportRows(tx, tw, rollupBucket("construction", tier), basename, decodeConstructionRollup, func(c ConstructionRollup) int64 { return c.Timestamp }); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// isHistoryKind is true for the series a BoltStore keeps in the database
+func isHistoryKind(name string) bool {
+	switch name {
+	case "agentStatus", "construction", "agentRollup", "constructionRollup":
+		return true
+	}
+	return false
+}
+
+// exportArchive tars every file under the data directory that skip does
+// not match, by its slash separated relative path, then whatever extra adds.
+// Compaction and retention wait so no file goes away part way through
+func (d *DiskStore) exportArchive(w io.Writer, skip func(rel string) bool, extra func(tw *tar.Writer) error) error {
+	d.compactMu.Lock()
+	defer d.compactMu.Unlock()
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	err := filepath.Walk(d.path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == d.path {
+			return nil
+		}
+		rel, err := filepath.Rel(d.path, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if skip(rel) {
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, info.Name())
+		if err != nil {
+			return err
+		}
+		header.Name = rel
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := extra(tw); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// exportRows writes the rows of every reset in bucket as
+// <reset>/<basename>-<day>.gob.zst, one file per day
+func exportRows[T any](tx *bolt.Tx, tw *tar.Writer, bucket []byte, basename string, decode func(symbol string, k, v []byte) (T, error), stamp func(T) int64) error {
+	root := tx.Bucket(bucket)
+	return root.ForEachBucket(func(reset []byte) error {
+		resetBucket := root.Bucket(reset)
+		days := make(map[int64][]T)
+		err := resetBucket.ForEachBucket(func(symbol []byte) error {
+			return resetBucket.Bucket(symbol).ForEach(func(k, v []byte) error {
+				r, err := decode(string(symbol), k, v)
+				if err != nil {
+					return err
+				}
+				day := TierDay.bucketStart(stamp(r))
+				days[day] = append(days[day], r)
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+		order := make([]int64, 0, len(days))
+		for day := range days {
+			order = append(order, day)
+		}
+		sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+		for _, day := range order {
+			rows := days[day]
+			sort.SliceStable(rows, func(i, j int) bool { return stamp(rows[i]) < stamp(rows[j]) })
+			name := path.Join(string(reset), fmt.Sprintf("%s-%d.gob.zst", basename, day))
+			if err := writeTarSnapshot(tw, name, rows); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func writeTarSnapshot(tw *tar.Writer, name string, v any) error {
+	var buf bytes.Buffer
+	if err := encodeSnapshot(&buf, v); err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(buf.Len()), ModTime: time.Now(), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(buf.Bytes())
+	return err
+}
@@ -1,26 +1,32 @@
 package datastore
 
 import (
-	"encoding/gob"
 	"fmt"
 
 	"github.com/papaburgs/fluffy-robot/internal/logging"
 )
 
-func StoreFactions(fac []Faction) error {
-	if currentReset == "" {
+// factionRecords stamps each faction with the reset it was collected in
+func factionRecords(fac []Faction, r Reset) []Faction {
+	if r == "" {
 		logging.Error("Current Reset is empty")
 	}
 	for i, k := range fac {
-		k.Reset = currentReset
+		k.Reset = r
 		fac[i] = k
 	}
-	return writeData("factions", 0, fac)
+	return fac
 }
 
-func GetFactions(thisReset Reset) ([]Faction, error) {
+func (d *DiskStore) StoreFactions(fac []Faction) error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	return d.writeData("factions", 0, factionRecords(fac, d.CurrentReset()))
+}
+
+func (d *DiskStore) GetFactions(thisReset Reset) ([]Faction, error) {
 	res := []Faction{}
-	m, err := readData("factions.", thisReset)
+	m, err := readDecoded[[]Faction](d, "factions.", thisReset)
 	if err != nil {
 		logging.Error("Failed to read factions file:", err)
 		return res, err
@@ -30,12 +36,8 @@ func GetFactions(thisReset Reset) ([]Faction, error) {
 		return res, fmt.Errorf("more than one file returned")
 	}
 
-	for _, b := range m {
-		gobDec := gob.NewDecoder(b)
-		if err := gobDec.Decode(&res); err != nil {
-			logging.Error("error decoding gob:", err)
-			return res, err
-		}
+	for _, v := range m {
+		res = append(res, v...)
 	}
 	m = nil
 	return res, nil
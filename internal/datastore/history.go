@@ -0,0 +1,137 @@
+package datastore
+
+import "time"
+
+// ResetSummary is how one agent did over one reset
+type ResetSummary struct {
+	Reset        Reset
+	Found        bool
+	Faction      string
+	Headquarters string
+	Credits      int64
+	PeakCredits  int64
+	Ships        int64
+	// CreditsRank is the position on the final credits leaderboard, 0 when
+	// the agent did not make it
+	CreditsRank int
+	Jumpgate    ConstructionStatus
+	FirstSeen   int64
+	LastSeen    int64
+	// Days is the agent's daily rollup for the reset
+	Days []AgentRollup
+}
+
+// ResetStart is midnight UTC on the reset date, or zero for a reset name
+// that is not a date
+func ResetStart(r Reset) time.Time {
+	t, err := time.Parse(time.DateOnly, string(r))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// AgentAcrossResets summarises symbol in every reset the store has data
+// for, newest first. Resets the agent did not play are included with Found
+// unset so callers can show the gaps
+func AgentAcrossResets(s Store, symbol string) []ResetSummary {
+	res := []ResetSummary{}
+	for _, r := range s.AllResets() {
+		thisReset := Reset(r)
+		sum := ResetSummary{Reset: thisReset}
+
+		agents, _ := s.GetAgentList(thisReset)
+		var agent Agent
+		for _, a := range agents {
+			if a.Symbol == symbol {
+				agent = a
+				sum.Found = true
+				break
+			}
+		}
+		agents = nil
+		if !sum.Found {
+			res = append(res, sum)
+			continue
+		}
+		sum.Faction = agent.Faction
+		sum.Headquarters = agent.Headquarters
+		sum.Credits = agent.Credits
+
+		days, _ := s.GetAgentRollups(thisReset, []string{symbol}, TierDay, 0, 0)
+		sum.Days = days
+		for _, d := range days {
+			sum.PeakCredits = max(sum.PeakCredits, d.Credits.Max)
+			if sum.FirstSeen == 0 {
+				sum.FirstSeen = d.Timestamp
+			}
+			sum.LastSeen = d.LastTimestamp
+			sum.Ships = d.Ships.Last
+		}
+		sum.PeakCredits = max(sum.PeakCredits, sum.Credits)
+
+		credits, _, _ := s.GetLeaderboard(thisReset)
+		for i, e := range credits {
+			if e.Symbol == symbol {
+				sum.CreditsRank = i + 1
+				break
+			}
+		}
+		if jg, ok := GetJumpgates(s, thisReset)[agent.System]; ok {
+			sum.Jumpgate = jg.Status
+		}
+		res = append(res, sum)
+	}
+	return res
+}
+
+// AgentDay is how an agent did over one UTC day of a reset
+type AgentDay struct {
+	// Day is midnight UTC
+	Day           int64
+	Credits       int64
+	CreditsGained int64
+	Ships         int64
+	ShipsAdded    int64
+	// Hours is the time the gains were made over, less than 24 on the
+	// first and the current day
+	Hours float64
+}
+
+// CreditsPerHour is CreditsGained spread over Hours
+func (d AgentDay) CreditsPerHour() float64 {
+	if d.Hours <= 0 {
+		return 0
+	}
+	return float64(d.CreditsGained) / d.Hours
+}
+
+// AgentDays measures every day of symbol's reset from the end of the day
+// before. The first day is measured from the agent's first record, or from
+// the day's lowest values when the raw history has been trimmed
+func AgentDays(s Store, thisReset Reset, symbol string) ([]AgentDay, error) {
+	days, err := s.GetAgentRollups(thisReset, []string{symbol}, TierDay, 0, 0)
+	if err != nil || len(days) == 0 {
+		return []AgentDay{}, err
+	}
+	first := days[0]
+	credits, ships, from := first.Credits.Min, first.Ships.Min, first.Timestamp
+	hist, _ := s.GetAgentHistoryFor(thisReset, []string{symbol}, first.Timestamp, first.LastTimestamp)
+	if len(hist) > 0 {
+		credits, ships, from = hist[0].Credits, hist[0].Ships, hist[0].Timestamp
+	}
+
+	res := make([]AgentDay, 0, len(days))
+	for _, d := range days {
+		res = append(res, AgentDay{
+			Day:           d.Timestamp,
+			Credits:       d.Credits.Last,
+			CreditsGained: d.Credits.Last - credits,
+			Ships:         d.Ships.Last,
+			ShipsAdded:    d.Ships.Last - ships,
+			Hours:         float64(d.LastTimestamp-from) / 3600,
+		})
+		credits, ships, from = d.Credits.Last, d.Ships.Last, d.LastTimestamp
+	}
+	return res, nil
+}
@@ -0,0 +1,604 @@
+package datastore
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/papaburgs/fluffy-robot/internal/logging"
+)
+
+// Import loads an archive made by /export, a .tar.gz of the storage
+// directory, back into a store. Every snapshot in it is decoded first and
+// nothing is written unless all of them decode as the kind their name says.
+//
+// Snapshots that are replaced as a whole (agents, jumpgates, stats,
+// leaderboard, factions) are only written when missing locally or when the
+// archived copy is newer. History (agent status, construction and their
+// rollups, leaderboard and stats snapshots) is merged record by record: records we do not have are added,
+// records we have are kept even if the archive disagrees, which is reported
+// as a conflict. Rollups only fill buckets we have no row for and are then
+// brought up to date with the imported records.
+
+// ErrInvalidArchive is returned, with nothing imported, when the archive
+// cannot be read or any snapshot in it fails to decode
+var ErrInvalidArchive = errors.New("invalid archive")
+
+// Importer is implemented by stores that can load an export archive
+type Importer interface {
+	Import(r io.Reader, dryRun bool) (ImportReport, error)
+}
+
+type ImportProblem struct {
+	File   string `json:"file"`
+	Reason string `json:"reason"`
+}
+
+// ImportReport lists every file of the archive as <reset>/<name> under what
+// happened to it. In a dry run it is what would happen
+type ImportReport struct {
+	Imported  []string        `json:"imported"`
+	Unchanged []string        `json:"unchanged"`
+	Skipped   []ImportProblem `json:"skipped"`
+	Conflicts []ImportProblem `json:"conflicts"`
+	Invalid   []ImportProblem `json:"invalid"`
+}
+
+func newImportReport() ImportReport {
+	return ImportReport{
+		Imported:  []string{},
+		Unchanged: []string{},
+		Skipped:   []ImportProblem{},
+		Conflicts: []ImportProblem{},
+		Invalid:   []ImportProblem{},
+	}
+}
+
+type archivedFile struct {
+	reset   Reset
+	name    string
+	kind    snapshotKind
+	value   any
+	modTime time.Time
+}
+
+func (f archivedFile) path() string {
+	return path.Join(string(f.reset), f.name)
+}
+
+// importedHistory is what an import adds to a reset's history, rollups are
+// only the rows for buckets the store has none for
+type importedHistory struct {
+	status              []AgentStatus
+	constructions       []JGConstruction
+	agentRollups        map[Tier][]AgentRollup
+	constructionRollups map[Tier][]ConstructionRollup
+	leaderboards        []LeaderboardSnapshot
+	stats               []StatsPoint
+}
+
+func (h importedHistory) empty() bool {
+	n := len(h.status) + len(h.constructions) + len(h.leaderboards) + len(h.stats)
+	for _, rows := range h.agentRollups {
+		n += len(rows)
+	}
+	for _, rows := range h.constructionRollups {
+		n += len(rows)
+	}
+	return n == 0
+}
+
+// historyWriter is a store that history is imported into. DiskStore keeps
+// it in files and BoltStore in its database
+type historyWriter interface {
+	Store
+	writeHistory(thisReset Reset, h importedHistory) error
+}
+
+func (d *DiskStore) Import(r io.Reader, dryRun bool) (ImportReport, error) {
+	return d.importArchive(r, dryRun, d)
+}
+
+func (b *BoltStore) Import(r io.Reader, dryRun bool) (ImportReport, error) {
+	return b.DiskStore.importArchive(r, dryRun, b)
+}
+
+func (d *DiskStore) importArchive(r io.Reader, dryRun bool, hw historyWriter) (ImportReport, error) {
+	report := newImportReport()
+	resets, err := readArchive(r, &report)
+	if err != nil {
+		return report, err
+	}
+	if len(report.Invalid) > 0 {
+		return report, fmt.Errorf("%w: %d snapshots do not decode", ErrInvalidArchive, len(report.Invalid))
+	}
+
+	// the whole import is one read-modify-write of each reset, the collector
+	// waits for it rather than writing in between
+	d.compactMu.Lock()
+	defer d.compactMu.Unlock()
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	names := make([]string, 0, len(resets))
+	for r := range resets {
+		names = append(names, string(r))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		thisReset := Reset(name)
+		if !dryRun {
+			if err := os.MkdirAll(filepath.Join(d.path, name), 0755); err != nil {
+				return report, err
+			}
+		}
+		var history []archivedFile
+		for _, f := range resets[thisReset] {
+			switch f.kind.name {
+			case "agentStatus", "construction", "agentRollup", "constructionRollup", "leaderboardHistory", "statsHistory":
+				history = append(history, f)
+			default:
+				if err := d.importSnapshot(f, dryRun, &report); err != nil {
+					return report, err
+				}
+			}
+		}
+		if err := importHistory(hw, thisReset, history, dryRun, &report); err != nil {
+			return report, err
+		}
+	}
+	logging.Info("import: imported", len(report.Imported), "unchanged", len(report.Unchanged),
+		"conflicts", len(report.Conflicts), "skipped", len(report.Skipped), "dry run", dryRun)
+	return report, nil
+}
+
+// readArchive decodes every snapshot in the archive, grouped by reset.
+// Entries that are not snapshots in a reset directory are skipped and ones
+// that fail to decode are reported as invalid
+func readArchive(r io.Reader, report *ImportReport) (map[Reset][]archivedFile, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not a .tar.gz: %w", ErrInvalidArchive, err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	res := make(map[Reset][]archivedFile)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return res, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(hdr.Name)
+		dir, file := path.Split(name)
+		dir = strings.TrimSuffix(dir, "/")
+		switch {
+		case ResetStart(Reset(dir)).IsZero():
+			report.Skipped = append(report.Skipped, ImportProblem{name, "not in a reset directory"})
+			continue
+		case !strings.HasSuffix(file, ".gob.zst") || isTempFile(file):
+			report.Skipped = append(report.Skipped, ImportProblem{name, "not a snapshot"})
+			continue
+		}
+
+		f, err := decodeArchived(tr, file)
+		if err != nil {
+			report.Invalid = append(report.Invalid, ImportProblem{name, err.Error()})
+			continue
+		}
+		f.reset, f.modTime = Reset(dir), hdr.ModTime
+		res[f.reset] = append(res[f.reset], f)
+	}
+}
+
+func decodeArchived(r io.Reader, name string) (archivedFile, error) {
+	b, err := decompressSnapshot(r)
+	if err != nil {
+		return archivedFile{}, err
+	}
+	payload, k, _, err := upgradePayload(name, b)
+	if err != nil {
+		return archivedFile{}, err
+	}
+	if want, ok := kindForFile(name); !ok || want.name != k.name {
+		return archivedFile{}, fmt.Errorf("holds %s, not what its name says", k.name)
+	}
+	v := k.target()
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(v); err != nil {
+		return archivedFile{}, err
+	}
+	return archivedFile{name: name, kind: k, value: v}, nil
+}
+
+// importSnapshot writes a whole-file snapshot unless the local copy is the
+// same or newer. The file keeps the archived modification time so a later
+// import compares against when it was written, not when it was imported
+func (d *DiskStore) importSnapshot(f archivedFile, dryRun bool, report *ImportReport) error {
+	target := filepath.Join(d.path, string(f.reset), f.name)
+	if local, err := ReadSnapshotFile(target); err == nil {
+		if reflect.DeepEqual(local, f.value) {
+			report.Unchanged = append(report.Unchanged, f.path())
+			return nil
+		}
+		// tar rounds times to the second, so do we or a copy written in
+		// the same second would look newer than ours
+		if info, err := os.Stat(target); err == nil && !f.modTime.After(info.ModTime().Round(time.Second)) {
+			report.Conflicts = append(report.Conflicts, ImportProblem{f.path(), "the local copy is newer, kept it"})
+			return nil
+		}
+	}
+	report.Imported = append(report.Imported, f.path())
+	if dryRun {
+		return nil
+	}
+	if err := d.writeNamed(f.reset, strings.TrimSuffix(f.name, ".gob.zst"), f.value); err != nil {
+		return err
+	}
+	return os.Chtimes(target, f.modTime, f.modTime)
+}
+
+func statusKey(a AgentStatus) string {
+	return fmt.Sprintf("%s/%d", a.Symbol, a.Timestamp)
+}
+
+func constructionKey(c JGConstruction) string {
+	return fmt.Sprintf("%s/%d", c.Jumpgate, c.Timestamp)
+}
+
+// newRecords returns the records whose key is not in have, adding them to
+// it, and how many have a key that is there with a different value
+func newRecords[T any](records []T, have map[string]T, key func(T) string) ([]T, int) {
+	fresh := []T{}
+	differ := 0
+	for _, r := range records {
+		k := key(r)
+		if old, ok := have[k]; ok {
+			if !reflect.DeepEqual(old, r) {
+				differ++
+			}
+			continue
+		}
+		have[k] = r
+		fresh = append(fresh, r)
+	}
+	return fresh, differ
+}
+
+func keyed[T any](records []T, key func(T) string) map[string]T {
+	res := make(map[string]T, len(records))
+	for _, r := range records {
+		res[key(r)] = r
+	}
+	return res
+}
+
+// rollupFileTier is the tier of a rollup file of series
+func rollupFileTier(series, name string) Tier {
+	for _, tier := range rollupTiers {
+		if strings.HasPrefix(name, rollupBasename(series, tier)+"-") {
+			return tier
+		}
+	}
+	return TierRaw
+}
+
+func importHistory(hw historyWriter, thisReset Reset, files []archivedFile, dryRun bool, report *ImportReport) error {
+	if len(files) == 0 {
+		return nil
+	}
+	// a reset we have nothing for has no directory yet in a dry run
+	missing := func(err error) bool {
+		return err == nil || errors.Is(err, fs.ErrNotExist)
+	}
+	status, err := hw.GetAgentHistory(thisReset, 0, 0)
+	if !missing(err) {
+		return err
+	}
+	constructions, err := hw.GetConstructions(thisReset, 0, 0)
+	if !missing(err) {
+		return err
+	}
+	leaderboards, err := hw.GetLeaderboardHistory(thisReset, 0, 0)
+	if !missing(err) {
+		return err
+	}
+	haveStatus := keyed(status, statusKey)
+	haveConstructions := keyed(constructions, constructionKey)
+	stats, err := hw.GetStatsHistory(thisReset, 0, 0)
+	if !missing(err) {
+		return err
+	}
+	haveLeaderboards := keyed(leaderboards, leaderboardKey)
+	haveStats := keyed(stats, statsKey)
+	haveAgentRollups := make(map[Tier]map[string]AgentRollup)
+	haveConstructionRollups := make(map[Tier]map[string]ConstructionRollup)
+	for _, tier := range rollupTiers {
+		agents, err := hw.GetAgentRollups(thisReset, nil, tier, 0, 0)
+		if !missing(err) {
+			return err
+		}
+		haveAgentRollups[tier] = keyed(agents, agentRollupKey)
+		cons, err := hw.GetConstructionRollups(thisReset, nil, tier, 0, 0)
+		if !missing(err) {
+			return err
+		}
+		haveConstructionRollups[tier] = keyed(cons, constructionRollupKey)
+	}
+
+	h := importedHistory{
+		agentRollups:        make(map[Tier][]AgentRollup),
+		constructionRollups: make(map[Tier][]ConstructionRollup),
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+	for _, f := range files {
+		var fresh, differ int
+		switch f.kind.name {
+		case "agentStatus":
+			rows, n := newRecords(*f.value.(*[]AgentStatus), haveStatus, statusKey)
+			h.status = append(h.status, rows...)
+			fresh, differ = len(rows), n
+		case "construction":
+			rows, n := newRecords(*f.value.(*[]JGConstruction), haveConstructions, constructionKey)
+			h.constructions = append(h.constructions, rows...)
+			fresh, differ = len(rows), n
+		case "leaderboardHistory":
+			rows, n := newRecords(*f.value.(*[]LeaderboardSnapshot), haveLeaderboards, leaderboardKey)
+			h.leaderboards = append(h.leaderboards, rows...)
+			fresh, differ = len(rows), n
+		case "statsHistory":
+			rows, n := newRecords(*f.value.(*[]StatsPoint), haveStats, statsKey)
+			h.stats = append(h.stats, rows...)
+			fresh, differ = len(rows), n
+		case "agentRollup":
+			// rollups are derived, a differing row is not worth reporting
+			tier := rollupFileTier("agents", f.name)
+			rows, _ := newRecords(*f.value.(*[]AgentRollup), haveAgentRollups[tier], agentRollupKey)
+			h.agentRollups[tier] = append(h.agentRollups[tier], rows...)
+			fresh = len(rows)
+		case "constructionRollup":
+			tier := rollupFileTier("construction", f.name)
+			rows, _ := newRecords(*f.value.(*[]ConstructionRollup), haveConstructionRollups[tier], constructionRollupKey)
+			h.constructionRollups[tier] = append(h.constructionRollups[tier], rows...)
+			fresh = len(rows)
+		}
+		if fresh > 0 {
+			report.Imported = append(report.Imported, f.path())
+		} else if differ == 0 {
+			report.Unchanged = append(report.Unchanged, f.path())
+		}
+		if differ > 0 {
+			report.Conflicts = append(report.Conflicts, ImportProblem{f.path(), fmt.Sprintf("%d records differ from ours, kept ours", differ)})
+		}
+	}
+	if dryRun || h.empty() {
+		return nil
+	}
+	return hw.writeHistory(thisReset, h)
+}
+
+// writeHistory adds the records to the reset's files as if they had been
+// collected, and folds them into the rollup files next to the imported rows
+func (d *DiskStore) writeHistory(thisReset Reset, h importedHistory) error {
+	if err := upsertRows(d, thisReset, "agentsStatus", h.status, statusKey, func(a AgentStatus) int64 { return a.Timestamp }); err != nil {
+		return err
+	}
+	if err := upsertRows(d, thisReset, "construction", h.constructions, constructionKey, func(c JGConstruction) int64 { return c.Timestamp }); err != nil {
+		return err
+	}
+	if err := d.writeSnapshotHistory(thisReset, h); err != nil {
+		return err
+	}
+	agents, cons := d.agentRollupSeries(thisReset), d.constructionRollupSeries(thisReset)
+	for _, tier := range rollupTiers {
+		if err := agents.write(d, thisReset, tier, h.agentRollups[tier], h.status); err != nil {
+			return err
+		}
+		if err := cons.write(d, thisReset, tier, h.constructionRollups[tier], h.constructions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollupSeries is how writeHistory maintains the rollups R of records S
+type rollupSeries[R, S any] struct {
+	series string
+	key    func(R) string
+	// bucketKey is the key of the row a record is folded into
+	bucketKey func(S, Tier) string
+	merge     func([]R, []S, Tier) []R
+	history   func() ([]S, error)
+	stamp     func(R) int64
+}
+
+func (d *DiskStore) agentRollupSeries(thisReset Reset) rollupSeries[AgentRollup, AgentStatus] {
+	return rollupSeries[AgentRollup, AgentStatus]{
+		series: "agents",
+		key:    agentRollupKey,
+		bucketKey: func(s AgentStatus, tier Tier) string {
+			return agentRollupKey(AgentRollup{Symbol: s.Symbol, Timestamp: tier.bucketStart(s.Timestamp)})
+		},
+		merge: mergeAgentRollups,
+		history: func() ([]AgentStatus, error) {
+			return d.GetAgentHistory(thisReset, 0, 0)
+		},
+		stamp: func(a AgentRollup) int64 { return a.Timestamp },
+	}
+}
+
+func (d *DiskStore) constructionRollupSeries(thisReset Reset) rollupSeries[ConstructionRollup, JGConstruction] {
+	return rollupSeries[ConstructionRollup, JGConstruction]{
+		series: "construction",
+		key:    constructionRollupKey,
+		bucketKey: func(c JGConstruction, tier Tier) string {
+			return constructionRollupKey(ConstructionRollup{Jumpgate: c.Jumpgate, Timestamp: tier.bucketStart(c.Timestamp)})
+		},
+		merge: mergeConstructionRollups,
+		history: func() ([]JGConstruction, error) {
+			return d.GetConstructions(thisReset, 0, 0)
+		},
+		stamp: func(c ConstructionRollup) int64 { return c.Timestamp },
+	}
+}
+
+// backfill folds the whole raw history into the tier's rows. Folding is
+// idempotent, so rows already up to date come out the same and buckets with
+// no row, or one started part way through, are filled in
+func (rs rollupSeries[R, S]) backfill(d *DiskStore, thisReset Reset, tier Tier) error {
+	hist, err := rs.history()
+	if err != nil || len(hist) == 0 {
+		return err
+	}
+	basename := rollupBasename(rs.series, tier)
+	files, err := readUncached[[]R](d, basename+"-", thisReset)
+	if err != nil {
+		return err
+	}
+	var existing []R
+	for _, rows := range files {
+		existing = append(existing, rows...)
+	}
+	return upsertRows(d, thisReset, basename, rs.merge(existing, hist, tier), rs.key, rs.stamp)
+}
+
+// write stores the imported rows and every row the imported records land in
+func (rs rollupSeries[R, S]) write(d *DiskStore, thisReset Reset, tier Tier, imported []R, records []S) error {
+	basename := rollupBasename(rs.series, tier)
+	files, err := readUncached[[]R](d, basename+"-", thisReset)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		// the reset is from before rollups, reads roll up the raw history
+		// which now has the imported records. Only archived rows have to be
+		// written, together with everything else so no bucket goes missing
+		if len(imported) == 0 {
+			return nil
+		}
+		hist, err := rs.history()
+		if err != nil {
+			return err
+		}
+		return upsertRows(d, thisReset, basename, rs.merge(imported, hist, tier), rs.key, rs.stamp)
+	}
+
+	all := append([]R{}, imported...)
+	for _, rows := range files {
+		all = append(all, rows...)
+	}
+	touched := make(map[string]bool)
+	for _, r := range imported {
+		touched[rs.key(r)] = true
+	}
+	for _, r := range records {
+		touched[rs.bucketKey(r, tier)] = true
+	}
+	var rows []R
+	for _, r := range rs.merge(all, records, tier) {
+		if touched[rs.key(r)] {
+			rows = append(rows, r)
+		}
+	}
+	return upsertRows(d, thisReset, basename, rows, rs.key, rs.stamp)
+}
+
+// upsertRows writes rows into the reset's <basename>- files. A row replaces
+// the one with its key in whichever file holds it, rows with new keys go to
+// <basename>-<bucket> as if they had been written then, and compaction
+// merges them into segments as usual
+func upsertRows[T any](d *DiskStore, thisReset Reset, basename string, rows []T, key func(T) string, bucket func(T) int64) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	files, err := readUncached[[]T](d, basename+"-", thisReset)
+	if err != nil {
+		return err
+	}
+	where := make(map[string]string)
+	for name, v := range files {
+		for _, r := range v {
+			where[key(r)] = name
+		}
+	}
+	updates := make(map[string]map[string]T)
+	for _, r := range rows {
+		name, ok := where[key(r)]
+		if !ok {
+			name = fmt.Sprintf("%s-%d.gob.zst", basename, bucket(r))
+		}
+		if updates[name] == nil {
+			updates[name] = make(map[string]T)
+		}
+		updates[name][key(r)] = r
+	}
+	for name, put := range updates {
+		merged := make([]T, 0, len(files[name])+len(put))
+		for _, r := range files[name] {
+			if n, ok := put[key(r)]; ok {
+				r = n
+				delete(put, key(r))
+			}
+			merged = append(merged, r)
+		}
+		for _, r := range rows {
+			if n, ok := put[key(r)]; ok {
+				merged = append(merged, n)
+				delete(put, key(r))
+			}
+		}
+		if err := d.writeNamed(thisReset, strings.TrimSuffix(name, ".gob.zst"), merged); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSnapshotHistory adds the leaderboard and stats snapshots, which
+// every store keeps in files
+func (d *DiskStore) writeSnapshotHistory(thisReset Reset, h importedHistory) error {
+	if err := upsertRows(d, thisReset, "leaderboardHistory", h.leaderboards, leaderboardKey, func(s LeaderboardSnapshot) int64 { return s.Timestamp }); err != nil {
+		return err
+	}
+	return upsertRows(d, thisReset, "statsHistory", h.stats, statsKey, func(p StatsPoint) int64 { return p.Timestamp })
+}
+
+// writeHistory puts the records into the database, which folds them into
+// its rollups, after the rollup rows for buckets it had nothing for
+func (b *BoltStore) writeHistory(thisReset Reset, h importedHistory) error {
+	if err := b.writeSnapshotHistory(thisReset, h); err != nil {
+		return err
+	}
+	for tier, rows := range h.agentRollups {
+		if err := b.putAgentRollups(tier, thisReset, rows); err != nil {
+			return err
+		}
+	}
+	for tier, rows := range h.constructionRollups {
+		if err := b.putConstructionRollups(tier, thisReset, rows); err != nil {
+			return err
+		}
+	}
+	if err := b.putAgentStatus(thisReset, h.status); err != nil {
+		return err
+	}
+	return b.putConstructions(thisReset, h.constructions)
+}
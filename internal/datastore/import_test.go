@@ -0,0 +1,429 @@
+package datastore
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// archiveFiles builds a .tar.gz like /export does, extra is added as is
+func archiveFiles(t *testing.T, dir string, extra map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	add := func(name string, b []byte, modTime time.Time) {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(b)), ModTime: modTime, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("WriteHeader: %v", err)
+		}
+		if _, err := tw.Write(b); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		add(filepath.ToSlash(rel), b, info.ModTime())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walking %s: %v", dir, err)
+	}
+	for name, b := range extra {
+		add(name, b, time.Now())
+	}
+	tw.Close()
+	gw.Close()
+	return buf.Bytes()
+}
+
+// sourceArchive is a reset of two agents over two hours with stats
+func sourceArchive(t *testing.T, hour int64, extra map[string][]byte) []byte {
+	t.Helper()
+	src, err := NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	if err := src.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	for i := int64(0); i < 24; i++ {
+		agents := []PublicAgent{
+			{Symbol: "ALPHA", Credits: 1000 + i, Headquarters: "X1-AB12-A1", ShipCount: 2},
+			{Symbol: "BRAVO", Credits: 2000 + i, Headquarters: "X1-CD34-B2", ShipCount: 3},
+		}
+		if err := src.StoreAgents(agents, hour+i*300); err != nil {
+			t.Fatalf("StoreAgents: %v", err)
+		}
+	}
+	var status ResponseStatus
+	status.ResetDate = "2026-01-04"
+	status.Stats.Agents = 2
+	if err := src.StoreStats(status, hour+23*300); err != nil {
+		t.Fatalf("StoreStats: %v", err)
+	}
+	return archiveFiles(t, src.DataPath(), extra)
+}
+
+func TestStore_Import(t *testing.T) {
+	hour := time.Date(2026, 1, 10, 10, 0, 0, 0, time.UTC).Unix()
+	archive := sourceArchive(t, hour, map[string][]byte{"README.txt": []byte("notes")})
+
+	disk, err := NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	bolt, err := NewBoltStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	t.Cleanup(func() { bolt.Close() })
+
+	for name, s := range map[string]interface {
+		Store
+		Importer
+	}{"disk": disk, "bolt": bolt} {
+		t.Run(name, func(t *testing.T) {
+			if err := s.UpdateReset("2026-01-04"); err != nil {
+				t.Fatalf("UpdateReset: %v", err)
+			}
+			// we saw ALPHA differently at the first tick and have a later
+			// one, so our agent list is newer too
+			agents := []PublicAgent{{Symbol: "ALPHA", Credits: 5, Headquarters: "X1-AB12-A1", ShipCount: 2}}
+			if err := s.StoreAgents(agents, hour); err != nil {
+				t.Fatalf("StoreAgents: %v", err)
+			}
+			if err := s.StoreAgents(agents, hour+3*3600); err != nil {
+				t.Fatalf("StoreAgents: %v", err)
+			}
+
+			report, err := s.Import(bytes.NewReader(archive), true)
+			if err != nil {
+				t.Fatalf("dry run: %v", err)
+			}
+			if len(report.Imported) == 0 || len(report.Conflicts) != 2 {
+				t.Fatalf("unexpected dry run report: %+v", report)
+			}
+			if hist, _ := s.GetAgentHistory("", 0, 0); len(hist) != 2 {
+				t.Fatalf("dry run wrote history, %d records", len(hist))
+			}
+
+			report, err = s.Import(bytes.NewReader(archive), false)
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if len(report.Skipped) != 1 || report.Skipped[0].File != "README.txt" {
+				t.Fatalf("expected README.txt to be skipped: %+v", report.Skipped)
+			}
+			conflicts := []ImportProblem{
+				{"2026-01-04/agents.gob.zst", "the local copy is newer, kept it"},
+				{"2026-01-04/agentsStatus-1768039200.gob.zst", "1 records differ from ours, kept ours"},
+			}
+			if !reflect.DeepEqual(report.Conflicts, conflicts) {
+				t.Fatalf("unexpected conflicts: %+v", report.Conflicts)
+			}
+
+			hist, err := s.GetAgentHistory("", 0, 0)
+			if err != nil {
+				t.Fatalf("GetAgentHistory: %v", err)
+			}
+			if len(hist) != 49 {
+				t.Fatalf("expected 49 history records, got %d", len(hist))
+			}
+			if hist[0].Symbol == "ALPHA" && hist[0].Credits != 5 || hist[1].Symbol == "ALPHA" && hist[1].Credits != 5 {
+				t.Fatalf("the conflicting record was replaced: %+v", hist[:2])
+			}
+			hourly, err := s.GetAgentRollups("", []string{"BRAVO"}, TierHour, 0, 0)
+			if err != nil {
+				t.Fatalf("GetAgentRollups: %v", err)
+			}
+			if len(hourly) != 2 || hourly[0].Credits.Min != 2000 || hourly[1].Credits.Last != 2023 {
+				t.Fatalf("unexpected hourly rollups for BRAVO: %+v", hourly)
+			}
+			alpha, _ := s.GetAgentRollups("", []string{"ALPHA"}, TierHour, 0, 0)
+			if len(alpha) != 3 || alpha[0].Credits.Min != 5 || alpha[2].Credits.Last != 5 {
+				t.Fatalf("unexpected hourly rollups for ALPHA: %+v", alpha)
+			}
+			if st, err := s.GetStats(""); err != nil || st.Agents != 2 {
+				t.Fatalf("stats were not imported: %+v %v", st, err)
+			}
+
+			// everything is there now
+			report, err = s.Import(bytes.NewReader(archive), false)
+			if err != nil {
+				t.Fatalf("second Import: %v", err)
+			}
+			if len(report.Imported) != 0 {
+				t.Fatalf("second import imported %v", report.Imported)
+			}
+			if hist, _ := s.GetAgentHistory("", 0, 0); len(hist) != 49 {
+				t.Fatalf("second import changed the history, %d records", len(hist))
+			}
+		})
+	}
+}
+
+// an upload into the current reset while the collector runs keeps both
+// sides in the rollups, run with -race
+func TestStore_ImportWhileCollecting(t *testing.T) {
+	for name, s := range testStores(t) {
+		if name == "memory" {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			hour := int64(1767528000)
+			archive := sourceArchive(t, hour, nil)
+			if err := s.UpdateReset("2026-01-04"); err != nil {
+				t.Fatalf("UpdateReset: %v", err)
+			}
+
+			done := make(chan error)
+			go func() {
+				for i := int64(0); i < 24; i++ {
+					agents := []PublicAgent{{Symbol: "ALPHA", Credits: 5000 + i, Headquarters: "X1-AB12-A1", ShipCount: 2}}
+					if err := s.StoreAgents(agents, hour+i*300+150); err != nil {
+						done <- err
+						return
+					}
+				}
+				done <- nil
+			}()
+			if _, err := s.(interface {
+				Import(io.Reader, bool) (ImportReport, error)
+			}).Import(bytes.NewReader(archive), false); err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if err := <-done; err != nil {
+				t.Fatalf("StoreAgents: %v", err)
+			}
+
+			for _, tier := range rollupTiers {
+				rows, err := s.GetAgentRollups("", []string{"ALPHA"}, tier, 0, 0)
+				if err != nil {
+					t.Fatalf("GetAgentRollups: %v", err)
+				}
+				lo, hi := int64(math.MaxInt64), int64(0)
+				for _, r := range rows {
+					lo, hi = min(lo, r.Credits.Min), max(hi, r.Credits.Max)
+				}
+				if lo != 1000 || hi != 5023 {
+					t.Fatalf("%s: credits %d to %d, want 1000 to 5023 from both the import and the collector", tier.Name, lo, hi)
+				}
+			}
+		})
+	}
+}
+
+// an import waits for a jumpgate change to finish instead of landing between
+// its read and its write
+func TestStore_ImportWaitsForJumpgateChange(t *testing.T) {
+	for name, s := range testStores(t) {
+		if name == "memory" {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			archive := sourceArchive(t, int64(1767528000), nil)
+			if err := s.UpdateReset("2026-01-04"); err != nil {
+				t.Fatalf("UpdateReset: %v", err)
+			}
+
+			entered, release, changed := make(chan struct{}), make(chan struct{}), make(chan error)
+			go func() {
+				changed <- s.ChangeJumpGates(func(current []JGInfo) []JGInfo {
+					close(entered)
+					<-release
+					return append(current, JGInfo{Jumpgate: "X1-EF56-I1", System: "X1-EF56", Status: Const})
+				})
+			}()
+			<-entered
+			imported := make(chan error)
+			go func() {
+				_, err := s.(interface {
+					Import(io.Reader, bool) (ImportReport, error)
+				}).Import(bytes.NewReader(archive), false)
+				imported <- err
+			}()
+			select {
+			case err := <-imported:
+				t.Fatalf("the import ran in the middle of the change: %v", err)
+			case <-time.After(100 * time.Millisecond):
+			}
+			close(release)
+			if err := <-changed; err != nil {
+				t.Fatalf("ChangeJumpGates: %v", err)
+			}
+			if err := <-imported; err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+		})
+	}
+}
+
+// a BoltStore export carries the database rows, of a trimmed reset too, into
+// either store
+func TestBoltStore_ExportRoundTrip(t *testing.T) {
+	src, err := NewBoltStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	t.Cleanup(func() { src.Close() })
+	fillResets(t, src, []Reset{"2025-12-28", "2026-01-04"})
+	if _, err := src.Prune(RetentionPolicy{FullResets: 1}); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	var archive bytes.Buffer
+	if err := src.Export(&archive); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	disk, err := NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	dst, err := NewBoltStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	t.Cleanup(func() { dst.Close() })
+
+	for name, s := range map[string]interface {
+		Store
+		Importer
+	}{"disk": disk, "bolt": dst} {
+		t.Run(name, func(t *testing.T) {
+			report, err := s.Import(bytes.NewReader(archive.Bytes()), false)
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			for _, p := range report.Skipped {
+				if strings.HasSuffix(p.File, boltFilename) {
+					t.Fatalf("the database file was exported: %+v", report.Skipped)
+				}
+			}
+
+			want, _ := src.GetAgentHistory("2026-01-04", 0, 0)
+			got, err := s.GetAgentHistory("2026-01-04", 0, 0)
+			if err != nil || len(want) != 12 || !reflect.DeepEqual(got, want) {
+				t.Fatalf("agent history did not round trip\n got: %+v (%v)\nwant: %+v", got, err, want)
+			}
+			wantCons, _ := src.GetConstructions("2026-01-04", 0, 0)
+			gotCons, err := s.GetConstructions("2026-01-04", 0, 0)
+			if err != nil || len(wantCons) != 1 || !reflect.DeepEqual(gotCons, wantCons) {
+				t.Fatalf("constructions did not round trip\n got: %+v (%v)\nwant: %+v", gotCons, err, wantCons)
+			}
+			// the trimmed reset only has its daily rollups left
+			wantDays, _ := src.GetAgentRollups("2025-12-28", nil, TierDay, 0, 0)
+			gotDays, err := s.GetAgentRollups("2025-12-28", nil, TierDay, 0, 0)
+			if err != nil || len(wantDays) != 1 || !reflect.DeepEqual(gotDays, wantDays) {
+				t.Fatalf("daily rollups did not round trip\n got: %+v (%v)\nwant: %+v", gotDays, err, wantDays)
+			}
+			wantConsDays, _ := src.GetConstructionRollups("2025-12-28", nil, TierDay, 0, 0)
+			gotConsDays, err := s.GetConstructionRollups("2025-12-28", nil, TierDay, 0, 0)
+			if err != nil || len(wantConsDays) != 1 || !reflect.DeepEqual(gotConsDays, wantConsDays) {
+				t.Fatalf("construction rollups did not round trip\n got: %+v (%v)\nwant: %+v", gotConsDays, err, wantConsDays)
+			}
+			if agents, err := s.GetAgentList("2026-01-04"); err != nil || len(agents) != 1 {
+				t.Fatalf("the agent list did not round trip: %+v (%v)", agents, err)
+			}
+		})
+	}
+}
+
+func TestDiskStore_ImportKeepsNewerSnapshots(t *testing.T) {
+	hour := time.Date(2026, 1, 10, 10, 0, 0, 0, time.UTC).Unix()
+	archive := sourceArchive(t, hour, nil)
+
+	d, err := NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	if err := d.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	var status ResponseStatus
+	status.ResetDate = "2026-01-04"
+	status.Stats.Agents = 7
+	if err := d.StoreStats(status, hour+3*3600); err != nil {
+		t.Fatalf("StoreStats: %v", err)
+	}
+	if err := os.Chtimes(filepath.Join(d.currentPath(), "stats.gob.zst"), time.Now().Add(time.Hour), time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	report, err := d.Import(bytes.NewReader(archive), false)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	want := ImportProblem{"2026-01-04/stats.gob.zst", "the local copy is newer, kept it"}
+	if len(report.Conflicts) != 1 || report.Conflicts[0] != want {
+		t.Fatalf("expected a conflict on stats: %+v", report.Conflicts)
+	}
+	if st, _ := d.GetStats(""); st.Agents != 7 {
+		t.Fatalf("newer stats were overwritten: %+v", st)
+	}
+
+	// an older local copy is replaced
+	if err := os.Chtimes(filepath.Join(d.currentPath(), "stats.gob.zst"), time.Unix(hour, 0), time.Unix(hour, 0)); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	if _, err := d.Import(bytes.NewReader(archive), false); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if st, _ := d.GetStats(""); st.Agents != 2 {
+		t.Fatalf("older stats were kept: %+v", st)
+	}
+}
+
+func TestDiskStore_ImportRejectsInvalidArchives(t *testing.T) {
+	hour := time.Date(2026, 1, 10, 10, 0, 0, 0, time.UTC).Unix()
+	construction, err := os.ReadFile(filepath.Join("testdata", "schema", "v2", "construction-1767225600.gob.zst"))
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	for name, extra := range map[string]map[string][]byte{
+		"corrupt":    {"2026-01-04/leaderboard.gob.zst": []byte("not zstd")},
+		"wrong kind": {"2026-01-04/leaderboard.gob.zst": construction},
+	} {
+		t.Run(name, func(t *testing.T) {
+			archive := sourceArchive(t, hour, extra)
+			d, err := NewDiskStore(t.TempDir(), false)
+			if err != nil {
+				t.Fatalf("NewDiskStore: %v", err)
+			}
+			report, err := d.Import(bytes.NewReader(archive), false)
+			if !errors.Is(err, ErrInvalidArchive) {
+				t.Fatalf("expected ErrInvalidArchive, got %v", err)
+			}
+			if len(report.Invalid) != 1 || report.Invalid[0].File != "2026-01-04/leaderboard.gob.zst" {
+				t.Fatalf("unexpected invalid files: %+v", report.Invalid)
+			}
+			if resets := d.AllResets(); len(resets) != 0 {
+				t.Fatalf("an invalid archive was partly imported: %v", resets)
+			}
+		})
+	}
+
+	d, err := NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	if _, err := d.Import(bytes.NewReader([]byte("plain text")), false); !errors.Is(err, ErrInvalidArchive) {
+		t.Fatalf("expected an error for something that is not a .tar.gz")
+	}
+}
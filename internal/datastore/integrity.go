@@ -0,0 +1,127 @@
+package datastore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/papaburgs/fluffy-robot/internal/logging"
+	"github.com/papaburgs/fluffy-robot/internal/metrics"
+)
+
+// quarantineDir is the subdirectory of a reset that files which fail to
+// decode are moved to. readData only looks at files so they drop out of
+// every read but stay around to be looked at
+const quarantineDir = "quarantine"
+
+// IntegrityChecker is implemented by stores that can check their files. It
+// removes unfinished temp files so it must run before anything writes
+type IntegrityChecker interface {
+	CheckIntegrity() ([]string, error)
+}
+
+// WriteChecker is implemented by stores that keep their data in a
+// directory, for health checks
+type WriteChecker interface {
+	CheckWritable() error
+}
+
+// CheckWritable creates and removes a temp file in the storage directory
+func (d *DiskStore) CheckWritable() error {
+	f, err := os.CreateTemp(d.path, ".writable-*.tmp")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	_, err = f.Write([]byte("ok"))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if rerr := os.Remove(name); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// checkSnapshot decodes filename, files of a kind we do not know only need
+// to decompress
+func checkSnapshot(filename string) error {
+	_, err := ReadSnapshotFile(filename)
+	if errors.Is(err, errUnknownSnapshot) {
+		return nil
+	}
+	return err
+}
+
+// isTempFile matches the names writeTemp creates
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".tmp")
+}
+
+// CheckIntegrity decodes every snapshot file in every reset directory and
+// moves the ones that fail into the reset's quarantine directory. Temp files
+// left by a write that never finished are removed. Returns the quarantined
+// files as <reset>/<name>
+func (d *DiskStore) CheckIntegrity() ([]string, error) {
+	d.compactMu.Lock()
+	defer d.compactMu.Unlock()
+	quarantined := []string{}
+	for _, r := range d.AllResets() {
+		if ResetStart(Reset(r)).IsZero() {
+			continue
+		}
+		dir := filepath.Join(d.path, r)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return quarantined, err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			name := e.Name()
+			if isTempFile(name) {
+				logging.Warn("integrity: removing unfinished write", filepath.Join(r, name))
+				if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+					return quarantined, err
+				}
+				continue
+			}
+			if !strings.HasSuffix(name, ".gob.zst") {
+				continue
+			}
+			err := checkSnapshot(filepath.Join(dir, name))
+			if err == nil {
+				continue
+			}
+			logging.Error("integrity: quarantining", filepath.Join(r, name), err)
+			if err := d.quarantine(Reset(r), name); err != nil {
+				return quarantined, err
+			}
+			quarantined = append(quarantined, filepath.Join(r, name))
+			metrics.DatastoreQuarantinedFiles.Add(1)
+		}
+	}
+	return quarantined, nil
+}
+
+// quarantine moves name out of thisReset's directory, along with its json copy
+func (d *DiskStore) quarantine(thisReset Reset, name string) error {
+	dir := filepath.Join(d.path, string(thisReset))
+	qdir := filepath.Join(dir, quarantineDir)
+	if err := os.MkdirAll(qdir, 0755); err != nil {
+		return err
+	}
+	d.filesMu.Lock()
+	defer d.filesMu.Unlock()
+	d.cache.invalidate(cacheKey{thisReset, name})
+	if err := os.Rename(filepath.Join(dir, name), filepath.Join(qdir, name)); err != nil {
+		return err
+	}
+	jsonName := strings.TrimSuffix(name, ".gob.zst") + ".json"
+	if err := os.Rename(filepath.Join(dir, jsonName), filepath.Join(qdir, jsonName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package datastore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiskStore_WriteDataLeavesNoTempFiles(t *testing.T) {
+	d, err := NewDiskStore(t.TempDir(), true)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	if err := d.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	jg := []JGInfo{{Jumpgate: "X1-AB12-I1", System: "X1-AB12"}}
+	for i := 0; i < 3; i++ {
+		if err := d.UpdateJumpGates(jg); err != nil {
+			t.Fatalf("UpdateJumpGates: %v", err)
+		}
+	}
+	names := snapshotNames(t, filepath.Join(d.DataPath(), "2026-01-04"))
+	if strings.Join(names, ",") != "jumpgates.gob.zst,jumpgates.json" {
+		t.Fatalf("unexpected files after writes: %v", names)
+	}
+}
+
+func TestDiskStore_CheckIntegrity(t *testing.T) {
+	d, err := NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	if err := d.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC).Unix()
+	agents := []PublicAgent{{Symbol: "ALPHA", Credits: 175000, Headquarters: "X1-AB12-A1", ShipCount: 2}}
+	if err := d.StoreAgents(agents, now); err != nil {
+		t.Fatalf("StoreAgents: %v", err)
+	}
+	if _, err := d.GetAgentList(""); err != nil {
+		t.Fatalf("GetAgentList: %v", err)
+	}
+
+	// a write cut short: the snapshot is truncated and a temp file is left
+	dir := filepath.Join(d.DataPath(), "2026-01-04")
+	full, err := os.ReadFile(filepath.Join(dir, "agents.gob.zst"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "agents.gob.zst"), full[:len(full)/2], 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".agents.gob.zst.123.tmp"), full[:4], 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	d.ConfigureCache(0, 0)
+	// it is an error naming the file, not an empty list
+	if _, err := d.GetAgentList(""); err == nil || !strings.Contains(err.Error(), "agents.gob.zst") {
+		t.Fatalf("expected the truncated file to fail to read, got %v", err)
+	}
+
+	bad, err := d.CheckIntegrity()
+	if err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+	if len(bad) != 1 || bad[0] != filepath.Join("2026-01-04", "agents.gob.zst") {
+		t.Fatalf("unexpected quarantined files: %v", bad)
+	}
+	if _, err := os.Stat(filepath.Join(dir, quarantineDir, "agents.gob.zst")); err != nil {
+		t.Fatalf("quarantined file missing: %v", err)
+	}
+	for _, name := range snapshotNames(t, dir) {
+		if isTempFile(name) || name == "agents.gob.zst" {
+			t.Fatalf("%s left in the reset directory", name)
+		}
+	}
+
+	// the rest of the reset still reads, the agent list is back with the
+	// next write
+	if hist, err := d.GetAgentHistory("", 0, 0); err != nil || len(hist) != 1 {
+		t.Fatalf("GetAgentHistory after quarantine = %v, %v", hist, err)
+	}
+	if bad, _ := d.CheckIntegrity(); len(bad) != 0 {
+		t.Fatalf("second scan quarantined %v", bad)
+	}
+	if err := d.StoreAgents(agents, now+300); err != nil {
+		t.Fatalf("StoreAgents: %v", err)
+	}
+	if list, err := d.GetAgentList(""); err != nil || len(list) != 1 {
+		t.Fatalf("GetAgentList after the next write = %v, %v", list, err)
+	}
+}
+
+func TestDiskStore_CheckWritable(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	d, err := NewDiskStore(dir, false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	if err := d.CheckWritable(); err != nil {
+		t.Fatalf("CheckWritable: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected the probe to be removed, found %v", entries)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("RemoveAll: %v", err)
+	}
+	if err := d.CheckWritable(); err == nil {
+		t.Fatal("expected an error once the directory is gone")
+	}
+}
@@ -1,7 +1,6 @@
 package datastore
 
 import (
-	"encoding/gob"
 	"fmt"
 	"sort"
 	"time"
@@ -9,44 +8,79 @@ import (
 	"github.com/papaburgs/fluffy-robot/internal/logging"
 )
 
-func UpdateJumpGates(jgList []JGInfo) {
-	writeData("jumpgates", 0, jgList)
+func (d *DiskStore) UpdateJumpGates(jgList []JGInfo) error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	return d.writeData("jumpgates", 0, jgList)
 }
 
-var consolidating bool
-
-func GetConstructions(thisReset Reset, start, end int64) ([]JGConstruction, error) {
-	for consolidating {
-		fmt.Println("still consolidating")
-		time.Sleep(time.Second)
+func (d *DiskStore) ChangeJumpGates(change func(current []JGInfo) []JGInfo) error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	m, err := readUncached[[]JGInfo](d, "jumpgates.", "")
+	if err != nil {
+		return err
 	}
+	current := []JGInfo{}
+	for _, v := range m {
+		current = append(current[:0], v...)
+	}
+	return d.writeData("jumpgates", 0, change(current))
+}
+
+func (d *DiskStore) GetConstructions(thisReset Reset, start, end int64) ([]JGConstruction, error) {
 	if end == 0 {
 		end = time.Now().Unix()
 	}
 	res := []JGConstruction{}
-	m, err := readData("construction-", thisReset)
+	m, err := readDecoded[[]JGConstruction](d, "construction-", thisReset)
 	if err != nil {
 		return res, err
 	}
 
 	allRecords := make([]JGConstruction, 0, len(m)*2)
-	for _, b := range m {
-		var v []JGConstruction
-		gobDec := gob.NewDecoder(b)
-		if err := gobDec.Decode(&v); err != nil {
-			return res, err
-		}
+	for _, v := range m {
 		allRecords = append(allRecords, v...)
 	}
+	m = nil
+
+	return filterConstructions(allRecords, start, end), nil
+}
+
+func (d *DiskStore) GetConstructionsFor(thisReset Reset, jumpgates []string, start, end int64) ([]JGConstruction, error) {
+	res, err := d.GetConstructions(thisReset, start, end)
+	return filterJumpgateSymbols(res, jumpgates), err
+}
+
+func (d *DiskStore) WalkConstructions(thisReset Reset, jumpgates []string, start, end int64, fn func(JGConstruction) error) error {
+	want := symbolSet(jumpgates)
+	return walkSeries(d, thisReset, "construction", start, end, func(c JGConstruction) int64 { return c.Timestamp }, func(c JGConstruction) error {
+		if want != nil && !want[c.Jumpgate] {
+			return nil
+		}
+		return fn(c)
+	})
+}
 
-	if len(m) > 5 {
-		consolidating = true
-		// consolidate("construction", allRecords, m)
-		consolidating = false
+// filterJumpgateSymbols keeps records for the given jumpgates, order is preserved
+func filterJumpgateSymbols(records []JGConstruction, jumpgates []string) []JGConstruction {
+	want := make(map[string]bool, len(jumpgates))
+	for _, j := range jumpgates {
+		want[j] = true
 	}
-	m = nil
+	res := []JGConstruction{}
+	for _, r := range records {
+		if want[r.Jumpgate] {
+			res = append(res, r)
+		}
+	}
+	return res
+}
 
-	for _, r := range allRecords {
+// filterConstructions keeps records inside [start, end] ordered by timestamp
+func filterConstructions(records []JGConstruction, start, end int64) []JGConstruction {
+	res := []JGConstruction{}
+	for _, r := range records {
 		if r.Timestamp >= start && r.Timestamp <= end {
 			res = append(res, r)
 		}
@@ -54,80 +88,69 @@ func GetConstructions(thisReset Reset, start, end int64) ([]JGConstruction, erro
 	sort.Slice(res, func(i, j int) bool {
 		return res[i].Timestamp < res[j].Timestamp
 	})
-	return res, nil
+	return res
 }
 
-func GetJumpgateList(thisReset Reset) ([]JGInfo, error) {
+func (d *DiskStore) GetJumpgateList(thisReset Reset) ([]JGInfo, error) {
 	res := []JGInfo{}
-	m, err := readData("jumpgates.", thisReset)
+	m, err := readDecoded[[]JGInfo](d, "jumpgates.", thisReset)
 	if err != nil {
 		return res, err
 	}
 
-	for _, b := range m {
-		gobDec := gob.NewDecoder(b)
-		if err := gobDec.Decode(&res); err != nil {
-			return res, err
-		}
+	for _, v := range m {
+		res = append(res[:0], v...)
 	}
 	m = nil
 	return res, nil
 }
 
-func MarkJumpgatesComplete(jgs []string, ts int64) {
-	current, err := GetJumpgateList(currentReset)
-	if err != nil {
-		logging.Error("error loading current jumpgates")
+func (d *DiskStore) AddConstructions(cList []JGConstruction, ts int64) error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	if err := d.writeData("construction", ts, cList); err != nil {
+		return err
 	}
-	updated := []JGInfo{}
-	for _, j := range current {
-		rec := j
-		for _, k := range jgs {
-			if j.System == k {
-				rec.Status = Complete
-				rec.Complete = ts
-			}
-		}
-		updated = append(updated, rec)
-	}
-	current = nil
-	UpdateJumpGates(updated)
-	updated = nil
+	return d.updateConstructionRollups(cList, ts)
 }
 
-func MarkJumpgatesStarted(jgs []string) {
-
-	current, err := GetJumpgateList(currentReset)
-	if err != nil {
-		logging.Error("error loading current jumpgates")
-	}
-	updated := []JGInfo{}
-	for _, j := range current {
-		rec := j
-		for _, k := range jgs {
-			if j.System == k {
-				rec.Status = Const
+func MarkJumpgatesComplete(s Store, jgs []string, ts int64) error {
+	return s.ChangeJumpGates(func(current []JGInfo) []JGInfo {
+		updated := []JGInfo{}
+		for _, j := range current {
+			rec := j
+			for _, k := range jgs {
+				if j.System == k {
+					rec.Status = Complete
+					rec.Complete = ts
+				}
 			}
+			updated = append(updated, rec)
 		}
-		updated = append(updated, rec)
-	}
-	current = nil
-	UpdateJumpGates(updated)
-	updated = nil
+		return updated
+	})
 }
 
-func AddConstructions(cList []JGConstruction, ts int64) {
-	writeData("construction", ts, cList)
+func MarkJumpgatesStarted(s Store, jgs []string) error {
+	return s.ChangeJumpGates(func(current []JGInfo) []JGInfo {
+		updated := []JGInfo{}
+		for _, j := range current {
+			rec := j
+			for _, k := range jgs {
+				if j.System == k {
+					rec.Status = Const
+				}
+			}
+			updated = append(updated, rec)
+		}
+		return updated
+	})
 }
 
-func GetJumpgates(thisReset Reset) map[string]JGInfo {
-	for consolidating {
-		fmt.Println("still consolidating")
-		time.Sleep(time.Second)
-	}
-	current, err := GetJumpgateList(currentReset)
+func GetJumpgates(s Store, thisReset Reset) map[string]JGInfo {
+	current, err := s.GetJumpgateList(thisReset)
 	if err != nil {
-		logging.Error("error loading current jumpgates")
+		logging.Error("error loading jumpgates for", thisReset, err)
 		return nil
 	}
 	res := make(map[string]JGInfo, len(current))
@@ -138,10 +161,10 @@ func GetJumpgates(thisReset Reset) map[string]JGInfo {
 	return res
 }
 
-func GetJumpgatesUnderConst(thisReset Reset) map[string]JGInfo {
-	current, err := GetJumpgateList(currentReset)
+func GetJumpgatesUnderConst(s Store, thisReset Reset) map[string]JGInfo {
+	current, err := s.GetJumpgateList(thisReset)
 	if err != nil {
-		logging.Error("error loading current jumpgates")
+		logging.Error("error loading jumpgates for", thisReset, err)
 		return nil
 	}
 	res := make(map[string]JGInfo)
@@ -154,10 +177,10 @@ func GetJumpgatesUnderConst(thisReset Reset) map[string]JGInfo {
 	return res
 }
 
-func GetJumpgatesNotStarted(thisReset Reset) map[string]JGInfo {
-	current, err := GetJumpgateList(currentReset)
+func GetJumpgatesNotStarted(s Store, thisReset Reset) map[string]JGInfo {
+	current, err := s.GetJumpgateList(thisReset)
 	if err != nil {
-		logging.Error("error loading current jumpgates")
+		logging.Error("error loading jumpgates for", thisReset, err)
 		return nil
 	}
 	res := make(map[string]JGInfo)
@@ -170,10 +193,29 @@ func GetJumpgatesNotStarted(thisReset Reset) map[string]JGInfo {
 	return res
 }
 
-func GetJumpgatesComplete(thisReset Reset) []JGInfo {
-	current, err := GetJumpgateList(currentReset)
+// LatestConstructions is the last record of each of jumpgates in thisReset,
+// read from the daily rollups. Jumpgates without any records are left out
+func LatestConstructions(s Store, thisReset Reset, jumpgates []string) (map[string]JGConstruction, error) {
+	res := make(map[string]JGConstruction, len(jumpgates))
+	if len(jumpgates) == 0 {
+		return res, nil
+	}
+	rollups, err := s.GetConstructionRollups(thisReset, jumpgates, TierDay, 0, 0)
+	if err != nil {
+		return res, fmt.Errorf("loading construction rollups for %s: %w", thisReset, err)
+	}
+	for _, r := range rollups {
+		if r.LastTimestamp >= res[r.Jumpgate].Timestamp {
+			res[r.Jumpgate] = JGConstruction{Timestamp: r.LastTimestamp, Jumpgate: r.Jumpgate, Materials: r.Last()}
+		}
+	}
+	return res, nil
+}
+
+func GetJumpgatesComplete(s Store, thisReset Reset) []JGInfo {
+	current, err := s.GetJumpgateList(thisReset)
 	if err != nil {
-		logging.Error("error loading current jumpgates")
+		logging.Error("error loading jumpgates for", thisReset, err)
 		return nil
 	}
 	res := []JGInfo{}
@@ -188,6 +230,5 @@ func GetJumpgatesComplete(thisReset Reset) []JGInfo {
 
 type ConstructionRecord struct {
 	Timestamp int64
-	Fabmat    int
-	Advcct    int
+	Materials Materials
 }
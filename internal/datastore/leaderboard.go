@@ -0,0 +1,91 @@
+package datastore
+
+import (
+	"sort"
+	"strconv"
+)
+
+// RankPoint is where an agent was on both leaderboards at Timestamp, a rank
+// of 0 means the agent was not on that board
+type RankPoint struct {
+	Symbol      string
+	Timestamp   int64
+	CreditsRank int
+	ChartsRank  int
+}
+
+func leaderboardSnapshot(r LeaderboardRecord, now int64) LeaderboardSnapshot {
+	return LeaderboardSnapshot{
+		Timestamp:   now,
+		CreditsList: r.CreditsList,
+		ChartsList:  r.ChartsList,
+	}
+}
+
+func leaderboardKey(s LeaderboardSnapshot) string {
+	return strconv.FormatInt(s.Timestamp, 10)
+}
+
+// filterLeaderboardHistory keeps snapshots inside [start, end] ordered by timestamp
+func filterLeaderboardHistory(records []LeaderboardSnapshot, start, end int64) []LeaderboardSnapshot {
+	res := []LeaderboardSnapshot{}
+	for _, r := range records {
+		if r.Timestamp >= start && r.Timestamp <= end {
+			res = append(res, r)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Timestamp < res[j].Timestamp
+	})
+	return res
+}
+
+// Rank is the 1 based position of symbol in a leaderboard, 0 when it is
+// not on it
+func Rank(entries []LeaderboardEntry, symbol string) int {
+	for i, e := range entries {
+		if e.Symbol == symbol {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// LeaderboardAt picks the latest snapshot taken at or before ts from
+// history, which is ordered oldest first
+func LeaderboardAt(history []LeaderboardSnapshot, ts int64) (LeaderboardSnapshot, bool) {
+	i := sort.Search(len(history), func(i int) bool {
+		return history[i].Timestamp > ts
+	})
+	if i == 0 {
+		return LeaderboardSnapshot{}, false
+	}
+	return history[i-1], true
+}
+
+// RankPoints returns a point for every snapshot and every symbol, ordered
+// by timestamp then by the order of symbols. Agents off both boards still
+// get a point so charts show the gap
+func RankPoints(history []LeaderboardSnapshot, symbols []string) []RankPoint {
+	res := make([]RankPoint, 0, len(history)*len(symbols))
+	for _, snap := range history {
+		for _, sym := range symbols {
+			res = append(res, RankPoint{
+				Symbol:      sym,
+				Timestamp:   snap.Timestamp,
+				CreditsRank: Rank(snap.CreditsList, sym),
+				ChartsRank:  Rank(snap.ChartsList, sym),
+			})
+		}
+	}
+	return res
+}
+
+// RankHistory is RankPoints for every leaderboard stored in [start, end]
+func RankHistory(s Store, thisReset Reset, symbols []string, start, end int64) ([]RankPoint, error) {
+	history, err := s.GetLeaderboardHistory(thisReset, start, end)
+	if err != nil {
+		return []RankPoint{}, err
+	}
+	return RankPoints(history, symbols), nil
+}
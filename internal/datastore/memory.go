@@ -0,0 +1,359 @@
+package datastore
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryReset holds everything collected for one reset
+type memoryReset struct {
+	agents        []Agent
+	agentHistory  []AgentStatus
+	factions      []Faction
+	jumpgates     []JGInfo
+	constructions []JGConstruction
+	stats         *Stats
+	leaderboard   *LeaderboardRecord
+	leaderboards  []LeaderboardSnapshot
+	statsHistory  []StatsPoint
+}
+
+// MemoryStore keeps all data in maps, nothing touches the filesystem
+type MemoryStore struct {
+	mu           sync.RWMutex
+	currentReset Reset
+	resets       map[Reset]*memoryReset
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		resets: make(map[Reset]*memoryReset),
+	}
+}
+
+func (m *MemoryStore) UpdateReset(r Reset) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.currentReset = r
+	if _, ok := m.resets[r]; !ok {
+		m.resets[r] = &memoryReset{}
+	}
+	return nil
+}
+
+func (m *MemoryStore) CurrentReset() Reset {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.currentReset
+}
+
+func (m *MemoryStore) AllResets() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	resets := make([]string, 0, len(m.resets))
+	for r := range m.resets {
+		resets = append(resets, string(r))
+	}
+	sort.Slice(resets, func(i, j int) bool {
+		return resets[i] > resets[j]
+	})
+	return resets
+}
+
+// current returns the data for the reset being written, callers hold the write lock
+func (m *MemoryStore) current() (*memoryReset, error) {
+	if m.currentReset == "" {
+		return nil, fmt.Errorf("current reset is not set")
+	}
+	return m.resets[m.currentReset], nil
+}
+
+// lookup returns the data for thisReset, empty means the current reset. Callers hold the read lock
+func (m *MemoryStore) lookup(thisReset Reset) (*memoryReset, error) {
+	if thisReset == "" {
+		thisReset = m.currentReset
+	}
+	mr, ok := m.resets[thisReset]
+	if !ok {
+		return nil, fmt.Errorf("no data for reset %q", thisReset)
+	}
+	return mr, nil
+}
+
+func (m *MemoryStore) StoreAgents(apiAgents []PublicAgent, now int64) error {
+	agentList, statusList := agentRecords(apiAgents, now)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mr, err := m.current()
+	if err != nil {
+		return err
+	}
+	mr.agents = agentList
+	mr.agentHistory = append(mr.agentHistory, statusList...)
+	return nil
+}
+
+func (m *MemoryStore) GetAgentList(thisReset Reset) ([]Agent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	mr, err := m.lookup(thisReset)
+	if err != nil {
+		return []Agent{}, err
+	}
+	if mr.agents == nil {
+		return []Agent{}, fmt.Errorf("invalid read")
+	}
+	return append([]Agent{}, mr.agents...), nil
+}
+
+func (m *MemoryStore) GetAgentHistory(thisReset Reset, start, end int64) ([]AgentStatus, error) {
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	mr, err := m.lookup(thisReset)
+	if err != nil {
+		return []AgentStatus{}, err
+	}
+	return filterAgentHistory(mr.agentHistory, start, end), nil
+}
+
+func (m *MemoryStore) GetAgentHistoryFor(thisReset Reset, symbols []string, start, end int64) ([]AgentStatus, error) {
+	res, err := m.GetAgentHistory(thisReset, start, end)
+	return filterAgentSymbols(res, symbols), err
+}
+
+// WalkAgentHistory works on a copy, fn may be slow and must not hold up writers
+func (m *MemoryStore) WalkAgentHistory(thisReset Reset, symbols []string, start, end int64, fn func(AgentStatus) error) error {
+	res, err := m.GetAgentHistory(thisReset, start, end)
+	if err != nil {
+		return err
+	}
+	want := symbolSet(symbols)
+	for _, r := range res {
+		if want != nil && !want[r.Symbol] {
+			continue
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAgentRollups rolls up the raw history on every call, there is nothing
+// to save by keeping the tiers in memory as well
+func (m *MemoryStore) GetAgentRollups(thisReset Reset, symbols []string, tier Tier, start, end int64) ([]AgentRollup, error) {
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	if tier == TierRaw {
+		hist, err := m.GetAgentHistory(thisReset, start, end)
+		return filterAgentRollups(rawAgentRollups(hist), symbols, tier, start, end), err
+	}
+	hist, err := m.GetAgentHistory(thisReset, tier.bucketStart(start), end)
+	if err != nil {
+		return []AgentRollup{}, err
+	}
+	return filterAgentRollups(mergeAgentRollups(nil, hist, tier), symbols, tier, start, end), nil
+}
+
+func (m *MemoryStore) StoreFactions(fac []Faction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mr, err := m.current()
+	if err != nil {
+		return err
+	}
+	mr.factions = append([]Faction{}, factionRecords(fac, m.currentReset)...)
+	return nil
+}
+
+func (m *MemoryStore) GetFactions(thisReset Reset) ([]Faction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	mr, err := m.lookup(thisReset)
+	if err != nil {
+		return []Faction{}, err
+	}
+	if mr.factions == nil {
+		return []Faction{}, fmt.Errorf("invalid read")
+	}
+	return append([]Faction{}, mr.factions...), nil
+}
+
+func (m *MemoryStore) UpdateJumpGates(jgList []JGInfo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mr, err := m.current()
+	if err != nil {
+		return err
+	}
+	mr.jumpgates = append([]JGInfo{}, jgList...)
+	return nil
+}
+
+func (m *MemoryStore) ChangeJumpGates(change func(current []JGInfo) []JGInfo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mr, err := m.current()
+	if err != nil {
+		return err
+	}
+	mr.jumpgates = append([]JGInfo{}, change(append([]JGInfo{}, mr.jumpgates...))...)
+	return nil
+}
+
+func (m *MemoryStore) GetJumpgateList(thisReset Reset) ([]JGInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	mr, err := m.lookup(thisReset)
+	if err != nil {
+		return []JGInfo{}, err
+	}
+	return append([]JGInfo{}, mr.jumpgates...), nil
+}
+
+func (m *MemoryStore) AddConstructions(cList []JGConstruction, ts int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mr, err := m.current()
+	if err != nil {
+		return err
+	}
+	mr.constructions = append(mr.constructions, cList...)
+	return nil
+}
+
+func (m *MemoryStore) GetConstructions(thisReset Reset, start, end int64) ([]JGConstruction, error) {
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	mr, err := m.lookup(thisReset)
+	if err != nil {
+		return []JGConstruction{}, err
+	}
+	return filterConstructions(mr.constructions, start, end), nil
+}
+
+func (m *MemoryStore) WalkConstructions(thisReset Reset, jumpgates []string, start, end int64, fn func(JGConstruction) error) error {
+	res, err := m.GetConstructions(thisReset, start, end)
+	if err != nil {
+		return err
+	}
+	want := symbolSet(jumpgates)
+	for _, r := range res {
+		if want != nil && !want[r.Jumpgate] {
+			continue
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetConstructionsFor(thisReset Reset, jumpgates []string, start, end int64) ([]JGConstruction, error) {
+	res, err := m.GetConstructions(thisReset, start, end)
+	return filterJumpgateSymbols(res, jumpgates), err
+}
+
+func (m *MemoryStore) GetConstructionRollups(thisReset Reset, jumpgates []string, tier Tier, start, end int64) ([]ConstructionRollup, error) {
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	if tier == TierRaw {
+		cons, err := m.GetConstructions(thisReset, start, end)
+		return filterConstructionRollups(rawConstructionRollups(cons), jumpgates, tier, start, end), err
+	}
+	cons, err := m.GetConstructions(thisReset, tier.bucketStart(start), end)
+	if err != nil {
+		return []ConstructionRollup{}, err
+	}
+	return filterConstructionRollups(mergeConstructionRollups(nil, cons, tier), jumpgates, tier, start, end), nil
+}
+
+func (m *MemoryStore) StoreStats(r ResponseStatus, now int64) error {
+	st := statsRecord(r)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mr, err := m.current()
+	if err != nil {
+		return err
+	}
+	mr.stats = &st
+	mr.statsHistory = append(mr.statsHistory, statsPoint(st, now))
+	return nil
+}
+
+func (m *MemoryStore) GetStatsHistory(thisReset Reset, start, end int64) ([]StatsPoint, error) {
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	mr, err := m.lookup(thisReset)
+	if err != nil {
+		return []StatsPoint{}, err
+	}
+	return filterStatsHistory(mr.statsHistory, start, end), nil
+}
+
+func (m *MemoryStore) GetStats(thisReset Reset) (Stats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	mr, err := m.lookup(thisReset)
+	if err != nil {
+		return Stats{}, err
+	}
+	if mr.stats == nil {
+		return Stats{}, fmt.Errorf("invalid read")
+	}
+	return *mr.stats, nil
+}
+
+func (m *MemoryStore) StoreLeaderboards(r ResponseStatus, now int64) error {
+	ldrbd := leaderboardRecord(r)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mr, err := m.current()
+	if err != nil {
+		return err
+	}
+	mr.leaderboard = &ldrbd
+	mr.leaderboards = append(mr.leaderboards, leaderboardSnapshot(ldrbd, now))
+	return nil
+}
+
+func (m *MemoryStore) GetLeaderboard(thisReset Reset) ([]LeaderboardEntry, []LeaderboardEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	mr, err := m.lookup(thisReset)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mr.leaderboard == nil {
+		return nil, nil, fmt.Errorf("invalid read")
+	}
+	return append([]LeaderboardEntry{}, mr.leaderboard.CreditsList...),
+		append([]LeaderboardEntry{}, mr.leaderboard.ChartsList...),
+		nil
+}
+
+func (m *MemoryStore) GetLeaderboardHistory(thisReset Reset, start, end int64) ([]LeaderboardSnapshot, error) {
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	mr, err := m.lookup(thisReset)
+	if err != nil {
+		return []LeaderboardSnapshot{}, err
+	}
+	return filterLeaderboardHistory(mr.leaderboards, start, end), nil
+}
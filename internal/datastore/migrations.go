@@ -0,0 +1,143 @@
+package datastore
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/papaburgs/fluffy-robot/internal/logging"
+)
+
+func init() {
+	// version 1 added the header, the payloads are unchanged
+	for _, k := range snapshotKinds {
+		registerMigration(migration{kind: k.name, from: 0, upgrade: unchanged})
+	}
+	registerMigration(migration{kind: "construction", from: 1, upgrade: upgradeSlice(legacyConstruction.current)})
+	registerMigration(migration{kind: "constructionRollup", from: 1, upgrade: upgradeSlice(legacyConstructionRollup.current)})
+}
+
+func unchanged(payload []byte) ([]byte, error) {
+	return payload, nil
+}
+
+// version 2 of construction and constructionRollup replaced the FAB_MATS and
+// ADVANCED_CIRCUITRY fields with the whole recipe. Gates then always needed
+// 1600 and 400
+var legacyRecipe = Materials{
+	{Symbol: "FAB_MATS", Required: 1600},
+	{Symbol: "ADVANCED_CIRCUITRY", Required: 400},
+}
+
+// legacyConstruction decodes a JGConstruction of either version, rows in
+// timeseries.db carry no version so they are read through it too
+type legacyConstruction struct {
+	Timestamp int64
+	Jumpgate  string
+	Materials Materials
+	Fabmat    int
+	Advcct    int
+}
+
+func (l legacyConstruction) current() JGConstruction {
+	c := JGConstruction{Timestamp: l.Timestamp, Jumpgate: l.Jumpgate, Materials: l.Materials}
+	if c.Materials == nil {
+		c.Materials = slices.Clone(legacyRecipe)
+		c.Materials[0].Fulfilled = l.Fabmat
+		c.Materials[1].Fulfilled = l.Advcct
+	}
+	return c
+}
+
+// legacyConstructionRollup is legacyConstruction for ConstructionRollup
+type legacyConstructionRollup struct {
+	Jumpgate      string
+	Timestamp     int64
+	LastTimestamp int64
+	Materials     []MaterialRollup
+	Fabmat        Rollup
+	Advcct        Rollup
+}
+
+func (l legacyConstructionRollup) current() ConstructionRollup {
+	c := ConstructionRollup{Jumpgate: l.Jumpgate, Timestamp: l.Timestamp, LastTimestamp: l.LastTimestamp, Materials: l.Materials}
+	if c.Materials == nil {
+		c.Materials = []MaterialRollup{
+			{Symbol: legacyRecipe[0].Symbol, Required: int64(legacyRecipe[0].Required), Fulfilled: l.Fabmat},
+			{Symbol: legacyRecipe[1].Symbol, Required: int64(legacyRecipe[1].Required), Fulfilled: l.Advcct},
+		}
+	}
+	return c
+}
+
+// upgradeSlice re-encodes a gob []From payload as []To
+func upgradeSlice[From, To any](convert func(From) To) func([]byte) ([]byte, error) {
+	return func(payload []byte) ([]byte, error) {
+		var old []From
+		if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&old); err != nil {
+			return nil, err
+		}
+		res := make([]To, 0, len(old))
+		for _, o := range old {
+			res = append(res, convert(o))
+		}
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(res); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+// Migrate rewrites every snapshot file stored at an older schema version at
+// the current one, so reads no longer have to upgrade it. Returns the files
+// rewritten (or, in a dry run, that would be) as <reset>/<name>
+func (d *DiskStore) Migrate(dryRun bool) ([]string, error) {
+	d.compactMu.Lock()
+	defer d.compactMu.Unlock()
+	migrated := []string{}
+	for _, r := range d.AllResets() {
+		if ResetStart(Reset(r)).IsZero() {
+			continue
+		}
+		dir := filepath.Join(d.path, r)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return migrated, err
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if e.IsDir() || !strings.HasSuffix(name, ".gob.zst") {
+				continue
+			}
+			b, err := readSnapshotBytes(filepath.Join(dir, name))
+			if err != nil {
+				return migrated, err
+			}
+			payload, k, version, err := upgradePayload(name, b)
+			if err != nil {
+				logging.Warn("migrate: skipping", filepath.Join(r, name), err)
+				continue
+			}
+			if version == k.version {
+				continue
+			}
+			migrated = append(migrated, filepath.Join(r, name))
+			if dryRun {
+				continue
+			}
+			v := k.target()
+			if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(v); err != nil {
+				return migrated, err
+			}
+			if err := d.writeSnapshot(Reset(r), name, v); err != nil {
+				return migrated, err
+			}
+			logging.Info("migrate:", filepath.Join(r, name), "from version", version, "to", k.version)
+		}
+	}
+	return migrated, nil
+}
@@ -0,0 +1,265 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/papaburgs/fluffy-robot/internal/config"
+	"github.com/papaburgs/fluffy-robot/internal/logging"
+	"github.com/papaburgs/fluffy-robot/internal/metrics"
+)
+
+// RetentionPolicy decides what is kept of old resets. Resets are counted
+// newest first and the reset being written is never touched:
+//
+//	index < FullResets               kept as is
+//	FullResets <= index < MaxResets  history trimmed to daily rollups
+//	index >= MaxResets               deleted
+//
+// A zero FullResets keeps every reset that is not deleted in full, a zero
+// MaxResets never deletes. The zero policy does nothing.
+type RetentionPolicy struct {
+	FullResets int
+	MaxResets  int
+	DryRun     bool
+}
+
+func (p RetentionPolicy) Enabled() bool {
+	return p.FullResets > 0 || p.MaxResets > 0
+}
+
+type RetentionAction string
+
+const (
+	RetainFull    RetentionAction = "keep"
+	RetainRollups RetentionAction = "trim"
+	RetainNone    RetentionAction = "delete"
+)
+
+// PruneResult is what was (or, in a dry run, would be) removed from a reset
+type PruneResult struct {
+	Reset  Reset
+	Action RetentionAction
+	Files  int
+	Bytes  int64
+	// Rows is the number of database rows, for stores that have them
+	Rows int
+}
+
+// Janitor is implemented by stores that can prune old resets
+type Janitor interface {
+	Prune(p RetentionPolicy) ([]PruneResult, error)
+}
+
+type retentionStep struct {
+	reset  Reset
+	action RetentionAction
+}
+
+// plan assigns an action to every reset directory. Only names that are
+// reset dates are considered so a storage path shared with anything else
+// is never swept
+func (p RetentionPolicy) plan(resets []string, current Reset) []retentionStep {
+	steps := []retentionStep{}
+	i := 0
+	for _, r := range resets {
+		if ResetStart(Reset(r)).IsZero() {
+			continue
+		}
+		action := RetainFull
+		switch {
+		case Reset(r) == current:
+		case p.MaxResets > 0 && i >= p.MaxResets:
+			action = RetainNone
+		case p.FullResets > 0 && i >= p.FullResets:
+			action = RetainRollups
+		}
+		steps = append(steps, retentionStep{reset: Reset(r), action: action})
+		i++
+	}
+	return steps
+}
+
+// NewRetentionPolicy is the policy cfg describes
+func NewRetentionPolicy(cfg config.Retention) RetentionPolicy {
+	return RetentionPolicy{
+		FullResets: cfg.FullResets,
+		MaxResets:  cfg.MaxResets,
+		DryRun:     cfg.DryRun,
+	}
+}
+
+// RunRetention prunes now and then every interval until ctx is cancelled.
+// It returns straight away for a policy that does nothing
+func RunRetention(ctx context.Context, j Janitor, p RetentionPolicy, interval time.Duration) {
+	if !p.Enabled() {
+		return
+	}
+	logging.Info("retention enabled", "full", p.FullResets, "max", p.MaxResets, "dryRun", p.DryRun)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		runRetention(j, p)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func runRetention(j Janitor, p RetentionPolicy) {
+	start := time.Now()
+	metrics.DatastoreRetentionRuns.Add(1)
+	results, err := j.Prune(p)
+	if err != nil {
+		metrics.DatastoreRetentionErrors.Add(1)
+		logging.Error("retention: prune failed", err)
+	}
+	var pendingBytes int64
+	for _, r := range results {
+		if p.DryRun {
+			logging.Info("retention: would", string(r.Action), r.Reset, "files", r.Files, "bytes", r.Bytes, "rows", r.Rows)
+			pendingBytes += r.Bytes
+			continue
+		}
+		logging.Info("retention:", string(r.Action), r.Reset, "files", r.Files, "bytes", r.Bytes, "rows", r.Rows)
+		metrics.DatastoreRetentionBytesRemoved.Add(r.Bytes)
+		switch r.Action {
+		case RetainNone:
+			metrics.DatastoreRetentionResetsDeleted.Add(1)
+		case RetainRollups:
+			metrics.DatastoreRetentionResetsTrimmed.Add(1)
+		}
+	}
+	if p.DryRun {
+		metrics.DatastoreRetentionPendingResets.Set(int64(len(results)))
+		metrics.DatastoreRetentionPendingBytes.Set(pendingBytes)
+	}
+	logging.Debug("retention complete, took", time.Since(start))
+}
+
+// trimmedPrefixes are the history files a trimmed reset loses, the daily
+// rollups and the single snapshot files (agents, stats...) stay
+var trimmedPrefixes = []string{
+	"agentsStatus-",
+	"construction-",
+	rollupBasename("agents", TierHour) + "-",
+	rollupBasename("construction", TierHour) + "-",
+}
+
+func isTrimmed(name string) bool {
+	for _, p := range trimmedPrefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prune applies p to every reset directory
+func (d *DiskStore) Prune(p RetentionPolicy) ([]PruneResult, error) {
+	// compaction rewrites the same files, let it finish first
+	d.compactMu.Lock()
+	defer d.compactMu.Unlock()
+	results := []PruneResult{}
+	for _, step := range p.plan(d.AllResets(), d.CurrentReset()) {
+		var (
+			res PruneResult
+			err error
+		)
+		switch step.action {
+		case RetainRollups:
+			res, err = d.trimReset(step.reset, p.DryRun)
+		case RetainNone:
+			res, err = d.deleteReset(step.reset, p.DryRun)
+		default:
+			continue
+		}
+		if err != nil {
+			return results, fmt.Errorf("%s %s: %w", step.action, step.reset, err)
+		}
+		if res.Files > 0 || step.action == RetainNone {
+			results = append(results, res)
+		}
+	}
+	return results, nil
+}
+
+// trimReset removes the raw and hourly history of thisReset, folding the raw
+// history into the daily rollups first
+func (d *DiskStore) trimReset(thisReset Reset, dryRun bool) (PruneResult, error) {
+	res := PruneResult{Reset: thisReset, Action: RetainRollups}
+	dir := filepath.Join(d.path, string(thisReset))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return res, err
+	}
+	victims := []os.DirEntry{}
+	for _, e := range entries {
+		if !e.IsDir() && isTrimmed(e.Name()) {
+			victims = append(victims, e)
+			if info, err := e.Info(); err == nil {
+				res.Bytes += info.Size()
+			}
+		}
+	}
+	res.Files = len(victims)
+	if dryRun || len(victims) == 0 {
+		return res, nil
+	}
+	if err := d.ensureDailyRollups(thisReset); err != nil {
+		return res, err
+	}
+
+	d.filesMu.Lock()
+	defer d.filesMu.Unlock()
+	for _, e := range victims {
+		d.cache.invalidate(cacheKey{thisReset, e.Name()})
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil && !os.IsNotExist(err) {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// ensureDailyRollups folds the raw history into the daily rollups, so every
+// day of it keeps a row once the raw files go. That includes resets from
+// before rollups and days collected before a reset's rollups began
+func (d *DiskStore) ensureDailyRollups(thisReset Reset) error {
+	if err := d.agentRollupSeries(thisReset).backfill(d, thisReset, TierDay); err != nil {
+		return err
+	}
+	return d.constructionRollupSeries(thisReset).backfill(d, thisReset, TierDay)
+}
+
+// deleteReset removes the whole reset directory
+func (d *DiskStore) deleteReset(thisReset Reset, dryRun bool) (PruneResult, error) {
+	res := PruneResult{Reset: thisReset, Action: RetainNone}
+	dir := filepath.Join(d.path, string(thisReset))
+	err := filepath.WalkDir(dir, func(path string, e os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() {
+			return nil
+		}
+		res.Files++
+		if info, err := e.Info(); err == nil {
+			res.Bytes += info.Size()
+		}
+		return nil
+	})
+	if err != nil || dryRun {
+		return res, err
+	}
+
+	d.filesMu.Lock()
+	defer d.filesMu.Unlock()
+	d.cache.invalidateReset(thisReset)
+	return res, os.RemoveAll(dir)
+}
@@ -0,0 +1,229 @@
+package datastore
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestRetentionPolicy_Plan(t *testing.T) {
+	resets := []string{"2026-01-18", "2026-01-11", "lost+found", "2026-01-04", "2025-12-28", "2025-12-21"}
+	p := RetentionPolicy{FullResets: 2, MaxResets: 4}
+	got := []string{}
+	for _, s := range p.plan(resets, "2026-01-18") {
+		got = append(got, string(s.reset)+"="+string(s.action))
+	}
+	want := "2026-01-18=keep,2026-01-11=keep,2026-01-04=trim,2025-12-28=trim,2025-12-21=delete"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("unexpected plan\n got: %s\nwant: %s", strings.Join(got, ","), want)
+	}
+
+	// the reset being written is kept even past the limits
+	for _, s := range (RetentionPolicy{MaxResets: 1}).plan([]string{"2026-01-18", "2026-01-11"}, "2026-01-11") {
+		if s.reset == "2026-01-11" && s.action != RetainFull {
+			t.Fatalf("current reset would be %s", s.action)
+		}
+	}
+}
+
+// fillResets writes an hour of agent and construction history into each reset,
+// the last one stays current
+func fillResets(t *testing.T, s Store, resets []Reset) {
+	for _, r := range resets {
+		if err := s.UpdateReset(r); err != nil {
+			t.Fatalf("UpdateReset: %v", err)
+		}
+		start := ResetStart(r).Add(12 * time.Hour).Unix()
+		for i := int64(0); i < 12; i++ {
+			agents := []PublicAgent{{Symbol: "ALPHA", Credits: 1000 + i, Headquarters: "X1-AB12-A1", ShipCount: 2}}
+			if err := s.StoreAgents(agents, start+i*300); err != nil {
+				t.Fatalf("StoreAgents: %v", err)
+			}
+		}
+		c := []JGConstruction{{Timestamp: start, Jumpgate: "X1-AB12-I1", Materials: Materials{
+			{Symbol: "FAB_MATS", Required: 1600, Fulfilled: 100},
+			{Symbol: "ADVANCED_CIRCUITRY", Required: 400, Fulfilled: 10},
+		}}}
+		if err := s.AddConstructions(c, start); err != nil {
+			t.Fatalf("AddConstructions: %v", err)
+		}
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	resets := []Reset{"2025-12-21", "2025-12-28", "2026-01-04"}
+	for name, s := range testStores(t) {
+		j, ok := s.(Janitor)
+		if !ok {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			fillResets(t, s, resets)
+			// a directory that is not a reset must survive any policy
+			dataPath := s.(interface{ DataPath() string }).DataPath()
+			if err := os.Mkdir(filepath.Join(dataPath, "keepme"), 0755); err != nil {
+				t.Fatalf("Mkdir: %v", err)
+			}
+			p := RetentionPolicy{FullResets: 1, MaxResets: 2, DryRun: true}
+
+			results, err := j.Prune(p)
+			if err != nil {
+				t.Fatalf("Prune dry run: %v", err)
+			}
+			if len(results) != 2 || results[0].Action != RetainRollups || results[1].Action != RetainNone {
+				t.Fatalf("unexpected dry run results: %+v", results)
+			}
+			if hist, _ := s.GetAgentHistory("2025-12-28", 0, 0); len(hist) != 12 {
+				t.Fatalf("dry run removed history, %d records left", len(hist))
+			}
+
+			p.DryRun = false
+			if _, err := j.Prune(p); err != nil {
+				t.Fatalf("Prune: %v", err)
+			}
+			all := strings.Join(s.AllResets(), ",")
+			if all != "keepme,2026-01-04,2025-12-28" {
+				t.Fatalf("unexpected resets after prune: %s", all)
+			}
+			if hist, _ := s.GetAgentHistory("2025-12-28", 0, 0); len(hist) != 0 {
+				t.Fatalf("trimmed reset kept %d raw records", len(hist))
+			}
+			days, err := s.GetAgentRollups("2025-12-28", nil, TierDay, 0, 0)
+			if err != nil || len(days) != 1 || days[0].Credits != (Rollup{Min: 1000, Max: 1011, Last: 1011}) {
+				t.Fatalf("trimmed reset lost its daily rollup: %+v (%v)", days, err)
+			}
+			cons, _ := s.GetConstructionRollups("2025-12-28", nil, TierDay, 0, 0)
+			if len(cons) != 1 || cons[0].Material("FAB_MATS").Fulfilled.Last != 100 {
+				t.Fatalf("trimmed reset lost its construction rollup: %+v", cons)
+			}
+			if hist, _ := s.GetAgentHistory("2026-01-04", 0, 0); len(hist) != 12 {
+				t.Fatalf("current reset was touched, %d records left", len(hist))
+			}
+
+			// a second run has nothing left to do
+			results, err = j.Prune(p)
+			if err != nil || len(results) != 0 {
+				t.Fatalf("expected nothing to prune, got %+v (%v)", results, err)
+			}
+		})
+	}
+}
+
+func TestDiskStore_PruneBuildsMissingRollups(t *testing.T) {
+	d, err := NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	fillResets(t, d, []Reset{"2025-12-28", "2026-01-04"})
+	// resets collected before rollups existed only have the raw files
+	dir := filepath.Join(d.DataPath(), "2025-12-28")
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), "Rollup") {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+	d.ConfigureCache(0, 0)
+
+	if _, err := d.Prune(RetentionPolicy{FullResets: 1}); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	days, err := d.GetAgentRollups("2025-12-28", []string{"ALPHA"}, TierDay, 0, 0)
+	if err != nil || len(days) != 1 || days[0].Credits.Last != 1011 {
+		t.Fatalf("expected a daily rollup built from raw history, got %+v (%v)", days, err)
+	}
+}
+
+func TestStore_PruneBackfillsEarlyDays(t *testing.T) {
+	const reset = Reset("2025-12-28")
+	day1 := ResetStart(reset).Add(12 * time.Hour).Unix()
+	day2 := ResetStart(reset).Add(36 * time.Hour).Unix()
+	for name, s := range testStores(t) {
+		j, ok := s.(Janitor)
+		if !ok {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			if err := s.UpdateReset(reset); err != nil {
+				t.Fatalf("UpdateReset: %v", err)
+			}
+			for i, ts := range []int64{day1, day2} {
+				agents := []PublicAgent{{Symbol: "ALPHA", Credits: 1000 + int64(i), Headquarters: "X1-AB12-A1"}}
+				if err := s.StoreAgents(agents, ts); err != nil {
+					t.Fatalf("StoreAgents: %v", err)
+				}
+				c := []JGConstruction{{Timestamp: ts, Jumpgate: "X1-AB12-I1", Materials: Materials{{Symbol: "FAB_MATS", Required: 1600, Fulfilled: 100 * (i + 1)}}}}
+				if err := s.AddConstructions(c, ts); err != nil {
+					t.Fatalf("AddConstructions: %v", err)
+				}
+			}
+			// rollups only began on day 2, day 1 is raw history alone
+			dropRollupsBefore(t, s, reset, TierDay.bucketStart(day2))
+			if err := s.UpdateReset("2026-01-04"); err != nil {
+				t.Fatalf("UpdateReset: %v", err)
+			}
+
+			if _, err := j.Prune(RetentionPolicy{FullResets: 1}); err != nil {
+				t.Fatalf("Prune: %v", err)
+			}
+			days, err := s.GetAgentRollups(reset, nil, TierDay, 0, 0)
+			if err != nil || len(days) != 2 || days[0].Timestamp != TierDay.bucketStart(day1) || days[0].Credits.Last != 1000 || days[1].Credits.Last != 1001 {
+				t.Fatalf("expected a daily rollup for both days, got %+v (%v)", days, err)
+			}
+			cons, err := s.GetConstructionRollups(reset, nil, TierDay, 0, 0)
+			if err != nil || len(cons) != 2 || cons[0].Material("FAB_MATS").Fulfilled.Last != 100 {
+				t.Fatalf("expected a construction rollup for both days, got %+v (%v)", cons, err)
+			}
+		})
+	}
+}
+
+// dropRollupsBefore removes every rollup row of thisReset older than ts, as
+// if rollups only began at ts
+func dropRollupsBefore(t *testing.T, s Store, thisReset Reset, ts int64) {
+	t.Helper()
+	if b, ok := s.(*BoltStore); ok {
+		err := b.db.Update(func(tx *bolt.Tx) error {
+			for _, series := range []string{"agents", "construction"} {
+				for _, tier := range rollupTiers {
+					resetBucket := tx.Bucket(rollupBucket(series, tier)).Bucket([]byte(thisReset))
+					err := resetBucket.ForEachBucket(func(symbol []byte) error {
+						c := resetBucket.Bucket(symbol).Cursor()
+						for k, _ := c.First(); k != nil && bytes.Compare(k, timestampKey(ts)) < 0; k, _ = c.First() {
+							if err := c.Delete(); err != nil {
+								return err
+							}
+						}
+						return nil
+					})
+					if err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("dropping rollups: %v", err)
+		}
+		return
+	}
+	d := s.(*DiskStore)
+	dir := filepath.Join(d.DataPath(), string(thisReset))
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".gob.zst")
+		if i := strings.LastIndex(name, "-"); strings.Contains(name, "Rollup") && i >= 0 {
+			if bucket, err := strconv.ParseInt(name[i+1:], 10, 64); err == nil && bucket < ts {
+				os.Remove(filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	d.ConfigureCache(0, 0)
+}
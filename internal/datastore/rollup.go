@@ -0,0 +1,420 @@
+package datastore
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Tier is a resolution history can be read at. TierRaw is the collection
+// interval, the others are maintained by the stores as records are written.
+type Tier struct {
+	Name string
+	Step int64
+}
+
+var (
+	TierRaw  = Tier{Name: "5m", Step: 300}
+	TierHour = Tier{Name: "1h", Step: 3600}
+	TierDay  = Tier{Name: "1d", Step: 86400}
+
+	// rollupTiers are the tiers stored on top of the raw records
+	rollupTiers = []Tier{TierHour, TierDay}
+)
+
+// PickTier returns the finest tier that covers [start, end] in at most
+// maxPoints points per series, or TierDay if none of them do
+func PickTier(start, end int64, maxPoints int) Tier {
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	for _, t := range []Tier{TierRaw, TierHour, TierDay} {
+		if (end-start)/t.Step <= int64(maxPoints) {
+			return t
+		}
+	}
+	return TierDay
+}
+
+// TierByName returns the tier called name ("5m", "1h" or "1d")
+func TierByName(name string) (Tier, bool) {
+	for _, t := range []Tier{TierRaw, TierHour, TierDay} {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Tier{}, false
+}
+
+// bucketStart is the start of the tier bucket holding ts
+func (t Tier) bucketStart(ts int64) int64 {
+	return ts - ts%t.Step
+}
+
+// Rollup summarises one value over a bucket
+type Rollup struct {
+	Min  int64
+	Max  int64
+	Last int64
+}
+
+func newRollup(v int64) Rollup {
+	return Rollup{Min: v, Max: v, Last: v}
+}
+
+// add folds v in, v is the newest value seen
+func (r Rollup) add(v int64) Rollup {
+	r.Min = min(r.Min, v)
+	r.Max = max(r.Max, v)
+	r.Last = v
+	return r
+}
+
+// AgentRollup is one agent over one bucket. Timestamp is the bucket start,
+// LastTimestamp is when the Last values were collected
+type AgentRollup struct {
+	Symbol        string
+	Timestamp     int64
+	LastTimestamp int64
+	Credits       Rollup
+	Ships         Rollup
+}
+
+// ConstructionRollup is one jumpgate over one bucket
+type ConstructionRollup struct {
+	Jumpgate      string
+	Timestamp     int64
+	LastTimestamp int64
+	Materials     []MaterialRollup
+}
+
+// MaterialRollup is the delivered amount of one material, Required is the
+// latest recipe's
+type MaterialRollup struct {
+	Symbol    string
+	Required  int64
+	Fulfilled Rollup
+}
+
+// Last is the recipe as of LastTimestamp
+func (c ConstructionRollup) Last() Materials {
+	res := make(Materials, 0, len(c.Materials))
+	for _, m := range c.Materials {
+		res = append(res, Material{Symbol: m.Symbol, Required: int(m.Required), Fulfilled: int(m.Fulfilled.Last)})
+	}
+	return res
+}
+
+// Material returns the rollup for symbol, or a zero one
+func (c ConstructionRollup) Material(symbol string) MaterialRollup {
+	for _, m := range c.Materials {
+		if m.Symbol == symbol {
+			return m
+		}
+	}
+	return MaterialRollup{}
+}
+
+func agentRollupKey(a AgentRollup) string {
+	return fmt.Sprintf("%s/%d", a.Symbol, a.Timestamp)
+}
+
+func constructionRollupKey(c ConstructionRollup) string {
+	return fmt.Sprintf("%s/%d", c.Jumpgate, c.Timestamp)
+}
+
+// addAgentStatus folds one record into the rollup for its bucket
+func (a AgentRollup) addAgentStatus(s AgentStatus) AgentRollup {
+	if a.LastTimestamp == 0 {
+		return AgentRollup{
+			Symbol:        a.Symbol,
+			Timestamp:     a.Timestamp,
+			LastTimestamp: s.Timestamp,
+			Credits:       newRollup(s.Credits),
+			Ships:         newRollup(s.Ships),
+		}
+	}
+	if s.Timestamp < a.LastTimestamp {
+		// an older record only widens the range
+		a.Credits.Min, a.Credits.Max = min(a.Credits.Min, s.Credits), max(a.Credits.Max, s.Credits)
+		a.Ships.Min, a.Ships.Max = min(a.Ships.Min, s.Ships), max(a.Ships.Max, s.Ships)
+		return a
+	}
+	a.LastTimestamp = s.Timestamp
+	a.Credits = a.Credits.add(s.Credits)
+	a.Ships = a.Ships.add(s.Ships)
+	return a
+}
+
+func (c ConstructionRollup) addConstruction(r JGConstruction) ConstructionRollup {
+	if c.LastTimestamp == 0 {
+		c = ConstructionRollup{Jumpgate: c.Jumpgate, Timestamp: c.Timestamp, LastTimestamp: r.Timestamp}
+		for _, m := range r.Materials {
+			c.Materials = append(c.Materials, MaterialRollup{Symbol: m.Symbol, Required: int64(m.Required), Fulfilled: newRollup(int64(m.Fulfilled))})
+		}
+		return c
+	}
+	// rollups may be shared with the cache, never write through the old slice
+	mats := append([]MaterialRollup{}, c.Materials...)
+	older := r.Timestamp < c.LastTimestamp
+	for _, m := range r.Materials {
+		i := slices.IndexFunc(mats, func(x MaterialRollup) bool { return x.Symbol == m.Symbol })
+		if i < 0 {
+			mats = append(mats, MaterialRollup{Symbol: m.Symbol, Required: int64(m.Required), Fulfilled: newRollup(int64(m.Fulfilled))})
+			continue
+		}
+		v := int64(m.Fulfilled)
+		if older {
+			// an older record only widens the range
+			mats[i].Fulfilled.Min, mats[i].Fulfilled.Max = min(mats[i].Fulfilled.Min, v), max(mats[i].Fulfilled.Max, v)
+			continue
+		}
+		mats[i].Required = int64(m.Required)
+		mats[i].Fulfilled = mats[i].Fulfilled.add(v)
+	}
+	c.Materials = mats
+	if !older {
+		c.LastTimestamp = r.Timestamp
+	}
+	return c
+}
+
+// mergeAgentRollups folds records into existing rollups for tier and
+// returns the result ordered by bucket then symbol
+func mergeAgentRollups(existing []AgentRollup, records []AgentStatus, tier Tier) []AgentRollup {
+	m := make(map[string]AgentRollup, len(existing)+len(records))
+	for _, a := range existing {
+		m[agentRollupKey(a)] = a
+	}
+	for _, r := range records {
+		k := agentRollupKey(AgentRollup{Symbol: r.Symbol, Timestamp: tier.bucketStart(r.Timestamp)})
+		a, ok := m[k]
+		if !ok {
+			a = AgentRollup{Symbol: r.Symbol, Timestamp: tier.bucketStart(r.Timestamp)}
+		}
+		m[k] = a.addAgentStatus(r)
+	}
+	res := make([]AgentRollup, 0, len(m))
+	for _, a := range m {
+		res = append(res, a)
+	}
+	sortAgentRollups(res)
+	return res
+}
+
+func mergeConstructionRollups(existing []ConstructionRollup, records []JGConstruction, tier Tier) []ConstructionRollup {
+	m := make(map[string]ConstructionRollup, len(existing)+len(records))
+	for _, c := range existing {
+		m[constructionRollupKey(c)] = c
+	}
+	for _, r := range records {
+		k := constructionRollupKey(ConstructionRollup{Jumpgate: r.Jumpgate, Timestamp: tier.bucketStart(r.Timestamp)})
+		c, ok := m[k]
+		if !ok {
+			c = ConstructionRollup{Jumpgate: r.Jumpgate, Timestamp: tier.bucketStart(r.Timestamp)}
+		}
+		m[k] = c.addConstruction(r)
+	}
+	res := make([]ConstructionRollup, 0, len(m))
+	for _, c := range m {
+		res = append(res, c)
+	}
+	sortConstructionRollups(res)
+	return res
+}
+
+// rawAgentRollups wraps raw records so every tier reads the same way
+func rawAgentRollups(records []AgentStatus) []AgentRollup {
+	res := make([]AgentRollup, 0, len(records))
+	for _, r := range records {
+		res = append(res, AgentRollup{Symbol: r.Symbol, Timestamp: r.Timestamp}.addAgentStatus(r))
+	}
+	return res
+}
+
+func rawConstructionRollups(records []JGConstruction) []ConstructionRollup {
+	res := make([]ConstructionRollup, 0, len(records))
+	for _, r := range records {
+		res = append(res, ConstructionRollup{Jumpgate: r.Jumpgate, Timestamp: r.Timestamp}.addConstruction(r))
+	}
+	return res
+}
+
+// filterAgentRollups keeps buckets that overlap [start, end] for the given
+// symbols, a nil symbols slice keeps every symbol
+func filterAgentRollups(rollups []AgentRollup, symbols []string, tier Tier, start, end int64) []AgentRollup {
+	var want map[string]bool
+	if symbols != nil {
+		want = make(map[string]bool, len(symbols))
+		for _, s := range symbols {
+			want[s] = true
+		}
+	}
+	res := []AgentRollup{}
+	for _, a := range rollups {
+		if want != nil && !want[a.Symbol] {
+			continue
+		}
+		if a.Timestamp+tier.Step <= start || a.Timestamp > end {
+			continue
+		}
+		res = append(res, a)
+	}
+	sortAgentRollups(res)
+	return res
+}
+
+func filterConstructionRollups(rollups []ConstructionRollup, jumpgates []string, tier Tier, start, end int64) []ConstructionRollup {
+	var want map[string]bool
+	if jumpgates != nil {
+		want = make(map[string]bool, len(jumpgates))
+		for _, j := range jumpgates {
+			want[j] = true
+		}
+	}
+	res := []ConstructionRollup{}
+	for _, c := range rollups {
+		if want != nil && !want[c.Jumpgate] {
+			continue
+		}
+		if c.Timestamp+tier.Step <= start || c.Timestamp > end {
+			continue
+		}
+		res = append(res, c)
+	}
+	sortConstructionRollups(res)
+	return res
+}
+
+func sortAgentRollups(r []AgentRollup) {
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].Timestamp != r[j].Timestamp {
+			return r[i].Timestamp < r[j].Timestamp
+		}
+		return r[i].Symbol < r[j].Symbol
+	})
+}
+
+func sortConstructionRollups(r []ConstructionRollup) {
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].Timestamp != r[j].Timestamp {
+			return r[i].Timestamp < r[j].Timestamp
+		}
+		return r[i].Jumpgate < r[j].Jumpgate
+	})
+}
+
+// AgentSeries reads agent history at the finest tier that fits maxPoints
+// per agent over [start, end]
+func AgentSeries(s Store, thisReset Reset, symbols []string, start, end int64, maxPoints int) ([]AgentRollup, Tier, error) {
+	tier := PickTier(start, end, maxPoints)
+	res, err := s.GetAgentRollups(thisReset, symbols, tier, start, end)
+	return res, tier, err
+}
+
+// ConstructionSeries reads construction history at the finest tier that
+// fits maxPoints per jumpgate over [start, end]
+func ConstructionSeries(s Store, thisReset Reset, jumpgates []string, start, end int64, maxPoints int) ([]ConstructionRollup, Tier, error) {
+	tier := PickTier(start, end, maxPoints)
+	res, err := s.GetConstructionRollups(thisReset, jumpgates, tier, start, end)
+	return res, tier, err
+}
+
+// DiskStore keeps one rollup file per bucket, rewritten on every write that
+// lands in it:
+//
+//	agentsRollup1h-1767225600.gob.zst     every agent for that hour
+//	agentsRollup1d-1767139200.gob.zst     every agent for that day
+//
+// Hourly files are merged into daily segments by compaction once their day
+// is over. Resets collected before rollups existed have no files, those are
+// rolled up from the raw history on read.
+
+func rollupBasename(series string, tier Tier) string {
+	return series + "Rollup" + tier.Name
+}
+
+func (d *DiskStore) updateAgentRollups(statusList []AgentStatus, now int64) error {
+	for _, tier := range rollupTiers {
+		basename := rollupBasename("agents", tier)
+		bucket := tier.bucketStart(now)
+		m, err := readUncached[[]AgentRollup](d, fmt.Sprintf("%s-%d.", basename, bucket), "")
+		if err != nil {
+			return err
+		}
+		var existing []AgentRollup
+		for _, v := range m {
+			existing = append(existing, v...)
+		}
+		if err := d.writeData(basename, bucket, mergeAgentRollups(existing, statusList, tier)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DiskStore) updateConstructionRollups(cList []JGConstruction, now int64) error {
+	for _, tier := range rollupTiers {
+		basename := rollupBasename("construction", tier)
+		bucket := tier.bucketStart(now)
+		m, err := readUncached[[]ConstructionRollup](d, fmt.Sprintf("%s-%d.", basename, bucket), "")
+		if err != nil {
+			return err
+		}
+		var existing []ConstructionRollup
+		for _, v := range m {
+			existing = append(existing, v...)
+		}
+		if err := d.writeData(basename, bucket, mergeConstructionRollups(existing, cList, tier)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DiskStore) GetAgentRollups(thisReset Reset, symbols []string, tier Tier, start, end int64) ([]AgentRollup, error) {
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	if tier == TierRaw {
+		hist, err := d.GetAgentHistory(thisReset, start, end)
+		return filterAgentRollups(rawAgentRollups(hist), symbols, tier, start, end), err
+	}
+	m, err := readDecoded[[]AgentRollup](d, rollupBasename("agents", tier)+"-", thisReset)
+	if err != nil {
+		return []AgentRollup{}, err
+	}
+	if len(m) == 0 {
+		hist, err := d.GetAgentHistory(thisReset, tier.bucketStart(start), end)
+		return filterAgentRollups(mergeAgentRollups(nil, hist, tier), symbols, tier, start, end), err
+	}
+	all := []AgentRollup{}
+	for _, v := range m {
+		all = append(all, v...)
+	}
+	return filterAgentRollups(all, symbols, tier, start, end), nil
+}
+
+func (d *DiskStore) GetConstructionRollups(thisReset Reset, jumpgates []string, tier Tier, start, end int64) ([]ConstructionRollup, error) {
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	if tier == TierRaw {
+		cons, err := d.GetConstructions(thisReset, start, end)
+		return filterConstructionRollups(rawConstructionRollups(cons), jumpgates, tier, start, end), err
+	}
+	m, err := readDecoded[[]ConstructionRollup](d, rollupBasename("construction", tier)+"-", thisReset)
+	if err != nil {
+		return []ConstructionRollup{}, err
+	}
+	if len(m) == 0 {
+		cons, err := d.GetConstructions(thisReset, tier.bucketStart(start), end)
+		return filterConstructionRollups(mergeConstructionRollups(nil, cons, tier), jumpgates, tier, start, end), err
+	}
+	all := []ConstructionRollup{}
+	for _, v := range m {
+		all = append(all, v...)
+	}
+	return filterConstructionRollups(all, jumpgates, tier, start, end), nil
+}
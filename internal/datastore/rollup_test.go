@@ -0,0 +1,208 @@
+package datastore
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPickTier(t *testing.T) {
+	start := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC).Unix()
+	cases := []struct {
+		window    time.Duration
+		maxPoints int
+		want      Tier
+	}{
+		{4 * time.Hour, 300, TierRaw},
+		{24 * time.Hour, 300, TierRaw},
+		{7 * 24 * time.Hour, 300, TierHour},
+		{7 * 24 * time.Hour, 50, TierDay},
+		{90 * 24 * time.Hour, 10, TierDay},
+	}
+	for _, c := range cases {
+		if got := PickTier(start, start+int64(c.window.Seconds()), c.maxPoints); got != c.want {
+			t.Errorf("PickTier(%v, %d) = %s, want %s", c.window, c.maxPoints, got.Name, c.want.Name)
+		}
+	}
+}
+
+func TestStore_AgentRollups(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := s.UpdateReset("2026-01-04"); err != nil {
+				t.Fatalf("UpdateReset: %v", err)
+			}
+			// two hours of ticks, ALPHA dips in the middle of the first hour
+			hour := time.Date(2026, 1, 10, 10, 0, 0, 0, time.UTC).Unix()
+			for i := int64(0); i < 24; i++ {
+				credits := 1000 + i*10
+				if i == 6 {
+					credits = 10
+				}
+				agents := []PublicAgent{{Symbol: "ALPHA", Credits: credits, Headquarters: "X1-AB12-A1", ShipCount: int(2 + i/12)}}
+				if err := s.StoreAgents(agents, hour+i*300); err != nil {
+					t.Fatalf("StoreAgents: %v", err)
+				}
+			}
+			end := hour + 24*300
+
+			raw, err := s.GetAgentRollups("", []string{"ALPHA"}, TierRaw, hour, end)
+			if err != nil {
+				t.Fatalf("GetAgentRollups raw: %v", err)
+			}
+			if len(raw) != 24 || raw[6].Credits.Last != 10 {
+				t.Fatalf("unexpected raw rollups: %d rows", len(raw))
+			}
+
+			hourly, err := s.GetAgentRollups("", []string{"ALPHA"}, TierHour, hour, end)
+			if err != nil {
+				t.Fatalf("GetAgentRollups hour: %v", err)
+			}
+			if len(hourly) != 2 {
+				t.Fatalf("expected 2 hourly rows, got %+v", hourly)
+			}
+			first := hourly[0]
+			if first.Timestamp != hour || first.LastTimestamp != hour+11*300 {
+				t.Fatalf("unexpected first hour bounds: %+v", first)
+			}
+			if first.Credits != (Rollup{Min: 10, Max: 1110, Last: 1110}) || first.Ships != (Rollup{Min: 2, Max: 2, Last: 2}) {
+				t.Fatalf("unexpected first hour values: %+v", first)
+			}
+			if hourly[1].Ships.Last != 3 || hourly[1].Credits.Last != 1230 {
+				t.Fatalf("unexpected second hour values: %+v", hourly[1])
+			}
+
+			daily, err := s.GetAgentRollups("", nil, TierDay, hour, end)
+			if err != nil {
+				t.Fatalf("GetAgentRollups day: %v", err)
+			}
+			if len(daily) != 1 || daily[0].Credits != (Rollup{Min: 10, Max: 1230, Last: 1230}) {
+				t.Fatalf("unexpected daily rows: %+v", daily)
+			}
+
+			none, _ := s.GetAgentRollups("", []string{"BRAVO"}, TierHour, hour, end)
+			if len(none) != 0 {
+				t.Fatalf("expected no rows for unknown agent, got %+v", none)
+			}
+		})
+	}
+}
+
+func TestAgentDays(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := s.UpdateReset("2026-01-04"); err != nil {
+				t.Fatalf("UpdateReset: %v", err)
+			}
+			day := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC).Unix()
+			ticks := []struct {
+				at      int64
+				credits int64
+				ships   int
+			}{
+				{day + 12*3600, 1000, 2},
+				{day + 18*3600, 1600, 2},
+				{day + 30*3600, 2000, 3},
+				{day + 36*3600, 2600, 4},
+			}
+			for _, tick := range ticks {
+				agents := []PublicAgent{{Symbol: "ALPHA", Credits: tick.credits, Headquarters: "X1-AB12-A1", ShipCount: tick.ships}}
+				if err := s.StoreAgents(agents, tick.at); err != nil {
+					t.Fatalf("StoreAgents: %v", err)
+				}
+			}
+
+			days, err := AgentDays(s, "", "ALPHA")
+			if err != nil {
+				t.Fatalf("AgentDays: %v", err)
+			}
+			want := []AgentDay{
+				{Day: day, Credits: 1600, CreditsGained: 600, Ships: 2, ShipsAdded: 0, Hours: 6},
+				{Day: day + 24*3600, Credits: 2600, CreditsGained: 1000, Ships: 4, ShipsAdded: 2, Hours: 18},
+			}
+			if !reflect.DeepEqual(days, want) {
+				t.Fatalf("unexpected days:\n got %+v\nwant %+v", days, want)
+			}
+			if rate := days[0].CreditsPerHour(); rate != 100 {
+				t.Fatalf("expected 100 credits per hour on the first day, got %v", rate)
+			}
+
+			if none, _ := AgentDays(s, "", "BRAVO"); len(none) != 0 {
+				t.Fatalf("expected no days for an unknown agent, got %+v", none)
+			}
+		})
+	}
+}
+
+func TestStore_ConstructionRollups(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := s.UpdateReset("2026-01-04"); err != nil {
+				t.Fatalf("UpdateReset: %v", err)
+			}
+			day := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC).Unix()
+			for i := int64(0); i < 8; i++ {
+				ts := day + i*1800
+				mats := Materials{
+					{Symbol: "FAB_MATS", Required: 1600, Fulfilled: int(100 * i)},
+					{Symbol: "ADVANCED_CIRCUITRY", Required: 400, Fulfilled: int(20 * i)},
+				}
+				// a material added to the recipe part way through
+				if i >= 6 {
+					mats = append(mats, Material{Symbol: "QUANTUM_STABILIZERS", Required: 10, Fulfilled: int(i - 5)})
+				}
+				c := []JGConstruction{{Timestamp: ts, Jumpgate: "X1-AB12-I1", Materials: mats}}
+				if err := s.AddConstructions(c, ts); err != nil {
+					t.Fatalf("AddConstructions: %v", err)
+				}
+			}
+			rows, tier, err := ConstructionSeries(s, "", []string{"X1-AB12-I1"}, day, day+4*3600, 4)
+			if err != nil {
+				t.Fatalf("ConstructionSeries: %v", err)
+			}
+			if tier != TierHour || len(rows) != 4 {
+				t.Fatalf("expected 4 hourly rows, got %s %+v", tier.Name, rows)
+			}
+			if rows[3].Material("FAB_MATS").Fulfilled != (Rollup{Min: 600, Max: 700, Last: 700}) || rows[3].Material("ADVANCED_CIRCUITRY").Fulfilled.Last != 140 {
+				t.Fatalf("unexpected last hour: %+v", rows[3])
+			}
+			if q := rows[3].Material("QUANTUM_STABILIZERS"); q.Required != 10 || q.Fulfilled != (Rollup{Min: 1, Max: 2, Last: 2}) {
+				t.Fatalf("unexpected added material: %+v", q)
+			}
+			if len(rows[2].Materials) != 2 {
+				t.Fatalf("material showed up before it was added: %+v", rows[2])
+			}
+		})
+	}
+}
+
+func TestDiskStore_RollupsSurviveCompaction(t *testing.T) {
+	d, err := NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	if err := d.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	start := time.Date(2026, 1, 9, 22, 0, 0, 0, time.UTC).Unix()
+	for i := int64(0); i < 36; i++ {
+		agents := []PublicAgent{{Symbol: "ALPHA", Credits: i, Headquarters: "X1-AB12-A1", ShipCount: 1}}
+		if err := d.StoreAgents(agents, start+i*300); err != nil {
+			t.Fatalf("StoreAgents: %v", err)
+		}
+	}
+	before, _ := d.GetAgentRollups("", nil, TierHour, start, start+36*300)
+	d.Compact(time.Date(2026, 1, 11, 0, 0, 0, 0, time.UTC))
+	after, err := d.GetAgentRollups("", nil, TierHour, start, start+36*300)
+	if err != nil {
+		t.Fatalf("GetAgentRollups: %v", err)
+	}
+	if len(before) != 3 || len(after) != 3 {
+		t.Fatalf("expected 3 hourly rows, got %d before and %d after", len(before), len(after))
+	}
+	for i := range before {
+		if before[i] != after[i] {
+			t.Fatalf("row %d changed by compaction: %+v != %+v", i, before[i], after[i])
+		}
+	}
+}
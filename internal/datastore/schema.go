@@ -0,0 +1,244 @@
+package datastore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Every .gob.zst file starts, inside the zstd stream, with a header naming
+// what it holds and the schema version it was written at:
+//
+//	"FRSV"   magic
+//	uint16   version, big endian
+//	uint8    length of the kind, then the kind
+//
+// followed by the gob payload. Files from before the header are version 0 and
+// their kind comes from the file name. On read an older payload is passed
+// through the registered migrations one version at a time, so the getters
+// only ever decode the current types. The JSON copies are not versioned.
+//
+// Changing a stored type means bumping its kind's version, keeping the old
+// type around unexported and registering a migration from it.
+
+var envelopeMagic = []byte("FRSV")
+
+var errUnknownSnapshot = errors.New("unknown snapshot kind")
+
+type snapshotKind struct {
+	name string
+	// prefixes identify files written before the header
+	prefixes []string
+	version  int
+	// target returns a pointer to a zero value of the current type
+	target func() any
+}
+
+var snapshotKinds = []snapshotKind{
+	{"agents", []string{"agents."}, 1, func() any { return &[]Agent{} }},
+	{"agentStatus", []string{"agentsStatus-"}, 1, func() any { return &[]AgentStatus{} }},
+	{"agentRollup", []string{rollupBasename("agents", TierHour) + "-", rollupBasename("agents", TierDay) + "-"}, 1, func() any { return &[]AgentRollup{} }},
+	{"construction", []string{"construction-"}, 2, func() any { return &[]JGConstruction{} }},
+	{"constructionRollup", []string{rollupBasename("construction", TierHour) + "-", rollupBasename("construction", TierDay) + "-"}, 2, func() any { return &[]ConstructionRollup{} }},
+	{"jumpgates", []string{"jumpgates."}, 1, func() any { return &[]JGInfo{} }},
+	{"stats", []string{"stats."}, 1, func() any { return &Stats{} }},
+	{"statsHistory", []string{"statsHistory-"}, 1, func() any { return &[]StatsPoint{} }},
+	{"leaderboard", []string{"leaderboard."}, 1, func() any { return &LeaderboardRecord{} }},
+	{"leaderboardHistory", []string{"leaderboardHistory-"}, 1, func() any { return &[]LeaderboardSnapshot{} }},
+	{"factions", []string{"factions."}, 1, func() any { return &[]Faction{} }},
+}
+
+func kindByName(name string) (snapshotKind, bool) {
+	for _, k := range snapshotKinds {
+		if k.name == name {
+			return k, true
+		}
+	}
+	return snapshotKind{}, false
+}
+
+// kindForFile picks the kind of a file without a header from its name
+func kindForFile(filename string) (snapshotKind, bool) {
+	for _, k := range snapshotKinds {
+		for _, p := range k.prefixes {
+			if strings.HasPrefix(filename, p) {
+				return k, true
+			}
+		}
+	}
+	return snapshotKind{}, false
+}
+
+// kindForValue picks the kind whose type v is, or points to
+func kindForValue(v any) (snapshotKind, bool) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for _, k := range snapshotKinds {
+		if reflect.TypeOf(k.target()).Elem() == t {
+			return k, true
+		}
+	}
+	return snapshotKind{}, false
+}
+
+// migration upgrades the gob payload of one kind from version from to from+1
+type migration struct {
+	kind    string
+	from    int
+	upgrade func(payload []byte) ([]byte, error)
+}
+
+var migrations = map[string]map[int]migration{}
+
+// registerMigration adds m to the registry. It panics on a duplicate so a
+// clash shows up as soon as the package loads
+func registerMigration(m migration) {
+	if migrations[m.kind] == nil {
+		migrations[m.kind] = make(map[int]migration)
+	}
+	if _, ok := migrations[m.kind][m.from]; ok {
+		panic(fmt.Sprintf("duplicate migration for %s from version %d", m.kind, m.from))
+	}
+	migrations[m.kind][m.from] = m
+}
+
+func writeEnvelope(w io.Writer, k snapshotKind) error {
+	header := make([]byte, 0, len(envelopeMagic)+3+len(k.name))
+	header = append(header, envelopeMagic...)
+	header = binary.BigEndian.AppendUint16(header, uint16(k.version))
+	header = append(header, byte(len(k.name)))
+	header = append(header, k.name...)
+	_, err := w.Write(header)
+	return err
+}
+
+// readEnvelope splits a decompressed file into its header and payload. A
+// file without a header is version 0 with no kind
+func readEnvelope(b []byte) (kind string, version int, payload []byte, err error) {
+	if !bytes.HasPrefix(b, envelopeMagic) {
+		return "", 0, b, nil
+	}
+	rest := b[len(envelopeMagic):]
+	if len(rest) < 3 || len(rest) < 3+int(rest[2]) {
+		return "", 0, nil, fmt.Errorf("truncated snapshot header")
+	}
+	version = int(binary.BigEndian.Uint16(rest))
+	n := int(rest[2])
+	return string(rest[3 : 3+n]), version, rest[3+n:], nil
+}
+
+// upgradePayload returns the payload of a decompressed file at the current
+// version of its kind, along with the kind and the version it was stored at
+func upgradePayload(filename string, b []byte) ([]byte, snapshotKind, int, error) {
+	name, version, payload, err := readEnvelope(b)
+	if err != nil {
+		return nil, snapshotKind{}, 0, err
+	}
+	var (
+		k  snapshotKind
+		ok bool
+	)
+	if name != "" {
+		k, ok = kindByName(name)
+	} else {
+		k, ok = kindForFile(filename)
+	}
+	if !ok {
+		return nil, snapshotKind{}, version, fmt.Errorf("%w: %s", errUnknownSnapshot, filename)
+	}
+	if version > k.version {
+		return nil, k, version, fmt.Errorf("%s is %s version %d, newer than this build's %d", filename, k.name, version, k.version)
+	}
+	for v := version; v < k.version; v++ {
+		m, ok := migrations[k.name][v]
+		if !ok {
+			return nil, k, version, fmt.Errorf("no migration for %s from version %d", k.name, v)
+		}
+		if payload, err = m.upgrade(payload); err != nil {
+			return nil, k, version, fmt.Errorf("migrating %s from version %d: %w", k.name, v, err)
+		}
+	}
+	return payload, k, version, nil
+}
+
+// decodePayload decodes a decompressed file into v, upgrading it first if it
+// was written at an older version
+func decodePayload(filename string, b []byte, v any) error {
+	payload, _, _, err := upgradePayload(filename, b)
+	if err != nil {
+		return err
+	}
+	return gob.NewDecoder(bytes.NewReader(payload)).Decode(v)
+}
+
+// encodeSnapshot writes v as zstd compressed gob behind a header for its kind
+func encodeSnapshot(w io.Writer, v any) error {
+	k, ok := kindForValue(v)
+	if !ok {
+		return fmt.Errorf("%w: %T", errUnknownSnapshot, v)
+	}
+	enc, err := zstd.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	if err := writeEnvelope(enc, k); err != nil {
+		enc.Close()
+		return err
+	}
+	if err := gob.NewEncoder(enc).Encode(v); err != nil {
+		enc.Close()
+		return err
+	}
+	return enc.Close()
+}
+
+func readSnapshotBytes(filename string) ([]byte, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return decompressSnapshot(countingReader{file})
+}
+
+func decompressSnapshot(r io.Reader) ([]byte, error) {
+	decoder, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+	b := new(bytes.Buffer)
+	if _, err := decoder.WriteTo(b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// ReadSnapshotFile decodes a .gob.zst file of any kind, upgraded to the
+// current version. The result is a pointer to the kind's type
+func ReadSnapshotFile(filename string) (any, error) {
+	b, err := readSnapshotBytes(filename)
+	if err != nil {
+		return nil, err
+	}
+	payload, k, _, err := upgradePayload(filepath.Base(filename), b)
+	if err != nil {
+		return nil, err
+	}
+	v := k.target()
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
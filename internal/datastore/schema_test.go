@@ -0,0 +1,273 @@
+package datastore
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateFixtures = flag.Bool("update-fixtures", false, "write missing fixture files for the current schema versions")
+
+// schemaFixtures is what every file under testdata/schema/v<N> holds,
+// decoded at the current version. The file names are the ones the store
+// writes so kinds of files without a header can be told from their name
+var schemaFixtures = map[string]any{
+	"agents.gob.zst": &[]Agent{
+		{Symbol: "ALPHA", Credits: 175000, Faction: "COSMIC", Headquarters: "X1-AB12-A1", System: "X1-AB12"},
+		{Symbol: "BRAVO", Credits: 250000, Faction: "VOID", Headquarters: "X1-CD34-B2", System: "X1-CD34"},
+	},
+	"agentsStatus-1767225600.gob.zst": &[]AgentStatus{
+		{Symbol: "ALPHA", Timestamp: 1767225600, Credits: 175000, Ships: 2},
+		{Symbol: "BRAVO", Timestamp: 1767225600, Credits: 250000, Ships: 3},
+	},
+	"agentsRollup1h-1767225600.gob.zst": &[]AgentRollup{
+		{Symbol: "ALPHA", Timestamp: 1767225600, LastTimestamp: 1767225900, Credits: Rollup{Min: 175000, Max: 176000, Last: 176000}, Ships: Rollup{Min: 2, Max: 2, Last: 2}},
+	},
+	"construction-1767225600.gob.zst": &[]JGConstruction{
+		{Timestamp: 1767225600, Jumpgate: "X1-AB12-I1", Materials: Materials{
+			{Symbol: "FAB_MATS", Required: 1600, Fulfilled: 400},
+			{Symbol: "ADVANCED_CIRCUITRY", Required: 400, Fulfilled: 100},
+		}},
+	},
+	"constructionRollup1d-1767225600.gob.zst": &[]ConstructionRollup{
+		{Jumpgate: "X1-AB12-I1", Timestamp: 1767225600, LastTimestamp: 1767229200, Materials: []MaterialRollup{
+			{Symbol: "FAB_MATS", Required: 1600, Fulfilled: Rollup{Min: 400, Max: 600, Last: 600}},
+			{Symbol: "ADVANCED_CIRCUITRY", Required: 400, Fulfilled: Rollup{Min: 100, Max: 120, Last: 120}},
+		}},
+	},
+	"jumpgates.gob.zst": &[]JGInfo{
+		{Jumpgate: "X1-AB12-I1", System: "X1-AB12", Headquarters: "X1-AB12-A1", Status: Const},
+	},
+	"stats.gob.zst": &Stats{
+		Reset: "2026-01-04", Agents: 2, Accounts: 2, Ships: 5, Systems: 12, Waypoints: 80,
+		Status: "ok", Version: "v2.3.0",
+		MarketUpdate: time.Date(2026, 1, 10, 11, 55, 0, 0, time.UTC),
+		NextReset:    time.Date(2026, 1, 11, 0, 0, 0, 0, time.UTC),
+		LastUpdate:   time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC),
+	},
+	"statsHistory-1767225600.gob.zst": &[]StatsPoint{
+		{Timestamp: 1767225600, Agents: 2, Accounts: 2, Ships: 5, Systems: 12, Waypoints: 80},
+	},
+	"leaderboard.gob.zst": &LeaderboardRecord{
+		CreditsList: []LeaderboardEntry{{Symbol: "BRAVO", Value: 250000}, {Symbol: "ALPHA", Value: 175000}},
+		ChartsList:  []LeaderboardEntry{{Symbol: "ALPHA", Value: 3}},
+	},
+	"leaderboardHistory-1767225600.gob.zst": &[]LeaderboardSnapshot{
+		{
+			Timestamp:   1767225600,
+			CreditsList: []LeaderboardEntry{{Symbol: "BRAVO", Value: 250000}, {Symbol: "ALPHA", Value: 175000}},
+			ChartsList:  []LeaderboardEntry{{Symbol: "ALPHA", Value: 3}},
+		},
+	},
+	"factions.gob.zst": &[]Faction{
+		{Reset: "2026-01-04", Symbol: "COSMIC", Name: "Cosmic Engineers", Headquarters: "X1-AB12-A1", IsRecruiting: true},
+	},
+}
+
+func fixtureVersions(t *testing.T) map[int]string {
+	dirs, err := filepath.Glob(filepath.Join("testdata", "schema", "v*"))
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	res := make(map[int]string)
+	for _, dir := range dirs {
+		v, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "v"))
+		if err != nil {
+			t.Fatalf("unexpected fixture directory %s", dir)
+		}
+		res[v] = dir
+	}
+	return res
+}
+
+func TestSchemaFixtures(t *testing.T) {
+	if *updateFixtures {
+		for name, want := range schemaFixtures {
+			k, _ := kindForValue(want)
+			dir := filepath.Join("testdata", "schema", fmt.Sprintf("v%d", k.version))
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatalf("MkdirAll: %v", err)
+			}
+			// fixtures from earlier runs stay as they were written
+			f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+			if os.IsExist(err) {
+				continue
+			}
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if err := encodeSnapshot(f, want); err != nil {
+				t.Fatalf("encodeSnapshot: %v", err)
+			}
+			f.Close()
+		}
+	}
+
+	versions := fixtureVersions(t)
+	// every kind has a fixture at every version it has been at
+	for _, k := range snapshotKinds {
+		for v := 0; v <= k.version; v++ {
+			dir, ok := versions[v]
+			found := false
+			if ok {
+				entries, _ := os.ReadDir(dir)
+				for _, e := range entries {
+					if fk, _ := kindForFile(e.Name()); fk.name == k.name {
+						found = true
+					}
+				}
+			}
+			if !found {
+				t.Errorf("no fixture for %s version %d, run go test -run TestSchemaFixtures -update-fixtures", k.name, v)
+			}
+		}
+	}
+
+	for v, dir := range versions {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("ReadDir: %v", err)
+		}
+		for _, e := range entries {
+			t.Run(fmt.Sprintf("v%d/%s", v, e.Name()), func(t *testing.T) {
+				filename := filepath.Join(dir, e.Name())
+				b, err := readSnapshotBytes(filename)
+				if err != nil {
+					t.Fatalf("readSnapshotBytes: %v", err)
+				}
+				if _, stored, _, _ := readEnvelope(b); stored != v {
+					t.Fatalf("fixture is version %d, not %d", stored, v)
+				}
+				got, err := ReadSnapshotFile(filename)
+				if err != nil {
+					t.Fatalf("ReadSnapshotFile: %v", err)
+				}
+				want, ok := schemaFixtures[e.Name()]
+				if !ok {
+					t.Fatalf("no expected value for %s", e.Name())
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("decoded %+v\nwant %+v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestReadEnvelope(t *testing.T) {
+	k, _ := kindByName("stats")
+	var b strings.Builder
+	writeEnvelope(&b, k)
+	b.WriteString("payload")
+
+	kind, version, payload, err := readEnvelope([]byte(b.String()))
+	if err != nil || kind != "stats" || version != k.version || string(payload) != "payload" {
+		t.Fatalf("readEnvelope = %q, %d, %q, %v", kind, version, payload, err)
+	}
+	if _, _, _, err := readEnvelope([]byte(b.String()[:6])); err == nil {
+		t.Fatalf("expected an error for a truncated header")
+	}
+	kind, version, payload, _ = readEnvelope([]byte("legacy"))
+	if kind != "" || version != 0 || string(payload) != "legacy" {
+		t.Fatalf("headerless payload read as %q version %d", kind, version)
+	}
+
+	// a file from a newer build is refused rather than misread
+	newer := k
+	newer.version++
+	var nb strings.Builder
+	writeEnvelope(&nb, newer)
+	if _, _, _, err := upgradePayload("stats.gob.zst", []byte(nb.String())); err == nil {
+		t.Fatalf("expected an error for a newer version")
+	}
+}
+
+func TestDiskStore_Migrate(t *testing.T) {
+	d, err := NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	if err := d.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	resetDir := filepath.Join(d.DataPath(), "2026-01-04")
+	src := fixtureVersions(t)[0]
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, e := range entries {
+		copyFile(t, filepath.Join(src, e.Name()), filepath.Join(resetDir, e.Name()))
+	}
+
+	// old files read through the getters before and after migrating
+	checkReads := func() {
+		t.Helper()
+		agents, err := d.GetAgentList("")
+		if err != nil || len(agents) != 2 {
+			t.Fatalf("GetAgentList = %v, %v", agents, err)
+		}
+		hist, err := d.GetAgentHistory("", 0, 0)
+		if err != nil || len(hist) != 2 {
+			t.Fatalf("GetAgentHistory = %v, %v", hist, err)
+		}
+		st, err := d.GetStats("")
+		if err != nil || st.Version != "v2.3.0" {
+			t.Fatalf("GetStats = %+v, %v", st, err)
+		}
+		cons, err := d.GetConstructions("", 0, 0)
+		if err != nil || len(cons) != 1 || cons[0].Materials.Get("ADVANCED_CIRCUITRY") != (Material{Symbol: "ADVANCED_CIRCUITRY", Required: 400, Fulfilled: 100}) {
+			t.Fatalf("GetConstructions = %+v, %v", cons, err)
+		}
+	}
+	checkReads()
+
+	pending, err := d.Migrate(true)
+	if err != nil || len(pending) != len(entries) {
+		t.Fatalf("dry run Migrate = %v, %v", pending, err)
+	}
+	done, err := d.Migrate(false)
+	if err != nil || len(done) != len(entries) {
+		t.Fatalf("Migrate = %v, %v", done, err)
+	}
+	for _, e := range entries {
+		b, err := readSnapshotBytes(filepath.Join(resetDir, e.Name()))
+		if err != nil {
+			t.Fatalf("readSnapshotBytes: %v", err)
+		}
+		k, _ := kindForFile(e.Name())
+		if kind, version, _, _ := readEnvelope(b); kind != k.name || version != k.version {
+			t.Fatalf("%s is %s version %d after migrating", e.Name(), kind, version)
+		}
+	}
+	d.ConfigureCache(0, 0)
+	checkReads()
+
+	if again, _ := d.Migrate(false); len(again) != 0 {
+		t.Fatalf("second Migrate rewrote %v", again)
+	}
+}
+
+func copyFile(t *testing.T, from, to string) {
+	t.Helper()
+	in, err := os.Open(from)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer in.Close()
+	out, err := os.Create(to)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package datastore
+
+import (
+	"sort"
+	"strconv"
+)
+
+// StatsRate is how fast the counts grew over the period starting at
+// Timestamp, per period
+type StatsRate struct {
+	Timestamp int64
+	Agents    float64
+	Accounts  float64
+	Ships     float64
+}
+
+func statsPoint(st Stats, now int64) StatsPoint {
+	return StatsPoint{
+		Timestamp: now,
+		Agents:    st.Agents,
+		Accounts:  st.Accounts,
+		Ships:     st.Ships,
+		Systems:   st.Systems,
+		Waypoints: st.Waypoints,
+	}
+}
+
+func statsKey(p StatsPoint) string {
+	return strconv.FormatInt(p.Timestamp, 10)
+}
+
+// filterStatsHistory keeps points inside [start, end] ordered by timestamp
+func filterStatsHistory(records []StatsPoint, start, end int64) []StatsPoint {
+	res := []StatsPoint{}
+	for _, r := range records {
+		if r.Timestamp >= start && r.Timestamp <= end {
+			res = append(res, r)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Timestamp < res[j].Timestamp
+	})
+	return res
+}
+
+// StatsRates turns history, oldest first, into growth per period of the
+// given length in seconds. Each period with points is measured from the
+// last point of the period before it, or from the very first point, to its
+// own last point. Growth over periods without points, when the collector
+// was down, is spread evenly over them
+func StatsRates(history []StatsPoint, period int64) []StatsRate {
+	res := []StatsRate{}
+	if len(history) == 0 || period <= 0 {
+		return res
+	}
+	type bucket struct {
+		start int64
+		last  StatsPoint
+	}
+	buckets := []bucket{}
+	for _, p := range history {
+		start := p.Timestamp - p.Timestamp%period
+		if n := len(buckets); n > 0 && buckets[n-1].start == start {
+			buckets[n-1].last = p
+			continue
+		}
+		buckets = append(buckets, bucket{start: start, last: p})
+	}
+	from, fromStart := history[0], buckets[0].start-period
+	for _, b := range buckets {
+		n := float64((b.start - fromStart) / period)
+		res = append(res, StatsRate{
+			Timestamp: b.start,
+			Agents:    float64(b.last.Agents-from.Agents) / n,
+			Accounts:  float64(b.last.Accounts-from.Accounts) / n,
+			Ships:     float64(b.last.Ships-from.Ships) / n,
+		})
+		from, fromStart = b.last, b.start
+	}
+	return res
+}
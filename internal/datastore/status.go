@@ -1,21 +1,17 @@
 package datastore
 
 import (
-	"encoding/gob"
 	"fmt"
-	"os"
-	"sort"
 	"time"
 
 	"github.com/papaburgs/fluffy-robot/internal/logging"
 )
 
-func StoreStats(r ResponseStatus) error {
+func statsRecord(r ResponseStatus) Stats {
 	st := Stats{
 		Reset:        r.ResetDate,
 		MarketUpdate: r.Health.LastMarketUpdate,
 		Agents:       r.Stats.Agents,
-		Accounts:     *r.Stats.Accounts,
 		Ships:        r.Stats.Ships,
 		Systems:      r.Stats.Systems,
 		Waypoints:    r.Stats.Waypoints,
@@ -24,11 +20,13 @@ func StoreStats(r ResponseStatus) error {
 		NextReset:    r.ServerResets.Next,
 		LastUpdate:   time.Now(),
 	}
-	writeData("stats", 0, st)
-	return nil
+	if r.Stats.Accounts != nil {
+		st.Accounts = *r.Stats.Accounts
+	}
+	return st
 }
 
-func StoreLeaderboards(r ResponseStatus) error {
+func leaderboardRecord(r ResponseStatus) LeaderboardRecord {
 	ldrbd := LeaderboardRecord{}
 	ldrbd.ChartsList = []LeaderboardEntry{}
 	for _, x := range r.Leaderboards.MostSubmittedCharts {
@@ -48,15 +46,32 @@ func StoreLeaderboards(r ResponseStatus) error {
 			},
 		)
 	}
+	return ldrbd
+}
 
-	writeData("leaderboard", 0, ldrbd)
+func (d *DiskStore) StoreStats(r ResponseStatus, now int64) error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	st := statsRecord(r)
+	if err := d.writeData("stats", 0, st); err != nil {
+		return err
+	}
+	return d.writeData("statsHistory", now, []StatsPoint{statsPoint(st, now)})
+}
 
-	return nil
+func (d *DiskStore) StoreLeaderboards(r ResponseStatus, now int64) error {
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+	ldrbd := leaderboardRecord(r)
+	if err := d.writeData("leaderboard", 0, ldrbd); err != nil {
+		return err
+	}
+	return d.writeData("leaderboardHistory", now, []LeaderboardSnapshot{leaderboardSnapshot(ldrbd, now)})
 }
 
-func GetStats(thisReset Reset) (Stats, error) {
+func (d *DiskStore) GetStats(thisReset Reset) (Stats, error) {
 	res := Stats{}
-	m, err := readData("stats.", "")
+	m, err := readDecoded[Stats](d, "stats.", thisReset)
 	if err != nil {
 		return res, err
 	}
@@ -65,19 +80,33 @@ func GetStats(thisReset Reset) (Stats, error) {
 		return res, fmt.Errorf("invalid read")
 	}
 
-	for _, b := range m {
-		gobDec := gob.NewDecoder(b)
-		if err := gobDec.Decode(&res); err != nil {
-			return res, err
-		}
+	for _, v := range m {
+		res = v
 	}
 	m = nil
 	return res, nil
 }
 
-func GetLeaderboard(thisReset Reset) ([]LeaderboardEntry, []LeaderboardEntry, error) {
+func (d *DiskStore) GetStatsHistory(thisReset Reset, start, end int64) ([]StatsPoint, error) {
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	m, err := readDecoded[[]StatsPoint](d, "statsHistory-", thisReset)
+	if err != nil {
+		logging.Error("Failed to load stats history:", err)
+		return []StatsPoint{}, err
+	}
+	allRecords := make([]StatsPoint, 0, len(m))
+	for _, v := range m {
+		allRecords = append(allRecords, v...)
+	}
+	m = nil
+	return filterStatsHistory(allRecords, start, end), nil
+}
+
+func (d *DiskStore) GetLeaderboard(thisReset Reset) ([]LeaderboardEntry, []LeaderboardEntry, error) {
 	res := LeaderboardRecord{}
-	m, err := readData("leaderboard.", thisReset)
+	m, err := readDecoded[LeaderboardRecord](d, "leaderboard.", thisReset)
 	if err != nil {
 		logging.Error("Failed to read file", err)
 		return nil, nil, err
@@ -87,61 +116,28 @@ func GetLeaderboard(thisReset Reset) ([]LeaderboardEntry, []LeaderboardEntry, er
 		return nil, nil, fmt.Errorf("invalid read")
 	}
 
-	for _, b := range m {
-		gobDec := gob.NewDecoder(b)
-		if err := gobDec.Decode(&res); err != nil {
-			logging.Error("error decoding gob", err)
-			return nil, nil, err
-		}
+	for _, v := range m {
+		res = v
 	}
 	m = nil
-	return res.CreditsList, res.ChartsList, nil
+	return append([]LeaderboardEntry{}, res.CreditsList...),
+		append([]LeaderboardEntry{}, res.ChartsList...),
+		nil
 }
 
-func AllResets() []string {
-	resets := []string{}
-	files, err := os.ReadDir(path)
-	if err != nil {
-		logging.Error("Failed to read resets directory", err)
-		return resets
+func (d *DiskStore) GetLeaderboardHistory(thisReset Reset, start, end int64) ([]LeaderboardSnapshot, error) {
+	if end == 0 {
+		end = time.Now().Unix()
 	}
-
-	for _, f := range files {
-		if f.IsDir() {
-			resets = append(resets, f.Name())
-		}
-	}
-	sort.Slice(resets, func(i, j int) bool {
-		return resets[i] > resets[j]
-	})
-	return resets
-}
-
-func LatestReset() Reset {
-	for {
-		if currentReset == "" {
-			// logging.Debug("reset is not updated yet")
-			time.Sleep(time.Second)
-		} else {
-			break
-		}
-	}
-	return currentReset
-}
-
-func NextReset() time.Time {
-	for {
-		if currentReset == "" {
-			// logging.Debug("reset is not updated yet")
-			time.Sleep(time.Second)
-		} else {
-			break
-		}
-	}
-	st, err := GetStats(currentReset)
+	m, err := readDecoded[[]LeaderboardSnapshot](d, "leaderboardHistory-", thisReset)
 	if err != nil {
-		logging.Error("error loading stats for NextReset", err)
-		return time.Time{}
+		logging.Error("Failed to load leaderboard history:", err)
+		return []LeaderboardSnapshot{}, err
 	}
-	return st.NextReset
+	allRecords := make([]LeaderboardSnapshot, 0, len(m))
+	for _, v := range m {
+		allRecords = append(allRecords, v...)
+	}
+	m = nil
+	return filterLeaderboardHistory(allRecords, start, end), nil
 }
@@ -0,0 +1,85 @@
+package datastore
+
+import (
+	"time"
+
+	"github.com/papaburgs/fluffy-robot/internal/logging"
+)
+
+// Store is the storage backend used by the collector and the frontend.
+// DiskStore keeps the gob+zstd files on disk, BoltStore moves the history
+// into an indexed bbolt database, MemoryStore keeps everything in maps and
+// is used for tests and throwaway instances.
+type Store interface {
+	// UpdateReset switches the store to reset r, all Store* calls write to it
+	UpdateReset(r Reset) error
+	// CurrentReset returns the reset being written to, empty until the first UpdateReset
+	CurrentReset() Reset
+	// AllResets returns every reset that has data, newest first
+	AllResets() []string
+
+	StoreAgents(apiAgents []PublicAgent, now int64) error
+	GetAgentList(thisReset Reset) ([]Agent, error)
+	GetAgentHistory(thisReset Reset, start, end int64) ([]AgentStatus, error)
+	// GetAgentHistoryFor is GetAgentHistory limited to the given agent symbols
+	GetAgentHistoryFor(thisReset Reset, symbols []string, start, end int64) ([]AgentStatus, error)
+	// GetAgentRollups returns one row per agent and tier bucket overlapping
+	// [start, end], nil symbols returns every agent
+	GetAgentRollups(thisReset Reset, symbols []string, tier Tier, start, end int64) ([]AgentRollup, error)
+
+	StoreFactions(fac []Faction) error
+	GetFactions(thisReset Reset) ([]Faction, error)
+
+	UpdateJumpGates(jgList []JGInfo) error
+	// ChangeJumpGates replaces the current reset's jumpgate list with what
+	// change makes of it, with no other write in between. change must not
+	// call the store
+	ChangeJumpGates(change func(current []JGInfo) []JGInfo) error
+	GetJumpgateList(thisReset Reset) ([]JGInfo, error)
+	AddConstructions(cList []JGConstruction, ts int64) error
+	GetConstructions(thisReset Reset, start, end int64) ([]JGConstruction, error)
+	// GetConstructionsFor is GetConstructions limited to the given jumpgate symbols
+	GetConstructionsFor(thisReset Reset, jumpgates []string, start, end int64) ([]JGConstruction, error)
+	// GetConstructionRollups is GetAgentRollups for construction progress
+	GetConstructionRollups(thisReset Reset, jumpgates []string, tier Tier, start, end int64) ([]ConstructionRollup, error)
+	// WalkAgentHistory calls fn with each record in [start, end] oldest
+	// first, without holding the whole range in memory. nil symbols is every
+	// agent. An error from fn stops the walk and is returned
+	WalkAgentHistory(thisReset Reset, symbols []string, start, end int64, fn func(AgentStatus) error) error
+	// WalkConstructions is WalkAgentHistory for construction progress
+	WalkConstructions(thisReset Reset, jumpgates []string, start, end int64, fn func(JGConstruction) error) error
+
+	// StoreStats replaces the current stats and adds the counts, stamped
+	// now, to the stats history
+	StoreStats(r ResponseStatus, now int64) error
+	GetStats(thisReset Reset) (Stats, error)
+	// GetStatsHistory returns the counts stored in [start, end], oldest first
+	GetStatsHistory(thisReset Reset, start, end int64) ([]StatsPoint, error)
+	// StoreLeaderboards replaces the current leaderboards and keeps a copy
+	// stamped now in the leaderboard history
+	StoreLeaderboards(r ResponseStatus, now int64) error
+	GetLeaderboard(thisReset Reset) ([]LeaderboardEntry, []LeaderboardEntry, error)
+	// GetLeaderboardHistory returns the leaderboards stored in [start, end],
+	// oldest first
+	GetLeaderboardHistory(thisReset Reset, start, end int64) ([]LeaderboardSnapshot, error)
+}
+
+// NextReset is when the current reset ends, zero while it is not known
+func NextReset(s Store) time.Time {
+	current := s.CurrentReset()
+	if current == "" {
+		return time.Time{}
+	}
+	st, err := s.GetStats(current)
+	if err != nil {
+		logging.Error("error loading stats for NextReset", err)
+		return time.Time{}
+	}
+	return st.NextReset
+}
+
+var (
+	_ Store = (*DiskStore)(nil)
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*BoltStore)(nil)
+)
@@ -0,0 +1,448 @@
+package datastore
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testStores(t *testing.T) map[string]Store {
+	disk, err := NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	bolt, err := NewBoltStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	t.Cleanup(func() { bolt.Close() })
+	return map[string]Store{
+		"memory": NewMemoryStore(),
+		"disk":   disk,
+		"bolt":   bolt,
+	}
+}
+
+func TestStore_AgentsRoundTrip(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := s.UpdateReset("2026-01-04"); err != nil {
+				t.Fatalf("UpdateReset: %v", err)
+			}
+			now := time.Now().Add(-time.Hour).Unix()
+			agents := []PublicAgent{
+				{Symbol: "ALPHA", Credits: 175000, Headquarters: "X1-AB12-A1", ShipCount: 2, StartingFaction: "COSMIC"},
+				{Symbol: "BRAVO", Credits: 250000, Headquarters: "X1-CD34-B2", ShipCount: 3, StartingFaction: "VOID"},
+			}
+			if err := s.StoreAgents(agents, now); err != nil {
+				t.Fatalf("StoreAgents: %v", err)
+			}
+			agents[1].Credits = 300000
+			if err := s.StoreAgents(agents, now+300); err != nil {
+				t.Fatalf("StoreAgents: %v", err)
+			}
+
+			list, err := s.GetAgentList(s.CurrentReset())
+			if err != nil {
+				t.Fatalf("GetAgentList: %v", err)
+			}
+			if len(list) != 2 {
+				t.Fatalf("expected 2 agents, got %d", len(list))
+			}
+			for _, a := range list {
+				if a.Symbol == "BRAVO" && (a.Credits != 300000 || a.System != "X1-CD34") {
+					t.Fatalf("unexpected agent record: %+v", a)
+				}
+			}
+
+			hist, err := s.GetAgentHistory(s.CurrentReset(), 0, 0)
+			if err != nil {
+				t.Fatalf("GetAgentHistory: %v", err)
+			}
+			if len(hist) != 4 {
+				t.Fatalf("expected 4 history records, got %d", len(hist))
+			}
+			if hist[0].Timestamp > hist[len(hist)-1].Timestamp {
+				t.Fatalf("history is not sorted by timestamp")
+			}
+
+			hist, _ = s.GetAgentHistory(s.CurrentReset(), now+1, 0)
+			if len(hist) != 2 {
+				t.Fatalf("expected 2 records after start filter, got %d", len(hist))
+			}
+
+			hist, err = s.GetAgentHistoryFor(s.CurrentReset(), []string{"BRAVO"}, 0, 0)
+			if err != nil {
+				t.Fatalf("GetAgentHistoryFor: %v", err)
+			}
+			if len(hist) != 2 || hist[0].Credits != 250000 || hist[1].Credits != 300000 {
+				t.Fatalf("unexpected history for BRAVO: %+v", hist)
+			}
+		})
+	}
+}
+
+func TestStore_Constructions(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := s.UpdateReset("2026-01-04"); err != nil {
+				t.Fatalf("UpdateReset: %v", err)
+			}
+			now := time.Now().Add(-time.Hour).Unix()
+			for i := int64(0); i < 3; i++ {
+				err := s.AddConstructions([]JGConstruction{
+					{Timestamp: now + i*60, Jumpgate: "X1-AB12-I1", Materials: Materials{{Symbol: "FAB_MATS", Required: 1600, Fulfilled: int(i * 100)}}},
+					{Timestamp: now + i*60, Jumpgate: "X1-CD34-I1", Materials: Materials{{Symbol: "ADVANCED_CIRCUITRY", Required: 400, Fulfilled: int(i * 10)}}},
+				}, now+i*60)
+				if err != nil {
+					t.Fatalf("AddConstructions: %v", err)
+				}
+			}
+			all, err := s.GetConstructions(s.CurrentReset(), 0, 0)
+			if err != nil {
+				t.Fatalf("GetConstructions: %v", err)
+			}
+			if len(all) != 6 {
+				t.Fatalf("expected 6 construction records, got %d", len(all))
+			}
+			one, err := s.GetConstructionsFor(s.CurrentReset(), []string{"X1-AB12-I1"}, now+60, 0)
+			if err != nil {
+				t.Fatalf("GetConstructionsFor: %v", err)
+			}
+			if len(one) != 2 || one[1].Materials.Get("FAB_MATS").Fulfilled != 200 {
+				t.Fatalf("unexpected constructions for X1-AB12-I1: %+v", one)
+			}
+		})
+	}
+}
+
+func TestStore_Walk(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := s.UpdateReset("2026-01-04"); err != nil {
+				t.Fatalf("UpdateReset: %v", err)
+			}
+			// out of order and more than a day apart, so bolt needs several reads
+			day := int64(1767528000)
+			for _, ts := range []int64{day + 3*86400, day, day + 60} {
+				agents := []PublicAgent{{Symbol: "ALPHA", Credits: ts, Headquarters: "X1-AB12-A1"}, {Symbol: "BRAVO", Credits: ts, Headquarters: "X1-CD34-B2"}}
+				if err := s.StoreAgents(agents, ts); err != nil {
+					t.Fatalf("StoreAgents: %v", err)
+				}
+				err := s.AddConstructions([]JGConstruction{{Timestamp: ts, Jumpgate: "X1-AB12-I1", Materials: Materials{{Symbol: "FAB_MATS", Required: 1600}}}}, ts)
+				if err != nil {
+					t.Fatalf("AddConstructions: %v", err)
+				}
+			}
+
+			var walked []int64
+			err := s.WalkAgentHistory("", nil, 0, 0, func(a AgentStatus) error {
+				walked = append(walked, a.Timestamp)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("WalkAgentHistory: %v", err)
+			}
+			if want := []int64{day, day, day + 60, day + 60, day + 3*86400, day + 3*86400}; !reflect.DeepEqual(walked, want) {
+				t.Fatalf("walked %v, want %v", walked, want)
+			}
+
+			walked = nil
+			err = s.WalkAgentHistory("", []string{"BRAVO"}, day+1, day+3*86400, func(a AgentStatus) error {
+				if a.Symbol != "BRAVO" {
+					t.Fatalf("walked %s", a.Symbol)
+				}
+				walked = append(walked, a.Timestamp)
+				return nil
+			})
+			if err != nil || !reflect.DeepEqual(walked, []int64{day + 60, day + 3*86400}) {
+				t.Fatalf("walked %v, %v", walked, err)
+			}
+
+			// an error from fn stops the walk
+			var n int
+			err = s.WalkConstructions("", []string{"X1-AB12-I1"}, 0, 0, func(JGConstruction) error {
+				n++
+				return errStopWalk
+			})
+			if err != errStopWalk || n != 1 {
+				t.Fatalf("expected the walk to stop after one record, got %d records and %v", n, err)
+			}
+		})
+	}
+}
+
+var errStopWalk = errors.New("stop")
+
+// an import can leave a tick file inside a compacted day, the walk still
+// has to come out in order
+func TestDiskStore_WalkOverlappingSegments(t *testing.T) {
+	d, err := NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	if err := d.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	day := int64(1767484800)
+	for _, ts := range []int64{day + 600, day + 7200} {
+		if err := d.StoreAgents([]PublicAgent{{Symbol: "ALPHA", Credits: ts, Headquarters: "X1-AB12-A1"}}, ts); err != nil {
+			t.Fatalf("StoreAgents: %v", err)
+		}
+	}
+	d.Compact(time.Unix(day+3*86400, 0))
+	if err := d.StoreAgents([]PublicAgent{{Symbol: "ALPHA", Credits: day + 3600, Headquarters: "X1-AB12-A1"}}, day+3600); err != nil {
+		t.Fatalf("StoreAgents: %v", err)
+	}
+
+	var walked []int64
+	err = d.WalkAgentHistory("", nil, 0, 0, func(a AgentStatus) error {
+		walked = append(walked, a.Timestamp)
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(walked, []int64{day + 600, day + 3600, day + 7200}) {
+		t.Fatalf("walked %v, %v", walked, err)
+	}
+}
+
+func TestBoltStore_Backfill(t *testing.T) {
+	dir := t.TempDir()
+	disk, err := NewDiskStore(dir, false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	if err := disk.UpdateReset("2025-12-28"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	now := time.Now().Add(-time.Hour).Unix()
+	agents := []PublicAgent{{Symbol: "ALPHA", Credits: 200000, Headquarters: "X1-AB12-A1", ShipCount: 2}}
+	for i := int64(0); i < 3; i++ {
+		if err := disk.StoreAgents(agents, now+i*300); err != nil {
+			t.Fatalf("StoreAgents: %v", err)
+		}
+	}
+
+	b, err := NewBoltStore(dir, false)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	defer b.Close()
+	hist, err := b.GetAgentHistoryFor("2025-12-28", []string{"ALPHA"}, 0, 0)
+	if err != nil {
+		t.Fatalf("GetAgentHistoryFor: %v", err)
+	}
+	if len(hist) != 3 {
+		t.Fatalf("expected 3 backfilled records, got %d", len(hist))
+	}
+}
+
+func TestStore_JumpgateHelpers(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := s.UpdateReset("2026-01-04"); err != nil {
+				t.Fatalf("UpdateReset: %v", err)
+			}
+			err := s.UpdateJumpGates([]JGInfo{
+				{System: "X1-AB12", Jumpgate: "X1-AB12-I1", Status: Active},
+				{System: "X1-CD34", Jumpgate: "X1-CD34-I1", Status: Const},
+			})
+			if err != nil {
+				t.Fatalf("UpdateJumpGates: %v", err)
+			}
+
+			if got := GetJumpgatesNotStarted(s, s.CurrentReset()); len(got) != 1 {
+				t.Fatalf("expected 1 active jumpgate, got %d", len(got))
+			}
+			if err := MarkJumpgatesStarted(s, []string{"X1-AB12"}); err != nil {
+				t.Fatalf("MarkJumpgatesStarted: %v", err)
+			}
+			if got := GetJumpgatesUnderConst(s, s.CurrentReset()); len(got) != 2 {
+				t.Fatalf("expected 2 jumpgates under construction, got %d", len(got))
+			}
+			if err := MarkJumpgatesComplete(s, []string{"X1-CD34"}, 1234); err != nil {
+				t.Fatalf("MarkJumpgatesComplete: %v", err)
+			}
+			complete := GetJumpgatesComplete(s, s.CurrentReset())
+			if len(complete) != 1 || complete[0].Complete != 1234 {
+				t.Fatalf("unexpected completed jumpgates: %+v", complete)
+			}
+		})
+	}
+}
+
+func TestStore_ReadsOlderResets(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for i, r := range []Reset{"2025-12-28", "2026-01-04"} {
+				if err := s.UpdateReset(r); err != nil {
+					t.Fatalf("UpdateReset: %v", err)
+				}
+				var status ResponseStatus
+				status.ResetDate = string(r)
+				status.Stats.Agents = 10 * (i + 1)
+				if err := s.StoreStats(status, time.Now().Add(-time.Minute).Unix()); err != nil {
+					t.Fatalf("StoreStats: %v", err)
+				}
+				agents := []PublicAgent{{Symbol: "ALPHA", Credits: int64(100000 * (i + 1)), Headquarters: "X1-AB12-A1", ShipCount: 2}}
+				if err := s.StoreAgents(agents, time.Now().Add(-time.Hour).Unix()); err != nil {
+					t.Fatalf("StoreAgents: %v", err)
+				}
+				if err := s.UpdateJumpGates([]JGInfo{{Jumpgate: "X1-AB12-I1", System: "X1-AB12", Status: ConstructionStatus(i + 1)}}); err != nil {
+					t.Fatalf("UpdateJumpGates: %v", err)
+				}
+			}
+
+			st, err := s.GetStats("2025-12-28")
+			if err != nil || st.Agents != 10 {
+				t.Fatalf("expected stats from the older reset, got %+v (%v)", st, err)
+			}
+			if jg := GetJumpgates(s, "2025-12-28")["X1-AB12"]; jg.Status != Active {
+				t.Fatalf("expected jumpgate from the older reset, got %+v", jg)
+			}
+
+			summaries := AgentAcrossResets(s, "ALPHA")
+			if len(summaries) != 2 {
+				t.Fatalf("expected 2 resets, got %d", len(summaries))
+			}
+			if summaries[0].Reset != "2026-01-04" || summaries[0].Credits != 200000 || summaries[1].Credits != 100000 {
+				t.Fatalf("unexpected summaries: %+v", summaries)
+			}
+			if !summaries[1].Found || summaries[1].Ships != 2 || summaries[1].Jumpgate != Active {
+				t.Fatalf("unexpected older summary: %+v", summaries[1])
+			}
+			if got := AgentAcrossResets(s, "NOBODY"); got[0].Found || got[1].Found {
+				t.Fatalf("unknown agent should not be found: %+v", got)
+			}
+		})
+	}
+}
+
+// leaderboardStatus is a status response with the credits board in the given order
+func leaderboardStatus(symbols ...string) ResponseStatus {
+	var r ResponseStatus
+	for i, s := range symbols {
+		r.Leaderboards.MostCredits = append(r.Leaderboards.MostCredits, struct {
+			AgentSymbol string `json:"agentSymbol"`
+			Credits     int64  `json:"credits"`
+		}{AgentSymbol: s, Credits: int64(1000 * (len(symbols) - i))})
+	}
+	r.Leaderboards.MostSubmittedCharts = append(r.Leaderboards.MostSubmittedCharts, struct {
+		AgentSymbol string `json:"agentSymbol"`
+		ChartCount  int    `json:"chartCount"`
+	}{AgentSymbol: symbols[0], ChartCount: 3})
+	return r
+}
+
+func TestStore_LeaderboardHistory(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := s.UpdateReset("2026-01-04"); err != nil {
+				t.Fatalf("UpdateReset: %v", err)
+			}
+			now := time.Now().Add(-2 * time.Hour).Unix()
+			if err := s.StoreLeaderboards(leaderboardStatus("ALPHA", "BRAVO"), now); err != nil {
+				t.Fatalf("StoreLeaderboards: %v", err)
+			}
+			if err := s.StoreLeaderboards(leaderboardStatus("BRAVO", "ALPHA", "CHARLIE"), now+3600); err != nil {
+				t.Fatalf("StoreLeaderboards: %v", err)
+			}
+
+			credits, _, err := s.GetLeaderboard(s.CurrentReset())
+			if err != nil || len(credits) != 3 || credits[0].Symbol != "BRAVO" {
+				t.Fatalf("current leaderboard is not the latest: %+v %v", credits, err)
+			}
+
+			hist, err := s.GetLeaderboardHistory(s.CurrentReset(), 0, 0)
+			if err != nil {
+				t.Fatalf("GetLeaderboardHistory: %v", err)
+			}
+			if len(hist) != 2 || hist[0].Timestamp != now || hist[1].Timestamp != now+3600 {
+				t.Fatalf("unexpected history: %+v", hist)
+			}
+			if hist, _ = s.GetLeaderboardHistory(s.CurrentReset(), now+1, 0); len(hist) != 1 {
+				t.Fatalf("expected 1 snapshot after start filter, got %d", len(hist))
+			}
+
+			points, err := RankHistory(s, s.CurrentReset(), []string{"ALPHA", "CHARLIE"}, 0, 0)
+			if err != nil {
+				t.Fatalf("RankHistory: %v", err)
+			}
+			want := []RankPoint{
+				{Symbol: "ALPHA", Timestamp: now, CreditsRank: 1, ChartsRank: 1},
+				{Symbol: "CHARLIE", Timestamp: now},
+				{Symbol: "ALPHA", Timestamp: now + 3600, CreditsRank: 2},
+				{Symbol: "CHARLIE", Timestamp: now + 3600, CreditsRank: 3},
+			}
+			if !reflect.DeepEqual(points, want) {
+				t.Fatalf("RankHistory = %+v, want %+v", points, want)
+			}
+		})
+	}
+}
+
+func TestLeaderboardAt(t *testing.T) {
+	hist := []LeaderboardSnapshot{{Timestamp: 100}, {Timestamp: 200}, {Timestamp: 300}}
+	for ts, want := range map[int64]int64{99: 0, 100: 100, 250: 200, 1000: 300} {
+		got, ok := LeaderboardAt(hist, ts)
+		if ok != (want != 0) || got.Timestamp != want {
+			t.Errorf("LeaderboardAt(%d) = %d %v, want %d", ts, got.Timestamp, ok, want)
+		}
+	}
+}
+
+func TestStore_StatsHistory(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := s.UpdateReset("2026-01-04"); err != nil {
+				t.Fatalf("UpdateReset: %v", err)
+			}
+			now := time.Now().Add(-2 * time.Hour).Unix()
+			for i, agents := range []int{10, 25} {
+				var status ResponseStatus
+				status.ResetDate = "2026-01-04"
+				status.Stats.Agents = agents
+				status.Stats.Ships = 2 * agents
+				if err := s.StoreStats(status, now+int64(i)*3600); err != nil {
+					t.Fatalf("StoreStats: %v", err)
+				}
+			}
+			if st, err := s.GetStats(""); err != nil || st.Agents != 25 {
+				t.Fatalf("current stats are not the latest: %+v %v", st, err)
+			}
+			hist, err := s.GetStatsHistory("", 0, 0)
+			if err != nil {
+				t.Fatalf("GetStatsHistory: %v", err)
+			}
+			want := []StatsPoint{{Timestamp: now, Agents: 10, Ships: 20}, {Timestamp: now + 3600, Agents: 25, Ships: 50}}
+			if !reflect.DeepEqual(hist, want) {
+				t.Fatalf("GetStatsHistory = %+v, want %+v", hist, want)
+			}
+			if hist, _ = s.GetStatsHistory("", now+1, 0); len(hist) != 1 {
+				t.Fatalf("expected 1 point after start filter, got %d", len(hist))
+			}
+		})
+	}
+}
+
+func TestStatsRates(t *testing.T) {
+	hour := int64(1767225600)
+	history := []StatsPoint{
+		{Timestamp: hour, Agents: 10},
+		{Timestamp: hour + 1800, Agents: 14, Ships: 4},
+		{Timestamp: hour + 3600, Agents: 20, Ships: 4},
+		// two hours without points
+		{Timestamp: hour + 4*3600, Agents: 26, Ships: 10},
+	}
+	want := []StatsRate{
+		{Timestamp: hour, Agents: 4, Ships: 4},
+		{Timestamp: hour + 3600, Agents: 6},
+		{Timestamp: hour + 4*3600, Agents: 2, Ships: 2},
+	}
+	if got := StatsRates(history, 3600); !reflect.DeepEqual(got, want) {
+		t.Fatalf("StatsRates = %+v, want %+v", got, want)
+	}
+	if got := StatsRates(nil, 3600); len(got) != 0 {
+		t.Fatalf("StatsRates of nothing = %+v", got)
+	}
+}
@@ -70,6 +70,13 @@ type LeaderboardRecord struct {
 	ChartsList  []LeaderboardEntry
 }
 
+// LeaderboardSnapshot is both leaderboards as they were at Timestamp
+type LeaderboardSnapshot struct {
+	Timestamp   int64
+	CreditsList []LeaderboardEntry
+	ChartsList  []LeaderboardEntry
+}
+
 // ***********  Agent types *************** \\
 // Public agent is the type that is returned from the api
 type PublicAgent struct {
@@ -125,6 +132,16 @@ type Stats struct {
 	LastUpdate   time.Time
 }
 
+// StatsPoint is the server's counts at Timestamp
+type StatsPoint struct {
+	Timestamp int64
+	Agents    int
+	Accounts  int
+	Ships     int
+	Systems   int
+	Waypoints int
+}
+
 type JGInfo struct {
 	Jumpgate     string
 	System       string
@@ -133,11 +150,50 @@ type JGInfo struct {
 	Complete     int64
 }
 
+// Material is one line of a jumpgate's construction recipe
+type Material struct {
+	Symbol    string
+	Required  int
+	Fulfilled int
+}
+
+// Materials is a recipe in the order the API lists it
+type Materials []Material
+
+// Get returns the material with symbol, or a zero Material
+func (m Materials) Get(symbol string) Material {
+	for _, x := range m {
+		if x.Symbol == symbol {
+			return x
+		}
+	}
+	return Material{}
+}
+
+// Started is true once anything has been delivered
+func (m Materials) Started() bool {
+	for _, x := range m {
+		if x.Fulfilled > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Completed returns a copy with every material fulfilled
+func (m Materials) Completed() Materials {
+	res := make(Materials, len(m))
+	for i, x := range m {
+		x.Fulfilled = x.Required
+		res[i] = x
+	}
+	return res
+}
+
 type JGConstruction struct {
 	Timestamp int64
 	Jumpgate  string
-	Fabmat    int
-	Advcct    int
+	Materials Materials
 }
 
 type JumpGateAgentListStruct struct {
@@ -148,7 +204,6 @@ type JumpGateAgentListStruct struct {
 type ConstructionOverview struct {
 	Agent     string
 	Jumpgate  string
-	Fabmat    int
-	Advcct    int
+	Materials Materials
 	Timestamp time.Time
 }
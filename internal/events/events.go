@@ -0,0 +1,87 @@
+// Package events carries notices from the collector to the frontend, so
+// open pages can reload when there is new data instead of waiting for the
+// user to click.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/papaburgs/fluffy-robot/internal/metrics"
+)
+
+type Type string
+
+const (
+	// AgentsUpdated follows an agent tick, which also stores stats and the
+	// leaderboards
+	AgentsUpdated Type = "agents-updated"
+	// ConstructionUpdated follows a jumpgate construction check
+	ConstructionUpdated Type = "construction-updated"
+	// ResetDetected is sent when the server reports a new reset
+	ResetDetected Type = "reset-detected"
+)
+
+type Event struct {
+	Type  Type      `json:"type"`
+	Reset string    `json:"reset"`
+	Time  time.Time `json:"time"`
+}
+
+// subscriberBuffer is how many events a subscriber can fall behind by
+// before it misses some
+const subscriberBuffer = 16
+
+// Bus fans every published event out to all subscribers. Publish never
+// blocks: a subscriber whose buffer is full misses the event, which is fine
+// for notices that only say "reload". A nil *Bus drops everything
+type Bus struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[chan Event]struct{})}
+}
+
+func (b *Bus) Publish(t Type, reset string) {
+	if b == nil {
+		return
+	}
+	e := Event{Type: t, Reset: reset, Time: time.Now()}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			metrics.EventsDropped.Add(1)
+		}
+	}
+	metrics.EventsPublished.Add(1)
+}
+
+// Subscribe returns a channel of every event published from now on and a
+// func to stop, which closes the channel
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Subscribers is how many subscriptions are open
+func (b *Bus) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
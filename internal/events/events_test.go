@@ -0,0 +1,40 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestBus(t *testing.T) {
+	b := NewBus()
+	a, stopA := b.Subscribe()
+	c, stopC := b.Subscribe()
+	defer stopC()
+
+	b.Publish(AgentsUpdated, "2026-01-04")
+	for _, ch := range []<-chan Event{a, c} {
+		e := <-ch
+		if e.Type != AgentsUpdated || e.Reset != "2026-01-04" || e.Time.IsZero() {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	}
+
+	stopA()
+	stopA()
+	if _, ok := <-a; ok {
+		t.Fatalf("expected the channel to be closed")
+	}
+	if n := b.Subscribers(); n != 1 {
+		t.Fatalf("expected 1 subscriber, got %d", n)
+	}
+
+	// a subscriber that does not read misses events instead of blocking
+	for i := 0; i < subscriberBuffer+5; i++ {
+		b.Publish(ConstructionUpdated, "2026-01-04")
+	}
+	if len(c) != subscriberBuffer {
+		t.Fatalf("expected a full buffer, got %d", len(c))
+	}
+
+	var none *Bus
+	none.Publish(ResetDetected, "2026-01-11")
+}
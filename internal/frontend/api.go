@@ -0,0 +1,521 @@
+package frontend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+	"github.com/papaburgs/fluffy-robot/internal/logging"
+	"github.com/papaburgs/fluffy-robot/internal/metrics"
+)
+
+// The /api/v1 endpoints serve the dashboard's data as JSON. Lists come back
+// as {"data": [...], "meta": {...}} and are paged with limit and offset,
+// errors as {"error": "..."}. Every endpoint takes reset (default newest),
+// most take start/end (unix seconds or RFC 3339) and agents (comma separated).
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+type APIMeta struct {
+	Reset  string `json:"reset"`
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	// Tier is the resolution of history endpoints
+	Tier string `json:"tier,omitempty"`
+}
+
+type APIResponse struct {
+	Data any      `json:"data"`
+	Meta *APIMeta `json:"meta,omitempty"`
+}
+
+type APIError struct {
+	Error string `json:"error"`
+}
+
+// apiRoute is one endpoint. The routes are registered and the OpenAPI
+// document is built from the same table so the two cannot disagree
+type apiRoute struct {
+	path string
+	// id is the operationId, and the method name in the generated client
+	id      string
+	summary string
+	handler func(*Server, http.ResponseWriter, *http.Request)
+	// params are keys of apiParams, on top of reset for every route but
+	// resets and limit and offset for paged ones
+	params []string
+	// data is a value of the type returned under "data"
+	data  any
+	paged bool
+}
+
+var apiRoutes = []apiRoute{
+	{"/api/v1/resets", "getResets", "Resets with data, newest first", (*Server).APIResetsHandler, nil, []string{}, false},
+	{"/api/v1/stats", "getStats", "Server statistics", (*Server).APIStatsHandler, nil, ds.Stats{}, false},
+	{"/api/v1/stats/history", "getStatsHistory", "Server counts over time", (*Server).APIStatsHistoryHandler, []string{"start", "end"}, []ds.StatsPoint{}, true},
+	{"/api/v1/leaderboard", "getLeaderboard", "Credits or charts leaderboard", (*Server).APILeaderboardHandler, []string{"type", "agents"}, []ds.LeaderboardEntry{}, true},
+	{"/api/v1/leaderboard/history", "getRankHistory", "Agents' rank on both leaderboards over time", (*Server).APIRankHistoryHandler, []string{"agents", "start", "end"}, []ds.RankPoint{}, true},
+	{"/api/v1/agents", "getAgents", "The agents table", (*Server).APIAgentsHandler, []string{"agents", "search", "faction", "system", "active", "construction", "sort"}, []AgentRow{}, true},
+	{"/api/v1/agents/history", "getAgentHistory", "Credits and ships over time", (*Server).APIAgentHistoryHandler, []string{"agents", "start", "end", "tier"}, []ds.AgentRollup{}, true},
+	{"/api/v1/jumpgates", "getJumpgates", "Jumpgates and their status", (*Server).APIJumpgatesHandler, []string{"status", "agents"}, []ds.JGInfo{}, true},
+	{"/api/v1/construction", "getConstruction", "Jumpgate construction progress over time", (*Server).APIConstructionHandler, []string{"jumpgates", "agents", "start", "end", "tier"}, []ds.ConstructionRollup{}, true},
+	{"/api/v1/construction/status", "getConstructionStatus", "Latest construction progress of each agent's jumpgate", (*Server).APIConstructionStatusHandler, []string{"agents"}, []ds.ConstructionOverview{}, true},
+}
+
+func (srv *Server) registerAPI() {
+	for _, route := range apiRoutes {
+		srv.mux.HandleFunc("GET "+route.path, func(w http.ResponseWriter, r *http.Request) {
+			route.handler(srv, w, r)
+		})
+	}
+	srv.mux.HandleFunc("GET /api/v1/openapi.json", srv.OpenAPIHandler)
+}
+
+func writeAPI(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logging.Error("api: failed to write response", err)
+	}
+}
+
+func apiFail(w http.ResponseWriter, status int, err error) {
+	writeAPI(w, status, APIError{Error: err.Error()})
+}
+
+// apiQuery is the parameters shared by the endpoints
+type apiQuery struct {
+	reset  ds.Reset
+	start  int64
+	end    int64
+	limit  int
+	offset int
+	// agents is nil when the parameter is not given
+	agents []string
+}
+
+// parseAPIQuery reads the shared parameters. Unlike the HTML handlers an
+// unknown reset is an error rather than falling back to the newest
+func (srv *Server) parseAPIQuery(r *http.Request) (apiQuery, int, error) {
+	q := r.URL.Query()
+	res := apiQuery{reset: srv.newestReset(), limit: defaultPageLimit}
+
+	if want := q.Get("reset"); want != "" {
+		known := false
+		for _, k := range srv.knownResets() {
+			if k == want {
+				known = true
+			}
+		}
+		if !known {
+			return res, http.StatusNotFound, fmt.Errorf("unknown reset %q", want)
+		}
+		res.reset = ds.Reset(want)
+	}
+	if res.reset == "" {
+		return res, http.StatusServiceUnavailable, errNoReset
+	}
+
+	var err error
+	if res.start, err = parseAPITime(q.Get("start")); err != nil {
+		return res, http.StatusBadRequest, fmt.Errorf("start: %w", err)
+	}
+	if res.end, err = parseAPITime(q.Get("end")); err != nil {
+		return res, http.StatusBadRequest, fmt.Errorf("end: %w", err)
+	}
+	if res.end == 0 {
+		res.end = srv.resetEnd(res.reset).Unix()
+	}
+	if res.start > res.end {
+		return res, http.StatusBadRequest, fmt.Errorf("start is after end")
+	}
+
+	if v := q.Get("limit"); v != "" {
+		if res.limit, err = strconv.Atoi(v); err != nil || res.limit < 1 || res.limit > maxPageLimit {
+			return res, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if res.offset, err = strconv.Atoi(v); err != nil || res.offset < 0 {
+			return res, http.StatusBadRequest, fmt.Errorf("offset must be a positive number")
+		}
+	}
+
+	if v := q.Get("agents"); v != "" {
+		res.agents = []string{}
+		for _, a := range mergeAgents(v) {
+			res.agents = append(res.agents, strings.ToUpper(a))
+		}
+		sort.Strings(res.agents)
+	}
+	return res, 0, nil
+}
+
+// parseAPITime reads unix seconds or RFC 3339, empty is 0
+func parseAPITime(v string) (int64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	if ts, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return ts, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return 0, fmt.Errorf("%q is neither unix seconds nor RFC 3339", v)
+	}
+	return t.Unix(), nil
+}
+
+// parseTier reads the tier parameter, raw records by default
+func parseTier(r *http.Request) (ds.Tier, error) {
+	name := r.URL.Query().Get("tier")
+	if name == "" {
+		return ds.TierRaw, nil
+	}
+	tier, ok := ds.TierByName(name)
+	if !ok {
+		return tier, fmt.Errorf("unknown tier %q, use 5m, 1h or 1d", name)
+	}
+	return tier, nil
+}
+
+// page cuts one page out of items
+func page[T any](items []T, q apiQuery) ([]T, *APIMeta) {
+	meta := &APIMeta{Reset: string(q.reset), Total: len(items), Limit: q.limit, Offset: q.offset}
+	if q.offset >= len(items) {
+		return []T{}, meta
+	}
+	return items[q.offset:min(q.offset+q.limit, len(items))], meta
+}
+
+func symbolSet(symbols []string) map[string]bool {
+	if symbols == nil {
+		return nil
+	}
+	res := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		res[s] = true
+	}
+	return res
+}
+
+// agentJumpgates maps agents to the jumpgate in their headquarters system
+func (srv *Server) agentJumpgates(thisReset ds.Reset, agents []string) []string {
+	aList, _ := srv.store.GetAgentList(thisReset)
+	jumpgates := ds.GetJumpgates(srv.store, thisReset)
+	want := symbolSet(agents)
+	seen := make(map[string]bool)
+	res := []string{}
+	for _, a := range aList {
+		if !want[a.Symbol] {
+			continue
+		}
+		if jg, ok := jumpgates[a.System]; ok && !seen[jg.Jumpgate] {
+			seen[jg.Jumpgate] = true
+			res = append(res, jg.Jumpgate)
+		}
+	}
+	return res
+}
+
+func (srv *Server) APIResetsHandler(w http.ResponseWriter, r *http.Request) {
+	writeAPI(w, http.StatusOK, APIResponse{Data: srv.knownResets()})
+}
+
+func (srv *Server) APIStatsHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	q, status, err := srv.parseAPIQuery(r)
+	if err != nil {
+		apiFail(w, status, err)
+		return
+	}
+	stats, err := srv.store.GetStats(q.reset)
+	if err != nil {
+		apiFail(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeAPI(w, http.StatusOK, APIResponse{Data: stats, Meta: &APIMeta{Reset: string(q.reset), Total: 1}})
+	metrics.RecordDuration("api_stats", start)
+}
+
+// APIStatsHistoryHandler serves the server's counts at every tick
+func (srv *Server) APIStatsHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	q, status, err := srv.parseAPIQuery(r)
+	if err != nil {
+		apiFail(w, status, err)
+		return
+	}
+	history, err := srv.store.GetStatsHistory(q.reset, q.start, q.end)
+	if err != nil {
+		apiFail(w, http.StatusInternalServerError, err)
+		return
+	}
+	data, meta := page(history, q)
+	writeAPI(w, http.StatusOK, APIResponse{Data: data, Meta: meta})
+	metrics.RecordDuration("api_stats_history", start)
+}
+
+// APILeaderboardHandler serves the credits leaderboard, or the charts one
+// with type=charts
+func (srv *Server) APILeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	q, status, err := srv.parseAPIQuery(r)
+	if err != nil {
+		apiFail(w, status, err)
+		return
+	}
+	creditLB, chartLB, err := srv.store.GetLeaderboard(q.reset)
+	if err != nil {
+		apiFail(w, http.StatusInternalServerError, err)
+		return
+	}
+	entries := creditLB
+	switch r.URL.Query().Get("type") {
+	case "", "credits":
+	case "charts":
+		entries = chartLB
+	default:
+		apiFail(w, http.StatusBadRequest, fmt.Errorf("type must be credits or charts"))
+		return
+	}
+	if want := symbolSet(q.agents); want != nil {
+		filtered := []ds.LeaderboardEntry{}
+		for _, e := range entries {
+			if want[e.Symbol] {
+				filtered = append(filtered, e)
+			}
+		}
+		entries = filtered
+	}
+	data, meta := page(entries, q)
+	writeAPI(w, http.StatusOK, APIResponse{Data: data, Meta: meta})
+	metrics.RecordDuration("api_leaderboard", start)
+}
+
+// APIRankHistoryHandler serves the agents' rank on both boards at every
+// stored leaderboard, 0 when off a board. Without agents it follows
+// everyone on either current board
+func (srv *Server) APIRankHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	q, status, err := srv.parseAPIQuery(r)
+	if err != nil {
+		apiFail(w, status, err)
+		return
+	}
+	agents := q.agents
+	if agents == nil {
+		creditLB, chartLB, err := srv.store.GetLeaderboard(q.reset)
+		if err != nil {
+			apiFail(w, http.StatusInternalServerError, err)
+			return
+		}
+		symbols := []string{}
+		for _, e := range append(creditLB, chartLB...) {
+			symbols = append(symbols, e.Symbol)
+		}
+		agents = mergeAgents(symbols)
+	}
+	points, err := ds.RankHistory(srv.store, q.reset, agents, q.start, q.end)
+	if err != nil {
+		apiFail(w, http.StatusInternalServerError, err)
+		return
+	}
+	data, meta := page(points, q)
+	writeAPI(w, http.StatusOK, APIResponse{Data: data, Meta: meta})
+	metrics.RecordDuration("api_rank_history", start)
+}
+
+// APIAgentsHandler serves the agents table. Besides agents it filters on
+// search, faction, system, active=true and construction=true and sorts by
+// sort=credits|ships|rate|recent|acceleration|shipRate|projected, every
+// order but the name is highest first
+func (srv *Server) APIAgentsHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	q, status, err := srv.parseAPIQuery(r)
+	if err != nil {
+		apiFail(w, status, err)
+		return
+	}
+	p := r.URL.Query()
+	rows := srv.agentRows(q.reset, agentFilter{
+		search:           p.Get("search"),
+		faction:          p.Get("faction"),
+		system:           p.Get("system"),
+		sortBy:           p.Get("sort"),
+		hideInactive:     p.Get("active") == "true",
+		constructionOnly: p.Get("construction") == "true",
+		symbols:          symbolSet(q.agents),
+	})
+	data, meta := page(rows, q)
+	writeAPI(w, http.StatusOK, APIResponse{Data: data, Meta: meta})
+	metrics.RecordDuration("api_agents", start)
+}
+
+// APIAgentHistoryHandler serves credits and ships over time at tier
+func (srv *Server) APIAgentHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	q, status, err := srv.parseAPIQuery(r)
+	if err != nil {
+		apiFail(w, status, err)
+		return
+	}
+	tier, err := parseTier(r)
+	if err != nil {
+		apiFail(w, http.StatusBadRequest, err)
+		return
+	}
+	series, err := srv.store.GetAgentRollups(q.reset, q.agents, tier, q.start, q.end)
+	if err != nil {
+		apiFail(w, http.StatusInternalServerError, err)
+		return
+	}
+	data, meta := page(series, q)
+	meta.Tier = tier.Name
+	writeAPI(w, http.StatusOK, APIResponse{Data: data, Meta: meta})
+	metrics.RecordDuration("api_agent_history", start)
+}
+
+// APIJumpgatesHandler serves the jumpgate list, status filters on
+// NoActivity, Active, Const or Complete and agents on the agents' gates
+func (srv *Server) APIJumpgatesHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	q, status, err := srv.parseAPIQuery(r)
+	if err != nil {
+		apiFail(w, status, err)
+		return
+	}
+	jgList, err := srv.store.GetJumpgateList(q.reset)
+	if err != nil {
+		apiFail(w, http.StatusInternalServerError, err)
+		return
+	}
+	wantStatus := r.URL.Query().Get("status")
+	var wantGates map[string]bool
+	if q.agents != nil {
+		wantGates = symbolSet(srv.agentJumpgates(q.reset, q.agents))
+	}
+	res := []ds.JGInfo{}
+	for _, jg := range jgList {
+		if wantStatus != "" && constructionStatusName(jg.Status) != wantStatus {
+			continue
+		}
+		if wantGates != nil && !wantGates[jg.Jumpgate] {
+			continue
+		}
+		res = append(res, jg)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Jumpgate < res[j].Jumpgate
+	})
+	data, meta := page(res, q)
+	writeAPI(w, http.StatusOK, APIResponse{Data: data, Meta: meta})
+	metrics.RecordDuration("api_jumpgates", start)
+}
+
+// constructionGates is the jumpgates parameter plus the gates of agents, nil
+// when neither is given
+func (srv *Server) constructionGates(r *http.Request, q apiQuery) []string {
+	var gates []string
+	if v := r.URL.Query().Get("jumpgates"); v != "" {
+		for _, jg := range mergeAgents(v) {
+			gates = append(gates, strings.ToUpper(jg))
+		}
+	}
+	if q.agents != nil {
+		gates = append(gates, srv.agentJumpgates(q.reset, q.agents)...)
+		if gates == nil {
+			gates = []string{}
+		}
+	}
+	return gates
+}
+
+// APIConstructionHandler serves construction progress over time at tier
+func (srv *Server) APIConstructionHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	q, status, err := srv.parseAPIQuery(r)
+	if err != nil {
+		apiFail(w, status, err)
+		return
+	}
+	tier, err := parseTier(r)
+	if err != nil {
+		apiFail(w, http.StatusBadRequest, err)
+		return
+	}
+	series, err := srv.store.GetConstructionRollups(q.reset, srv.constructionGates(r, q), tier, q.start, q.end)
+	if err != nil {
+		apiFail(w, http.StatusInternalServerError, err)
+		return
+	}
+	data, meta := page(series, q)
+	meta.Tier = tier.Name
+	writeAPI(w, http.StatusOK, APIResponse{Data: data, Meta: meta})
+	metrics.RecordDuration("api_construction", start)
+}
+
+// APIConstructionStatusHandler serves the latest construction record of each
+// agent's jumpgate, as in the chart page's summary table
+func (srv *Server) APIConstructionStatusHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	q, status, err := srv.parseAPIQuery(r)
+	if err != nil {
+		apiFail(w, status, err)
+		return
+	}
+	aList, err := srv.store.GetAgentList(q.reset)
+	if err != nil {
+		apiFail(w, http.StatusInternalServerError, err)
+		return
+	}
+	jgList, err := srv.store.GetJumpgateList(q.reset)
+	if err != nil {
+		apiFail(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// only the last record of each wanted agent's gate is needed
+	want := symbolSet(q.agents)
+	agents := agentsMap(aList)
+	jumpgates := jumpgatesMap(jgList)
+	var gates []string
+	for name, a := range agents {
+		if jg, ok := jumpgates[a.System]; ok && (want == nil || want[name]) {
+			gates = append(gates, jg.Jumpgate)
+		}
+	}
+	latest, err := ds.LatestConstructions(srv.store, q.reset, gates)
+	if err != nil {
+		apiFail(w, http.StatusInternalServerError, err)
+		return
+	}
+	constrList := make([]ds.JGConstruction, 0, len(latest))
+	for _, c := range latest {
+		constrList = append(constrList, c)
+	}
+
+	res := []ds.ConstructionOverview{}
+	for name, co := range constructionOverviews(agents, jumpgates, constrList) {
+		if want != nil && !want[name] {
+			continue
+		}
+		res = append(res, co)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Agent < res[j].Agent
+	})
+
+	data, meta := page(res, q)
+	writeAPI(w, http.StatusOK, APIResponse{Data: data, Meta: meta})
+	metrics.RecordDuration("api_construction_status", start)
+}
@@ -0,0 +1,275 @@
+package frontend
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+)
+
+// getAPI decodes the response to url into data, failing unless the status
+// is want
+func getAPI(t *testing.T, srv *Server, url string, want int, data any) APIMeta {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != want {
+		t.Fatalf("GET %s: status %d, want %d: %s", url, rec.Code, want, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("GET %s: Content-Type %q", url, ct)
+	}
+	var resp struct {
+		Data json.RawMessage
+		Meta *APIMeta
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	if data != nil {
+		if err := json.Unmarshal(resp.Data, data); err != nil {
+			t.Fatalf("GET %s: data: %v", url, err)
+		}
+	}
+	if resp.Meta == nil {
+		return APIMeta{}
+	}
+	return *resp.Meta
+}
+
+func TestAPIAgents(t *testing.T) {
+	srv := testServer(t)
+
+	var rows []AgentRow
+	meta := getAPI(t, srv, "/api/v1/agents?sort=credits", http.StatusOK, &rows)
+	if meta.Total != 2 || meta.Reset != "2026-01-04" || len(rows) != 2 || rows[0].Symbol != "BRAVO" || rows[0].Ships != 3 {
+		t.Fatalf("agents = %+v, %+v", rows, meta)
+	}
+
+	// the same filters as the agents page
+	getAPI(t, srv, "/api/v1/agents?active=true", http.StatusOK, &rows)
+	if len(rows) != 1 || rows[0].Symbol != "BRAVO" {
+		t.Fatalf("active agents = %+v", rows)
+	}
+	getAPI(t, srv, "/api/v1/agents?agents=alpha", http.StatusOK, &rows)
+	if len(rows) != 1 || rows[0].Symbol != "ALPHA" {
+		t.Fatalf("agents=alpha = %+v", rows)
+	}
+
+	meta = getAPI(t, srv, "/api/v1/agents?limit=1&offset=1", http.StatusOK, &rows)
+	if meta.Total != 2 || meta.Limit != 1 || meta.Offset != 1 || len(rows) != 1 || rows[0].Symbol != "BRAVO" {
+		t.Fatalf("second page = %+v, %+v", rows, meta)
+	}
+	getAPI(t, srv, "/api/v1/agents?offset=5", http.StatusOK, &rows)
+	if len(rows) != 0 {
+		t.Fatalf("page past the end = %+v", rows)
+	}
+}
+
+func TestAPIAgentsAnalytics(t *testing.T) {
+	srv := testServer(t)
+	// ALPHA earned 1000 an hour over the last three hours, BRAVO nothing
+	now := time.Now().Add(-time.Minute).Unix()
+	for i := int64(3); i >= 1; i-- {
+		err := srv.store.StoreAgents([]ds.PublicAgent{
+			{Symbol: "ALPHA", Credits: 175000 - i*1000, Headquarters: "X1-AB12-A1", ShipCount: 2, StartingFaction: "COSMIC"},
+			{Symbol: "BRAVO", Credits: 250000, Headquarters: "X1-CD34-B2", ShipCount: 3, StartingFaction: "VOID"},
+		}, now-i*3600)
+		if err != nil {
+			t.Fatalf("StoreAgents: %v", err)
+		}
+	}
+
+	var rows []AgentRow
+	getAPI(t, srv, "/api/v1/agents?sort=rate", http.StatusOK, &rows)
+	if len(rows) != 2 || rows[0].Symbol != "ALPHA" || rows[0].CreditsPerHour != 1000 || rows[1].CreditsPerHour != 0 {
+		t.Fatalf("agents by rate = %+v", rows)
+	}
+	if rows[0].ProjectedCredits < rows[0].Credits {
+		t.Fatalf("projection below the current credits: %+v", rows[0])
+	}
+	getAPI(t, srv, "/api/v1/agents?sort=credits", http.StatusOK, &rows)
+	if rows[0].Symbol != "BRAVO" {
+		t.Fatalf("agents by credits = %+v", rows)
+	}
+}
+
+func TestAPIHistory(t *testing.T) {
+	srv := testServer(t)
+
+	var series []ds.AgentRollup
+	meta := getAPI(t, srv, "/api/v1/agents/history?agents=BRAVO", http.StatusOK, &series)
+	if meta.Tier != "5m" || len(series) != 1 || series[0].Symbol != "BRAVO" || series[0].Credits.Last != 250000 {
+		t.Fatalf("history = %+v, %+v", series, meta)
+	}
+	meta = getAPI(t, srv, "/api/v1/agents/history?tier=1h&start=2026-01-01T00:00:00Z", http.StatusOK, &series)
+	if meta.Tier != "1h" || len(series) != 2 {
+		t.Fatalf("hourly history = %+v, %+v", series, meta)
+	}
+	getAPI(t, srv, "/api/v1/agents/history?end=1", http.StatusOK, &series)
+	if len(series) != 0 {
+		t.Fatalf("history before the data = %+v", series)
+	}
+}
+
+func TestAPIConstruction(t *testing.T) {
+	srv := testServer(t)
+	err := srv.store.UpdateJumpGates([]ds.JGInfo{
+		{Jumpgate: "X1-CD34-I1", System: "X1-CD34", Headquarters: "X1-CD34-B2", Status: ds.Const},
+		{Jumpgate: "X1-AB12-I1", System: "X1-AB12", Headquarters: "X1-AB12-A1", Status: ds.NoActivity},
+	})
+	if err != nil {
+		t.Fatalf("UpdateJumpGates: %v", err)
+	}
+	now := time.Now().Add(-time.Minute).Unix()
+	err = srv.store.AddConstructions([]ds.JGConstruction{{Timestamp: now, Jumpgate: "X1-CD34-I1", Materials: ds.Materials{
+		{Symbol: "FAB_MATS", Required: 1600, Fulfilled: 500},
+	}}}, now)
+	if err != nil {
+		t.Fatalf("AddConstructions: %v", err)
+	}
+
+	var gates []ds.JGInfo
+	getAPI(t, srv, "/api/v1/jumpgates?status=Const", http.StatusOK, &gates)
+	if len(gates) != 1 || gates[0].Jumpgate != "X1-CD34-I1" {
+		t.Fatalf("jumpgates under construction = %+v", gates)
+	}
+	getAPI(t, srv, "/api/v1/jumpgates?agents=ALPHA", http.StatusOK, &gates)
+	if len(gates) != 1 || gates[0].Jumpgate != "X1-AB12-I1" {
+		t.Fatalf("ALPHA's jumpgate = %+v", gates)
+	}
+
+	// agents select their gate's series
+	var series []ds.ConstructionRollup
+	getAPI(t, srv, "/api/v1/construction?agents=BRAVO", http.StatusOK, &series)
+	if len(series) != 1 || series[0].Material("FAB_MATS").Fulfilled.Last != 500 {
+		t.Fatalf("construction = %+v", series)
+	}
+	getAPI(t, srv, "/api/v1/construction?agents=ALPHA", http.StatusOK, &series)
+	if len(series) != 0 {
+		t.Fatalf("construction for ALPHA = %+v", series)
+	}
+
+	var status []ds.ConstructionOverview
+	getAPI(t, srv, "/api/v1/construction/status?agents=BRAVO", http.StatusOK, &status)
+	if len(status) != 1 || status[0].Jumpgate != "X1-CD34-I1" || status[0].Materials.Get("FAB_MATS").Fulfilled != 500 {
+		t.Fatalf("construction status = %+v", status)
+	}
+}
+
+func TestAPIOther(t *testing.T) {
+	srv := testServer(t)
+
+	var stats ds.Stats
+	getAPI(t, srv, "/api/v1/stats", http.StatusOK, &stats)
+	if stats.Agents != 2 || stats.Status != "ok" {
+		t.Fatalf("stats = %+v", stats)
+	}
+	var statsHistory []ds.StatsPoint
+	getAPI(t, srv, "/api/v1/stats/history", http.StatusOK, &statsHistory)
+	if len(statsHistory) != 1 || statsHistory[0].Agents != 2 {
+		t.Fatalf("stats history = %+v", statsHistory)
+	}
+	var lb []ds.LeaderboardEntry
+	getAPI(t, srv, "/api/v1/leaderboard?type=credits", http.StatusOK, &lb)
+	if len(lb) != 1 || lb[0].Symbol != "BRAVO" {
+		t.Fatalf("leaderboard = %+v", lb)
+	}
+	var ranks []ds.RankPoint
+	getAPI(t, srv, "/api/v1/leaderboard/history", http.StatusOK, &ranks)
+	if len(ranks) != 1 || ranks[0].Symbol != "BRAVO" || ranks[0].CreditsRank != 1 {
+		t.Fatalf("rank history = %+v", ranks)
+	}
+	var resets []string
+	getAPI(t, srv, "/api/v1/resets", http.StatusOK, &resets)
+	if len(resets) != 1 || resets[0] != "2026-01-04" {
+		t.Fatalf("resets = %v", resets)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	srv := testServer(t)
+	for url, want := range map[string]int{
+		"/api/v1/agents?reset=2020-01-01":        http.StatusNotFound,
+		"/api/v1/agents?limit=0":                 http.StatusBadRequest,
+		"/api/v1/agents?limit=5000":              http.StatusBadRequest,
+		"/api/v1/agents?offset=-1":               http.StatusBadRequest,
+		"/api/v1/agents/history?tier=2h":         http.StatusBadRequest,
+		"/api/v1/agents/history?start=yesterday": http.StatusBadRequest,
+		"/api/v1/construction?start=20&end=10":   http.StatusBadRequest,
+		"/api/v1/leaderboard?type=ships":         http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		var e APIError
+		if rec.Code != want || json.Unmarshal(rec.Body.Bytes(), &e) != nil || e.Error == "" {
+			t.Errorf("GET %s: status %d, want %d: %s", url, rec.Code, want, rec.Body.String())
+		}
+	}
+
+	// a failed read is an error, not an empty page
+	srv.store = brokenJumpgates{srv.store}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/construction/status", nil)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("construction status with a broken store: status %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+type brokenJumpgates struct{ ds.Store }
+
+func (brokenJumpgates) GetJumpgateList(ds.Reset) ([]ds.JGInfo, error) {
+	return nil, errors.New("disk on fire")
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	srv := testServer(t)
+	var spec struct {
+		Paths map[string]struct {
+			Get struct {
+				Parameters []struct{ Name string }
+			}
+		}
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]any
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(get(t, srv, "/api/v1/openapi.json")), &spec); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	for _, route := range apiRoutes {
+		op, ok := spec.Paths[route.path]
+		if !ok {
+			t.Errorf("%s missing from the document", route.path)
+			continue
+		}
+		for _, p := range op.Get.Parameters {
+			if _, ok := apiParams[p.Name]; !ok {
+				t.Errorf("%s: parameter %s is not described", route.path, p.Name)
+			}
+		}
+		// every documented route is served
+		get(t, srv, route.path)
+	}
+	// the schemas follow the Go types
+	for name, field := range map[string]string{
+		"AgentRow":             "ShowConstruct",
+		"Stats":                "NextReset",
+		"LeaderboardEntry":     "Value",
+		"ConstructionOverview": "Materials",
+		"APIMeta":              "total",
+	} {
+		if _, ok := spec.Components.Schemas[name].Properties[field]; !ok {
+			t.Errorf("schema %s has no %s", name, field)
+		}
+	}
+}
@@ -4,15 +4,21 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"math"
+	"slices"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-echarts/go-echarts/v2/charts"
 	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/go-echarts/go-echarts/v2/render"
 	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
 )
 
-const targetDataPoints int = 50
+// targetDataPoints is the point budget per series, the datastore picks the
+// rollup tier that fits it: raw up to a day, hourly up to 12 days
+const targetDataPoints int = 300
 
 func agentsMap(agents []ds.Agent) map[string]ds.Agent {
 	res := make(map[string]ds.Agent, len(agents))
@@ -34,28 +40,42 @@ func latestShips(history []ds.AgentStatus) map[string]int64 {
 	return latest
 }
 
-func agentRecordsCredits(history []ds.AgentStatus, agent string, dur time.Duration) []ds.DataPoint {
+// agentRecordsCredits plots the last value of each bucket at the time it
+// was collected, so every tier lines up on the same axis
+func agentRecordsCredits(series []ds.AgentRollup, agent string) []ds.DataPoint {
 	var res []ds.DataPoint
-	cutoff := time.Now().Add(-1 * dur).Unix()
-	for _, r := range history {
-		if r.Symbol == agent && r.Timestamp >= cutoff {
-			res = append(res, ds.DataPoint{Timestamp: r.Timestamp, Value: r.Credits})
+	for _, r := range series {
+		if r.Symbol == agent {
+			res = append(res, ds.DataPoint{Timestamp: r.LastTimestamp, Value: r.Credits.Last})
 		}
 	}
 	return res
 }
 
-func agentRecordsShips(history []ds.AgentStatus, agent string, dur time.Duration) []ds.DataPoint {
+func agentRecordsShips(series []ds.AgentRollup, agent string) []ds.DataPoint {
 	var res []ds.DataPoint
-	cutoff := time.Now().Add(-1 * dur).Unix()
-	for _, r := range history {
-		if r.Symbol == agent && r.Timestamp >= cutoff {
-			res = append(res, ds.DataPoint{Timestamp: r.Timestamp, Value: r.Ships})
+	for _, r := range series {
+		if r.Symbol == agent {
+			res = append(res, ds.DataPoint{Timestamp: r.LastTimestamp, Value: r.Ships.Last})
 		}
 	}
 	return res
 }
 
+// constructionRows flattens construction rollups back into records holding
+// the last value of each bucket
+func constructionRows(series []ds.ConstructionRollup) []ds.JGConstruction {
+	res := make([]ds.JGConstruction, 0, len(series))
+	for _, r := range series {
+		res = append(res, ds.JGConstruction{
+			Timestamp: r.LastTimestamp,
+			Jumpgate:  r.Jumpgate,
+			Materials: r.Last(),
+		})
+	}
+	return res
+}
+
 func constructionRecords(agentsMap map[string]ds.Agent, jgs map[string]ds.JGInfo, constructions []ds.JGConstruction, agentNames []string) map[string][]ds.ConstructionRecord {
 	res := make(map[string][]ds.ConstructionRecord)
 	for _, a := range agentNames {
@@ -71,8 +91,7 @@ func constructionRecords(agentsMap map[string]ds.Agent, jgs map[string]ds.JGInfo
 			if rec.Jumpgate == thisJG.Jumpgate {
 				res[a] = append(res[a], ds.ConstructionRecord{
 					Timestamp: rec.Timestamp,
-					Fabmat:    rec.Fabmat,
-					Advcct:    rec.Advcct,
+					Materials: rec.Materials,
 				})
 			}
 		}
@@ -106,8 +125,7 @@ func latestConstructionRecords(agentsMap map[string]ds.Agent, jgs map[string]ds.
 		res = append(res, ds.ConstructionOverview{
 			Agent:     a,
 			Jumpgate:  thisJG.Jumpgate,
-			Fabmat:    jgLatest.Fabmat,
-			Advcct:    jgLatest.Advcct,
+			Materials: jgLatest.Materials,
 			Timestamp: time.Unix(jgLatest.Timestamp, 0).UTC(),
 		})
 	}
@@ -122,17 +140,55 @@ func jumpgatesMap(jgs []ds.JGInfo) map[string]ds.JGInfo {
 	return res
 }
 
+// knownMaterials are display names for materials gates have needed so far,
+// anything else is shown by its trade symbol
+var knownMaterials = map[string]struct{ name, short string }{
+	"FAB_MATS":           {"Fabmat", "FB"},
+	"ADVANCED_CIRCUITRY": {"Adv Circuitry", "AC"},
+}
+
+func materialName(symbol string) string {
+	if m, ok := knownMaterials[symbol]; ok {
+		return m.name
+	}
+	return symbol
+}
+
+func materialShortName(symbol string) string {
+	if m, ok := knownMaterials[symbol]; ok {
+		return m.short
+	}
+	return symbol
+}
+
+// materialSymbols lists every material in recipes, in the order first seen
+func materialSymbols(recipes ...ds.Materials) []string {
+	res := []string{}
+	for _, r := range recipes {
+		for _, m := range r {
+			if !slices.Contains(res, m.Symbol) {
+				res = append(res, m.Symbol)
+			}
+		}
+	}
+	return res
+}
+
 func constructionString(co ds.ConstructionOverview, jg ds.JGInfo) (string, bool) {
 	if jg.Status == ds.Complete {
 		return "Complete", true
 	}
-	if co.Fabmat > 0 || co.Advcct > 0 {
-		return fmt.Sprintf("%d/1600 FB, %d/400 AC", co.Fabmat, co.Advcct), true
+	if co.Materials.Started() {
+		parts := make([]string, 0, len(co.Materials))
+		for _, m := range co.Materials {
+			parts = append(parts, fmt.Sprintf("%d/%d %s", m.Fulfilled, m.Required, materialShortName(m.Symbol)))
+		}
+		return strings.Join(parts, ", "), true
 	}
 	return "\u2014", false
 }
 
-func CreditChart(agents []string, history []ds.AgentStatus, dur time.Duration, title string) *charts.Line {
+func CreditChart(agents []string, series []ds.AgentRollup, dur time.Duration, end time.Time, title string) *charts.Line {
 	line := charts.NewLine()
 	line.SetGlobalOptions(
 		charts.WithInitializationOpts(opts.Initialization{
@@ -149,32 +205,19 @@ func CreditChart(agents []string, history []ds.AgentStatus, dur time.Duration, t
 		}),
 		charts.WithXAxisOpts(opts.XAxis{
 			Type: "time",
-			Min:  time.Now().Add(-1 * dur).UnixMilli(),
-			Max:  time.Now().UnixMilli(),
+			Min:  end.Add(-1 * dur).UnixMilli(),
+			Max:  end.UnixMilli(),
 		}),
 		charts.WithTooltipOpts(opts.Tooltip{
 			Show:    opts.Bool(true),
 			Trigger: "axis",
 		}),
 	)
-	collectionTime := 5
-	dpPerHour := 60 / collectionTime
-	estimatedTotal := int(dur.Hours()) * dpPerHour
-	stride := 1
-	if estimatedTotal > targetDataPoints {
-		stride = estimatedTotal / targetDataPoints
-		if stride < 1 {
-			stride = 1
-		}
-	}
-
 	for _, p := range agents {
-		creditHist := agentRecordsCredits(history, p, dur)
-		creditItems := make([]opts.LineData, 0, targetDataPoints*2)
-		for i, r := range creditHist {
-			if i%stride == 0 {
-				creditItems = append(creditItems, opts.LineData{Value: []interface{}{r.Timestamp * 1000, r.Value}})
-			}
+		creditHist := agentRecordsCredits(series, p)
+		creditItems := make([]opts.LineData, 0, len(creditHist))
+		for _, r := range creditHist {
+			creditItems = append(creditItems, opts.LineData{Value: []interface{}{r.Timestamp * 1000, r.Value}})
 		}
 		line.AddSeries(p, creditItems)
 
@@ -184,7 +227,7 @@ func CreditChart(agents []string, history []ds.AgentStatus, dur time.Duration, t
 	return line
 }
 
-func ShipChart(agents []string, history []ds.AgentStatus, dur time.Duration, title string) *charts.Line {
+func ShipChart(agents []string, series []ds.AgentRollup, dur time.Duration, end time.Time, title string) *charts.Line {
 	line := charts.NewLine()
 	line.SetGlobalOptions(
 		charts.WithInitializationOpts(opts.Initialization{
@@ -201,32 +244,19 @@ func ShipChart(agents []string, history []ds.AgentStatus, dur time.Duration, tit
 		}),
 		charts.WithXAxisOpts(opts.XAxis{
 			Type: "time",
-			Min:  time.Now().Add(-1 * dur).UnixMilli(),
-			Max:  time.Now().UnixMilli(),
+			Min:  end.Add(-1 * dur).UnixMilli(),
+			Max:  end.UnixMilli(),
 		}),
 		charts.WithTooltipOpts(opts.Tooltip{
 			Show:    opts.Bool(true),
 			Trigger: "axis",
 		}),
 	)
-	collectionTime := 5
-	dpPerHour := 60 / collectionTime
-	estimatedTotal := int(dur.Hours()) * dpPerHour
-	stride := 1
-	if estimatedTotal > targetDataPoints {
-		stride = estimatedTotal / targetDataPoints
-		if stride < 1 {
-			stride = 1
-		}
-	}
-
 	for _, p := range agents {
-		shipHist := agentRecordsShips(history, p, dur)
-		shipItems := make([]opts.LineData, 0, targetDataPoints*2)
-		for i, r := range shipHist {
-			if i%stride == 0 {
-				shipItems = append(shipItems, opts.LineData{Value: []interface{}{r.Timestamp * 1000, r.Value}})
-			}
+		shipHist := agentRecordsShips(series, p)
+		shipItems := make([]opts.LineData, 0, len(shipHist))
+		for _, r := range shipHist {
+			shipItems = append(shipItems, opts.LineData{Value: []interface{}{r.Timestamp * 1000, r.Value}})
 		}
 		line.AddSeries(p, shipItems)
 
@@ -236,7 +266,7 @@ func ShipChart(agents []string, history []ds.AgentStatus, dur time.Duration, tit
 	return line
 }
 
-func JumpgateConstructionChart(data map[string][]ds.ConstructionRecord, duration time.Duration) *charts.Line {
+func JumpgateConstructionChart(data map[string][]ds.ConstructionRecord, duration time.Duration, end time.Time) *charts.Line {
 	line := charts.NewLine()
 	line.SetGlobalOptions(
 		charts.WithInitializationOpts(opts.Initialization{
@@ -253,8 +283,8 @@ func JumpgateConstructionChart(data map[string][]ds.ConstructionRecord, duration
 		}),
 		charts.WithXAxisOpts(opts.XAxis{
 			Type: "time",
-			Min:  time.Now().Add(-1 * duration).UnixMilli(),
-			Max:  time.Now().UnixMilli(),
+			Min:  end.Add(-1 * duration).UnixMilli(),
+			Max:  end.UnixMilli(),
 		}),
 		charts.WithTooltipOpts(opts.Tooltip{
 			Show:    opts.Bool(true),
@@ -262,32 +292,36 @@ func JumpgateConstructionChart(data map[string][]ds.ConstructionRecord, duration
 		}),
 	)
 	for jg, recs := range data {
-		fabItems := make([]opts.LineData, 0)
-		advItems := make([]opts.LineData, 0)
+		// one series per material the gate has been seen needing
+		recipes := make([]ds.Materials, 0, len(recs))
 		for _, r := range recs {
-			fabItems = append(fabItems, opts.LineData{Value: []interface{}{r.Timestamp * 1000, r.Fabmat}})
-			advItems = append(advItems, opts.LineData{Value: []interface{}{r.Timestamp * 1000, r.Advcct}})
+			recipes = append(recipes, r.Materials)
+		}
+		for _, symbol := range materialSymbols(recipes...) {
+			items := make([]opts.LineData, 0, len(recs))
+			for _, r := range recs {
+				items = append(items, opts.LineData{Value: []interface{}{r.Timestamp * 1000, r.Materials.Get(symbol).Fulfilled}})
+			}
+			line.AddSeries(jg+" ("+materialName(symbol)+")", items)
 		}
-		line.AddSeries(jg+" (Fabmat)", fabItems)
-		line.AddSeries(jg+" (Advcct)", advItems)
-		fabItems = nil
-		advItems = nil
+		recipes = nil
 	}
 	data = nil
 	return line
 }
 
 type ConstructionParallelRow struct {
-	Agent    string
-	Jumpgate string
-	Fabmat   int
-	Advcct   int
+	Agent     string
+	Jumpgate  string
+	Materials ds.Materials
 }
 
 func ConstructionParallelChart(rows []ConstructionParallelRow) *charts.Parallel {
 	agentSet := make(map[string]struct{}, len(rows))
 	jgSet := make(map[string]struct{}, len(rows))
+	recipes := make([]ds.Materials, 0, len(rows))
 	for _, r := range rows {
+		recipes = append(recipes, r.Materials)
 		agentSet[r.Agent] = struct{}{}
 		jgSet[r.Jumpgate] = struct{}{}
 	}
@@ -302,6 +336,24 @@ func ConstructionParallelChart(rows []ConstructionParallelRow) *charts.Parallel
 	}
 	sort.Strings(jgs)
 
+	// a value axis per material, scaled to the largest requirement seen
+	symbols := materialSymbols(recipes...)
+	axes := []opts.ParallelAxis{
+		{Dim: 0, Name: "Agent", Type: "category", Data: agents},
+		{Dim: 1, Name: "Jumpgate", Type: "category", Data: jgs},
+	}
+	for i, symbol := range symbols {
+		required := 0
+		for _, r := range recipes {
+			required = max(required, r.Get(symbol).Required)
+		}
+		axis := opts.ParallelAxis{Dim: i + 2, Name: materialName(symbol), Type: "value"}
+		if required > 0 {
+			axis.Max = required
+		}
+		axes = append(axes, axis)
+	}
+
 	parallel := charts.NewParallel()
 	parallel.SetGlobalOptions(
 		charts.WithInitializationOpts(opts.Initialization{
@@ -312,12 +364,7 @@ func ConstructionParallelChart(rows []ConstructionParallelRow) *charts.Parallel
 		charts.WithTitleOpts(opts.Title{
 			Title: "Jumpgate Construction by Agent",
 		}),
-		charts.WithParallelAxisList([]opts.ParallelAxis{
-			{Dim: 0, Name: "Agent", Type: "category", Data: agents},
-			{Dim: 1, Name: "Jumpgate", Type: "category", Data: jgs},
-			{Dim: 2, Name: "Fabmat", Type: "value", Max: 1600},
-			{Dim: 3, Name: "Adv CCT", Type: "value", Max: 400},
-		}),
+		charts.WithParallelAxisList(axes),
 		charts.WithTooltipOpts(opts.Tooltip{
 			Show:    opts.Bool(true),
 			Trigger: "item",
@@ -329,9 +376,11 @@ func ConstructionParallelChart(rows []ConstructionParallelRow) *charts.Parallel
 		return rows[i].Agent < rows[j].Agent
 	})
 	for _, r := range rows {
-		data = append(data, opts.ParallelData{
-			Value: []interface{}{r.Agent, r.Jumpgate, r.Fabmat, r.Advcct},
-		})
+		value := []interface{}{r.Agent, r.Jumpgate}
+		for _, symbol := range symbols {
+			value = append(value, r.Materials.Get(symbol).Fulfilled)
+		}
+		data = append(data, opts.ParallelData{Value: value})
 	}
 	parallel.AddSeries("Construction", data)
 
@@ -343,13 +392,288 @@ type ChartSnippet struct {
 	Script  template.HTML
 }
 
+// chartSnippet renders a chart for a template
+func chartSnippet(c interface{ RenderSnippet() render.ChartSnippet }) ChartSnippet {
+	snippet := c.RenderSnippet()
+	return ChartSnippet{
+		Element: template.HTML(snippet.Element),
+		Script:  template.HTML(snippet.Script),
+	}
+}
+
 type ChartPageData struct {
+	// Period is the window shown, for reloading the same one
+	Period            string
 	CreditChart       ChartSnippet
 	ShipChart         ChartSnippet
 	ConstructionTable []ds.ConstructionOverview
+	// Materials are the table's material columns
+	Materials         []string
 	ConstructionChart ChartSnippet
 }
 
-func RenderChartFragment(w io.Writer, data ChartPageData) error {
-	return t.ExecuteTemplate(w, "chart.html", data)
+func (srv *Server) RenderChartFragment(w io.Writer, data ChartPageData) error {
+	return srv.t.ExecuteTemplate(w, "chart.html", data)
+}
+
+// CompareChart plots an agent's daily credits for each reset against days
+// since that reset started, so resets line up on the same axis
+func CompareChart(agent string, summaries []ds.ResetSummary) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Theme: "dark",
+			Width: "100%",
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:    agent,
+			Subtitle: "Credits by day of reset",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Min:      0,
+			Position: "right",
+			Name:     "Credits",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Type: "value",
+			Name: "Day",
+			Min:  0,
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+	)
+	for _, s := range summaries {
+		if !s.Found || len(s.Days) == 0 {
+			continue
+		}
+		resetStart := ds.ResetStart(s.Reset).Unix()
+		if resetStart < 0 {
+			resetStart = s.Days[0].Timestamp
+		}
+		items := make([]opts.LineData, 0, len(s.Days))
+		for _, d := range s.Days {
+			day := math.Round(float64(d.LastTimestamp-resetStart)/864) / 100
+			items = append(items, opts.LineData{Value: []interface{}{day, d.Credits.Last}})
+		}
+		line.AddSeries(string(s.Reset), items)
+	}
+	return line
+}
+
+// thinPoints keeps at most n evenly spread points, always including the
+// latest. Leaderboards and stats are stored every tick so a whole reset is
+// far more than a chart needs
+func thinPoints[T any](points []T, n int) []T {
+	if len(points) <= n {
+		return points
+	}
+	res := make([]T, 0, n)
+	step := float64(len(points)-1) / float64(n-1)
+	for i := range n {
+		res = append(res, points[int(math.Round(float64(i)*step))])
+	}
+	return res
+}
+
+// rankLine is a line chart of leaderboard positions, first place at the top
+func rankLine(title string) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Theme: "dark",
+			Width: "100%",
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title: title,
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Min:         1,
+			Inverse:     opts.Bool(true),
+			MinInterval: 1,
+			Position:    "right",
+			Name:        "Rank",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Type: "time",
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+	)
+	return line
+}
+
+// rankItems plots the non zero ranks, times off the board are left out
+func rankItems(points []ds.RankPoint, rank func(ds.RankPoint) int) []opts.LineData {
+	items := []opts.LineData{}
+	for _, p := range points {
+		if r := rank(p); r != 0 {
+			items = append(items, opts.LineData{Value: []interface{}{p.Timestamp * 1000, r}})
+		}
+	}
+	return items
+}
+
+// RankChart plots each agent's position on the credits or the charts
+// leaderboard
+func RankChart(agents []string, points []ds.RankPoint, board string) *charts.Line {
+	line := rankLine("Rank over time")
+	for _, a := range agents {
+		line.AddSeries(a, rankItems(points, func(p ds.RankPoint) int {
+			if p.Symbol != a {
+				return 0
+			}
+			if board == "charts" {
+				return p.ChartsRank
+			}
+			return p.CreditsRank
+		}))
+	}
+	return line
+}
+
+// AgentRankChart plots one agent's position on both leaderboards
+func AgentRankChart(points []ds.RankPoint) *charts.Line {
+	line := rankLine("Leaderboard rank")
+	line.AddSeries("Credits", rankItems(points, func(p ds.RankPoint) int { return p.CreditsRank }))
+	line.AddSeries("Charts", rankItems(points, func(p ds.RankPoint) int { return p.ChartsRank }))
+	return line
+}
+
+// StatsGrowthChart plots the server's agents and accounts, with ships on a
+// second axis as there are many more of them
+func StatsGrowthChart(history []ds.StatsPoint) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Theme: "dark",
+			Width: "100%",
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title: "Growth",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Min:  0,
+			Name: "Agents",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Type: "time",
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+	)
+	line.ExtendYAxis(opts.YAxis{
+		Min:      0,
+		Position: "right",
+		Name:     "Ships",
+	})
+	agents := make([]opts.LineData, 0, len(history))
+	accounts := make([]opts.LineData, 0, len(history))
+	ships := make([]opts.LineData, 0, len(history))
+	for _, p := range history {
+		agents = append(agents, opts.LineData{Value: []interface{}{p.Timestamp * 1000, p.Agents}})
+		accounts = append(accounts, opts.LineData{Value: []interface{}{p.Timestamp * 1000, p.Accounts}})
+		ships = append(ships, opts.LineData{Value: []interface{}{p.Timestamp * 1000, p.Ships}})
+	}
+	line.AddSeries("Agents", agents)
+	line.AddSeries("Accounts", accounts)
+	line.AddSeries("Ships", ships, charts.WithLineChartOpts(opts.LineChart{YAxisIndex: 1}))
+	return line
+}
+
+// StatsRateChart plots new agents and accounts per hour, and new ships on a
+// second axis
+func StatsRateChart(rates []ds.StatsRate) *charts.Bar {
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Theme: "dark",
+			Width: "100%",
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title: "New per hour",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "Agents",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Type: "time",
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+	)
+	bar.ExtendYAxis(opts.YAxis{
+		Position: "right",
+		Name:     "Ships",
+	})
+	agents := make([]opts.BarData, 0, len(rates))
+	accounts := make([]opts.BarData, 0, len(rates))
+	ships := make([]opts.BarData, 0, len(rates))
+	for _, r := range rates {
+		agents = append(agents, opts.BarData{Value: []interface{}{r.Timestamp * 1000, r.Agents}})
+		accounts = append(accounts, opts.BarData{Value: []interface{}{r.Timestamp * 1000, r.Accounts}})
+		ships = append(ships, opts.BarData{Value: []interface{}{r.Timestamp * 1000, r.Ships}})
+	}
+	bar.AddSeries("Agents", agents)
+	bar.AddSeries("Accounts", accounts)
+	bar.AddSeries("Ships", ships, charts.WithBarChartOpts(opts.BarChart{YAxisIndex: 1}))
+	return bar
+}
+
+// StatsCompareChart plots the agent count of each reset against days since
+// that reset started, like CompareChart does for one agent's credits
+func StatsCompareChart(histories map[ds.Reset][]ds.StatsPoint) *charts.Line {
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Theme: "dark",
+			Width: "100%",
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:    "Resets",
+			Subtitle: "Agents by day of reset",
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Min:      0,
+			Position: "right",
+			Name:     "Agents",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Type: "value",
+			Name: "Day",
+			Min:  0,
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+	)
+	resets := make([]ds.Reset, 0, len(histories))
+	for r, hist := range histories {
+		if len(hist) > 0 {
+			resets = append(resets, r)
+		}
+	}
+	slices.Sort(resets)
+	for _, r := range resets {
+		hist := histories[r]
+		resetStart := ds.ResetStart(r).Unix()
+		if resetStart < 0 {
+			resetStart = hist[0].Timestamp
+		}
+		items := make([]opts.LineData, 0, len(hist))
+		for _, p := range hist {
+			day := math.Round(float64(p.Timestamp-resetStart)/864) / 100
+			items = append(items, opts.LineData{Value: []interface{}{day, p.Agents}})
+		}
+		line.AddSeries(string(r), items)
+	}
+	return line
 }
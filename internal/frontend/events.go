@@ -0,0 +1,88 @@
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/papaburgs/fluffy-robot/internal/events"
+	"github.com/papaburgs/fluffy-robot/internal/logging"
+	"github.com/papaburgs/fluffy-robot/internal/metrics"
+)
+
+// eventKeepAlive is how often an idle stream gets a comment so proxies do
+// not close it
+const eventKeepAlive = 30 * time.Second
+
+// EventsHandler streams the collector's events as Server-Sent Events, the
+// event name being the type and the data the JSON events.Event. index.html
+// listens and reloads the views that show what changed
+func (srv *Server) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	if srv.events == nil {
+		http.NotFound(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch, stop := srv.events.Subscribe()
+	defer stop()
+	metrics.FrontendEventStreams.Add(1)
+	defer metrics.FrontendEventStreams.Add(-1)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// nginx buffers responses unless told not to
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-srv.closing:
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				logging.Error("events: failed to encode", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+		}
+		flusher.Flush()
+	}
+}
+
+// watchResets picks up a new reset as soon as the collector sees it rather
+// than when updateResetLoop next wakes
+func (srv *Server) watchResets(ctx context.Context) {
+	ch, stop := srv.events.Subscribe()
+	defer stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if e.Type == events.ResetDetected {
+				srv.refreshResets()
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package frontend
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/papaburgs/fluffy-robot/internal/events"
+)
+
+func TestEventsHandler(t *testing.T) {
+	srv := testServer(t)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without a bus, got %d", rec.Code)
+	}
+
+	srv.events = events.NewBus()
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type %q", ct)
+	}
+	body := bufio.NewReader(resp.Body)
+	if line, _ := body.ReadString('\n'); line != ": connected\n" {
+		t.Fatalf("first line %q", line)
+	}
+	body.ReadString('\n')
+
+	// the handler subscribes before it writes anything
+	srv.events.Publish(events.AgentsUpdated, "2026-01-04")
+	var lines []string
+	for len(lines) < 2 {
+		line, err := body.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v", err)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if lines[0] != "event: agents-updated" || !strings.Contains(lines[1], `"reset":"2026-01-04"`) {
+		t.Fatalf("unexpected event %q", lines)
+	}
+
+	resp.Body.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.events.Subscribers() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("the stream did not unsubscribe after the client left")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatchResets(t *testing.T) {
+	srv := testServer(t)
+	srv.events = events.NewBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.watchResets(ctx)
+	for srv.events.Subscribers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := srv.store.UpdateReset("2026-01-11"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	srv.events.Publish(events.ResetDetected, "2026-01-11")
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.newestReset() != "2026-01-11" {
+		if time.Now().After(deadline) {
+			t.Fatalf("the new reset was not picked up: %v", srv.knownResets())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// and it lets go of the bus once cancelled
+	cancel()
+	for srv.events.Subscribers() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("watchResets did not stop")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServe_Shutdown(t *testing.T) {
+	srv := testServer(t)
+	srv.events = events.NewBus()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- srv.serve(ctx, ln)
+	}()
+
+	// an open event stream must not hold up the shutdown
+	resp, err := http.Get("http://" + ln.Addr().String() + "/events")
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	defer resp.Body.Close()
+	body := bufio.NewReader(resp.Body)
+	if line, _ := body.ReadString('\n'); line != ": connected\n" {
+		t.Fatalf("first line %q", line)
+	}
+
+	cancel()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatalf("serve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after cancel")
+	}
+	if _, err := io.ReadAll(body); err != nil {
+		t.Fatalf("the stream did not end cleanly: %v", err)
+	}
+	if _, err := http.Get("http://" + ln.Addr().String() + "/"); err == nil {
+		t.Fatal("expected new connections to be refused")
+	}
+}
@@ -0,0 +1,282 @@
+package frontend
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+	"github.com/papaburgs/fluffy-robot/internal/logging"
+	"github.com/papaburgs/fluffy-robot/internal/metrics"
+	"github.com/papaburgs/fluffy-robot/internal/parquet"
+)
+
+// ExportHandler streams one data type of one reset as a flat table when
+// given a format (csv, ndjson or parquet) and a type (agents, construction,
+// leaderboard or stats). Without a format it sends the raw data directory as
+// a tarball, as it always has.
+//
+// reset, start, end, agents and jumpgates are read as in the JSON API
+func (srv *Server) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		srv.exportTarball(w)
+		metrics.RecordDuration("export", start)
+		return
+	}
+	logging.Info("Export Handler called for", format)
+
+	newTable, ok := tableFormats[format]
+	if !ok {
+		http.Error(w, "format must be csv, ndjson or parquet", http.StatusBadRequest)
+		return
+	}
+	dataType := r.URL.Query().Get("type")
+	export, ok := exportTypes[dataType]
+	if !ok {
+		http.Error(w, "type must be agents, construction, leaderboard or stats", http.StatusBadRequest)
+		return
+	}
+	q, status, err := srv.parseAPIQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	var jumpgates []string
+	if v := r.URL.Query().Get("jumpgates"); v != "" {
+		for _, jg := range mergeAgents(v) {
+			jumpgates = append(jumpgates, strings.ToUpper(jg))
+		}
+	}
+
+	// rows go out as the store reads them, an error once some are sent can
+	// only cut the response short
+	out := &sentWriter{w: w}
+	t := newTable(out, export.columns)
+	w.Header().Set("Content-Type", t.contentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-%s.%s\"", q.reset, dataType, format))
+	err = export.each(srv.store, q, jumpgates, t.write)
+	if err == nil {
+		err = t.close()
+	}
+	if err != nil {
+		logging.Error("export:", err)
+		if !out.sent {
+			w.Header().Del("Content-Disposition")
+			http.Error(w, "Failed to load data", http.StatusInternalServerError)
+		}
+		return
+	}
+	metrics.RecordDuration("export_"+format, start)
+}
+
+// sentWriter remembers whether anything reached the client
+type sentWriter struct {
+	w    io.Writer
+	sent bool
+}
+
+func (sw *sentWriter) Write(b []byte) (int, error) {
+	sw.sent = sw.sent || len(b) > 0
+	return sw.w.Write(b)
+}
+
+// exportType is a data type flattened to one row per record. each hands the
+// rows to emit one at a time, values are int64 or string so every format can
+// hold them
+type exportType struct {
+	columns []parquet.Column
+	each    func(s ds.Store, q apiQuery, jumpgates []string, emit func(row []any) error) error
+}
+
+var exportTypes = map[string]exportType{
+	"agents": {
+		columns: []parquet.Column{{Name: "timestamp", Type: parquet.Int64}, {Name: "symbol", Type: parquet.String}, {Name: "credits", Type: parquet.Int64}, {Name: "ships", Type: parquet.Int64}},
+		each: func(s ds.Store, q apiQuery, _ []string, emit func([]any) error) error {
+			return s.WalkAgentHistory(q.reset, q.agents, q.start, q.end, func(h ds.AgentStatus) error {
+				return emit([]any{h.Timestamp, h.Symbol, h.Credits, h.Ships})
+			})
+		},
+	},
+	// construction has a row per material so recipes of any shape fit
+	"construction": {
+		columns: []parquet.Column{{Name: "timestamp", Type: parquet.Int64}, {Name: "jumpgate", Type: parquet.String}, {Name: "material", Type: parquet.String}, {Name: "required", Type: parquet.Int64}, {Name: "fulfilled", Type: parquet.Int64}},
+		each: func(s ds.Store, q apiQuery, jumpgates []string, emit func([]any) error) error {
+			return s.WalkConstructions(q.reset, jumpgates, q.start, q.end, func(c ds.JGConstruction) error {
+				for _, m := range c.Materials {
+					if err := emit([]any{c.Timestamp, c.Jumpgate, m.Symbol, m.Required, m.Fulfilled}); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		},
+	},
+	// leaderboard and stats have a row per board entry or count taken
+	"leaderboard": {
+		columns: []parquet.Column{{Name: "timestamp", Type: parquet.Int64}, {Name: "board", Type: parquet.String}, {Name: "rank", Type: parquet.Int64}, {Name: "symbol", Type: parquet.String}, {Name: "value", Type: parquet.Int64}},
+		each: func(s ds.Store, q apiQuery, _ []string, emit func([]any) error) error {
+			history, err := s.GetLeaderboardHistory(q.reset, q.start, q.end)
+			if err != nil {
+				return err
+			}
+			want := symbolSet(q.agents)
+			for _, snap := range history {
+				for _, board := range []struct {
+					name    string
+					entries []ds.LeaderboardEntry
+				}{{"credits", snap.CreditsList}, {"charts", snap.ChartsList}} {
+					for i, e := range board.entries {
+						if want != nil && !want[e.Symbol] {
+							continue
+						}
+						if err := emit([]any{snap.Timestamp, board.name, i + 1, e.Symbol, e.Value}); err != nil {
+							return err
+						}
+					}
+				}
+			}
+			return nil
+		},
+	},
+	"stats": {
+		columns: []parquet.Column{
+			{Name: "timestamp", Type: parquet.Int64}, {Name: "agents", Type: parquet.Int64}, {Name: "accounts", Type: parquet.Int64},
+			{Name: "ships", Type: parquet.Int64}, {Name: "systems", Type: parquet.Int64}, {Name: "waypoints", Type: parquet.Int64},
+		},
+		each: func(s ds.Store, q apiQuery, _ []string, emit func([]any) error) error {
+			history, err := s.GetStatsHistory(q.reset, q.start, q.end)
+			if err != nil {
+				return err
+			}
+			for _, p := range history {
+				if err := emit([]any{p.Timestamp, p.Agents, p.Accounts, p.Ships, p.Systems, p.Waypoints}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// tableWriter writes rows of an exportType in one format
+type tableWriter interface {
+	contentType() string
+	write(row []any) error
+	close() error
+}
+
+var tableFormats = map[string]func(w io.Writer, columns []parquet.Column) tableWriter{
+	"csv":     newCSVTable,
+	"ndjson":  newNDJSONTable,
+	"parquet": newParquetTable,
+}
+
+type csvTable struct {
+	w      *csv.Writer
+	header []string
+	wrote  bool
+}
+
+func newCSVTable(w io.Writer, columns []parquet.Column) tableWriter {
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.Name
+	}
+	return &csvTable{w: csv.NewWriter(w), header: header}
+}
+
+func (t *csvTable) contentType() string { return "text/csv" }
+
+func (t *csvTable) write(row []any) error {
+	if !t.wrote {
+		t.wrote = true
+		if err := t.w.Write(t.header); err != nil {
+			return err
+		}
+	}
+	record := make([]string, len(row))
+	for i, v := range row {
+		switch v := v.(type) {
+		case string:
+			record[i] = v
+		case int64:
+			record[i] = strconv.FormatInt(v, 10)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return t.w.Write(record)
+}
+
+func (t *csvTable) close() error {
+	if !t.wrote {
+		t.wrote = true
+		t.w.Write(t.header)
+	}
+	t.w.Flush()
+	return t.w.Error()
+}
+
+type ndjsonTable struct {
+	enc     *json.Encoder
+	columns []parquet.Column
+}
+
+func newNDJSONTable(w io.Writer, columns []parquet.Column) tableWriter {
+	return &ndjsonTable{enc: json.NewEncoder(w), columns: columns}
+}
+
+func (t *ndjsonTable) contentType() string { return "application/x-ndjson" }
+
+func (t *ndjsonTable) write(row []any) error {
+	obj := make(map[string]any, len(row))
+	for i, c := range t.columns {
+		obj[c.Name] = row[i]
+	}
+	return t.enc.Encode(obj)
+}
+
+func (t *ndjsonTable) close() error { return nil }
+
+// parquetTable buffers a row group at a time, parquet puts each column of a
+// group together
+type parquetTable struct {
+	pw *parquet.Writer
+}
+
+func newParquetTable(w io.Writer, columns []parquet.Column) tableWriter {
+	return &parquetTable{pw: parquet.NewWriter(w, columns)}
+}
+
+func (t *parquetTable) contentType() string { return "application/vnd.apache.parquet" }
+
+func (t *parquetTable) write(row []any) error { return t.pw.Write(row) }
+
+func (t *parquetTable) close() error { return t.pw.Close() }
+
+// exportTarball sends the store's export archive as a .tar.gz
+func (srv *Server) exportTarball(w http.ResponseWriter) {
+	logging.Info("Export Handler called")
+
+	exporter, ok := srv.store.(ds.Exporter)
+	if !ok {
+		http.Error(w, "Export is not supported by this datastore", http.StatusNotImplemented)
+		return
+	}
+
+	filename := "data_export.tar.gz"
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+	if err := exporter.Export(w); err != nil {
+		logging.Error("export:", err)
+		http.Error(w, "Failed to package data", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,187 @@
+package frontend
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+)
+
+func export(t *testing.T, srv *Server, url string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s: status %d: %s", url, rec.Code, rec.Body.String())
+	}
+	return rec
+}
+
+func TestExportCSV(t *testing.T) {
+	srv := testServer(t)
+	rec := export(t, srv, "/export?format=csv&type=agents&agents=BRAVO")
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Fatalf("Content-Type %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, "2026-01-04-agents.csv") {
+		t.Fatalf("Content-Disposition %q", cd)
+	}
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if len(records) != 2 || strings.Join(records[0], ",") != "timestamp,symbol,credits,ships" || records[1][1] != "BRAVO" || records[1][2] != "250000" {
+		t.Fatalf("records = %v", records)
+	}
+
+	// no rows still has the header
+	rec = export(t, srv, "/export?format=csv&type=construction")
+	if strings.TrimSpace(rec.Body.String()) != "timestamp,jumpgate,material,required,fulfilled" {
+		t.Fatalf("empty construction export = %q", rec.Body.String())
+	}
+}
+
+func TestExportNDJSON(t *testing.T) {
+	srv := testServer(t)
+	now := time.Now().Add(-time.Minute).Unix()
+	err := srv.store.AddConstructions([]ds.JGConstruction{{Timestamp: now, Jumpgate: "X1-CD34-I1", Materials: ds.Materials{
+		{Symbol: "FAB_MATS", Required: 1600, Fulfilled: 500},
+		{Symbol: "ADVANCED_CIRCUITRY", Required: 400, Fulfilled: 20},
+	}}}, now)
+	if err != nil {
+		t.Fatalf("AddConstructions: %v", err)
+	}
+
+	rec := export(t, srv, "/export?format=ndjson&type=construction&jumpgates=x1-cd34-i1")
+	var rows []map[string]any
+	dec := json.NewDecoder(rec.Body)
+	for dec.More() {
+		var row map[string]any
+		if err := dec.Decode(&row); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) != 2 || rows[1]["material"] != "ADVANCED_CIRCUITRY" || rows[1]["fulfilled"] != float64(20) {
+		t.Fatalf("rows = %v", rows)
+	}
+
+	rec = export(t, srv, "/export?format=ndjson&type=leaderboard")
+	if body := rec.Body.String(); !strings.Contains(body, `{"board":"credits","rank":1,"symbol":"BRAVO","timestamp":`) || !strings.Contains(body, `"value":250000}`) {
+		t.Fatalf("leaderboard export = %s", rec.Body.String())
+	}
+}
+
+// leaderboard and stats export the whole reset, not just the latest
+func TestExportHistory(t *testing.T) {
+	srv := testServer(t)
+	ts := time.Now().Add(-30 * time.Second).Unix()
+	var status ds.ResponseStatus
+	status.ResetDate = "2026-01-04"
+	status.Stats.Agents = 3
+	for _, sym := range []string{"ALPHA", "BRAVO"} {
+		status.Leaderboards.MostCredits = append(status.Leaderboards.MostCredits, struct {
+			AgentSymbol string `json:"agentSymbol"`
+			Credits     int64  `json:"credits"`
+		}{AgentSymbol: sym, Credits: 300000})
+	}
+	if err := srv.store.StoreStats(status, ts); err != nil {
+		t.Fatalf("StoreStats: %v", err)
+	}
+	if err := srv.store.StoreLeaderboards(status, ts); err != nil {
+		t.Fatalf("StoreLeaderboards: %v", err)
+	}
+
+	records, err := csv.NewReader(export(t, srv, "/export?format=csv&type=stats").Body).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if len(records) != 3 || strings.Join(records[0], ",") != "timestamp,agents,accounts,ships,systems,waypoints" ||
+		records[1][1] != "2" || records[2][0] != fmt.Sprint(ts) || records[2][1] != "3" {
+		t.Fatalf("stats export = %v", records)
+	}
+
+	records, err = csv.NewReader(export(t, srv, "/export?format=csv&type=leaderboard&agents=BRAVO").Body).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if len(records) != 3 || records[1][2] != "1" || records[2][0] != fmt.Sprint(ts) || records[2][2] != "2" || records[2][3] != "BRAVO" {
+		t.Fatalf("leaderboard export = %v", records)
+	}
+}
+
+// a disk store is walked file by file, the export still comes out in order
+func TestExportCSV_DiskStore(t *testing.T) {
+	store, err := ds.NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	if err := store.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	day := int64(1767484800)
+	for _, ts := range []int64{day + 7200, day + 300, day + 86400} {
+		err := store.StoreAgents([]ds.PublicAgent{
+			{Symbol: "ALPHA", Credits: ts, Headquarters: "X1-AB12-A1"},
+			{Symbol: "BRAVO", Credits: ts, Headquarters: "X1-CD34-B2"},
+		}, ts)
+		if err != nil {
+			t.Fatalf("StoreAgents: %v", err)
+		}
+	}
+	srv, err := NewServer(store, ".", ".")
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	rec := export(t, srv, "/export?format=csv&type=agents&agents=ALPHA")
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	var got []string
+	for _, r := range records[1:] {
+		got = append(got, r[0]+" "+r[1])
+	}
+	want := []string{
+		fmt.Sprint(day+300, " ALPHA"), fmt.Sprint(day+7200, " ALPHA"), fmt.Sprint(day+86400, " ALPHA"),
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("rows = %v, want %v", got, want)
+	}
+}
+
+func TestExportParquet(t *testing.T) {
+	srv := testServer(t)
+	rec := export(t, srv, "/export?format=parquet&type=stats")
+	b := rec.Body.Bytes()
+	if !bytes.HasPrefix(b, []byte("PAR1")) || !bytes.HasSuffix(b, []byte("PAR1")) || !bytes.Contains(b, []byte("waypoints")) {
+		t.Fatalf("not a parquet file: %q", b)
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	srv := testServer(t)
+	for url, want := range map[string]int{
+		"/export?format=xlsx&type=agents":                 http.StatusBadRequest,
+		"/export?format=csv&type=ships":                   http.StatusBadRequest,
+		"/export?format=csv&type=agents&reset=2020-01-01": http.StatusNotFound,
+		// the memory store has no directory to tar
+		"/export": http.StatusNotImplemented,
+	} {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("GET %s: status %d, want %d", url, rec.Code, want)
+		}
+	}
+}
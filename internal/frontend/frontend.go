@@ -1,97 +1,269 @@
 package frontend
 
 import (
+	"context"
+	"errors"
 	"expvar"
+	"fmt"
 	"html/template"
+	"net"
 	"net/http"
-	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/papaburgs/fluffy-robot/internal/analytics"
+	"github.com/papaburgs/fluffy-robot/internal/config"
 	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+	"github.com/papaburgs/fluffy-robot/internal/events"
 	"github.com/papaburgs/fluffy-robot/internal/logging"
+	"github.com/papaburgs/fluffy-robot/internal/metrics"
 )
 
-var (
-	resets []string
-	t      *template.Template
-)
+// Server serves the dashboard from a single Store. Each Server has its own
+// mux so several can run side by side in one process
+type Server struct {
+	store ds.Store
+	t     *template.Template
+	mux   *http.ServeMux
+
+	resetsMu sync.RWMutex
+	resets   []string
+
+	// importToken guards /import, which is off while it is empty
+	importToken string
+	// events feeds /events, which is off while it is nil
+	events *events.Bus
+	// stallWindow is how long a jumpgate goes without deliveries before
+	// it is shown as stalled
+	stallWindow time.Duration
+	// staleAfter is how old the newest data gets before the health checks
+	// fail, counted from started until there is any
+	staleAfter time.Duration
+	started    time.Time
+	// closing is closed when the server starts shutting down, so streams
+	// that never go idle end
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+// shutdownTimeout is how long open requests get to finish once the server
+// is asked to stop
+const shutdownTimeout = 30 * time.Second
 
-func StartServer() {
+func NewServer(store ds.Store, templateDir, staticDir string) (*Server, error) {
 	funcMap := template.FuncMap{
 		"add": func(a, b int) int {
 			return a + b
 		},
+		"neg": func(a int) int {
+			return -a
+		},
+		"percent": func(f float64) string {
+			return fmt.Sprintf("%.0f%%", f*100)
+		},
 		"unixTime": func(ts int64) string {
 			return time.Unix(ts, 0).Format("2006-01-02 15:04")
 		},
-		"constructionStatus": func(s uint8) string {
-			switch s {
-			case 0:
-				return "NoActivity"
-			case 1:
-				return "Active"
-			case 2:
-				return "Const"
-			case 3:
-				return "Complete"
-			default:
-				return "Unknown"
-			}
+		"unixDate": func(ts int64) string {
+			return time.Unix(ts, 0).UTC().Format(time.DateOnly)
 		},
+		"materialName":       materialName,
+		"constructionStatus": constructionStatusName,
 	}
 
-	templateDir := "internal/frontend"
-	if env, ok := os.LookupEnv("FLUFFY_TEMPLATE_DIR"); ok {
-		templateDir = env
+	t, err := template.New("").Funcs(funcMap).ParseGlob(filepath.Join(templateDir, "templates", "*.html"))
+	if err != nil {
+		return nil, err
 	}
-	t = template.Must(template.New("").Funcs(funcMap).ParseGlob(filepath.Join(templateDir, "templates", "*.html")))
 
-	staticDir := "internal/frontend"
-	if env, ok := os.LookupEnv("FLUFFY_STATIC_DIR"); ok {
-		staticDir = env
+	srv := &Server{
+		store:       store,
+		t:           t,
+		mux:         http.NewServeMux(),
+		resets:      store.AllResets(),
+		stallWindow: analytics.DefaultStallWindow,
+		staleAfter:  config.Default().Frontend.StaleAfter,
+		started:     time.Now(),
+		closing:     make(chan struct{}),
 	}
+
 	fs := http.FileServer(http.Dir(filepath.Join(staticDir, "static")))
-	http.Handle("/static/", http.StripPrefix("/static/", fs))
+	srv.mux.Handle("/static/", http.StripPrefix("/static/", fs))
 
-	var portNumber string = ":8845"
-	if pn, ok := os.LookupEnv("FLUFFY_PORT"); ok {
-		portNumber = pn
+	srv.mux.HandleFunc("/", srv.needsReset(srv.RootHandler))
+	srv.mux.HandleFunc("/permissions", srv.needsReset(srv.PermissionsHandler))
+	srv.mux.HandleFunc("/status", srv.needsReset(srv.HeaderHandler))
+	srv.mux.HandleFunc("/chart", srv.needsReset(srv.LoadChartHandler))
+	srv.mux.HandleFunc("/permissions-grid", srv.needsReset(srv.PermissionsGridHandler))
+	srv.mux.HandleFunc("/agents", srv.needsReset(srv.AgentsHandler))
+	srv.mux.HandleFunc("/agents-grid", srv.needsReset(srv.AgentsGridHandler))
+
+	srv.mux.HandleFunc("/leaderboard", srv.needsReset(srv.LeaderboardHandler))
+	srv.mux.HandleFunc("/stats", srv.needsReset(srv.StatsHandler))
+	srv.mux.HandleFunc("/jumpgates", srv.needsReset(srv.JumpgatesHandler))
+	srv.mux.HandleFunc("/compare", srv.CompareHandler)
+	srv.mux.HandleFunc("GET /agent/{symbol}", srv.needsReset(srv.AgentHandler))
+
+	srv.mux.HandleFunc("/export", srv.ExportHandler)
+	srv.mux.HandleFunc("POST /import", srv.ImportHandler)
+	srv.mux.HandleFunc("GET /events", srv.EventsHandler)
+	srv.mux.HandleFunc("GET /healthz", srv.HealthzHandler)
+	srv.mux.HandleFunc("GET /readyz", srv.ReadyzHandler)
+
+	srv.registerAPI()
+
+	srv.mux.Handle("/debug/vars", expvar.Handler())
+	srv.mux.Handle("GET /metrics", metrics.Handler())
+
+	return srv, nil
+}
+
+// constructionStatusName is how a jumpgate status is shown on the pages and
+// in the API
+func constructionStatusName(s ds.ConstructionStatus) string {
+	switch s {
+	case 0:
+		return "NoActivity"
+	case 1:
+		return "Active"
+	case 2:
+		return "Const"
+	case 3:
+		return "Complete"
+	default:
+		return "Unknown"
+	}
+}
+
+// ServeHTTP times every request into the latency histogram, labelled with
+// the mux pattern that matched so paths with symbols in them share a series
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	srv.mux.ServeHTTP(sw, r)
+	endpoint := r.Pattern
+	if endpoint == "" {
+		endpoint = "unmatched"
+	}
+	metrics.HTTPRequestDuration.WithLabelValues(endpoint, strconv.Itoa(sw.status)).Observe(time.Since(start).Seconds())
+}
+
+// statusWriter remembers the status code written through it
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if !sw.wroteHeader {
+		sw.status = code
+		sw.wroteHeader = true
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	sw.wroteHeader = true
+	return sw.ResponseWriter.Write(b)
+}
+
+// Flush keeps /events streaming
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// StartServer serves store until ctx is cancelled, pushing bus's events to
+// open pages, then drains open requests. It only returns an error if the
+// server could not start or stop cleanly
+func StartServer(ctx context.Context, cfg config.Frontend, store ds.Store, bus *events.Bus) error {
+	srv, err := NewServer(store, cfg.TemplateDir, cfg.StaticDir)
+	if err != nil {
+		return fmt.Errorf("loading templates: %w", err)
+	}
+	srv.importToken = cfg.ImportToken
+	srv.events = bus
+	if cfg.StallWindow > 0 {
+		srv.stallWindow = cfg.StallWindow
 	}
+	if cfg.StaleAfter > 0 {
+		srv.staleAfter = cfg.StaleAfter
+	}
+
+	portNumber := cfg.Port
 	if !strings.HasPrefix(portNumber, ":") {
 		portNumber = ":" + portNumber
 	}
+	ln, err := net.Listen("tcp", portNumber)
+	if err != nil {
+		return err
+	}
 
-	go updateResetLoop()
+	go srv.updateResetLoop(ctx)
+	if bus != nil {
+		go srv.watchResets(ctx)
+	}
 
-	http.HandleFunc("/", RootHandler)
-	http.HandleFunc("/permissions", PermissionsHandler)
-	http.HandleFunc("/status", HeaderHandler)
-	http.HandleFunc("/chart", LoadChartHandler)
-	http.HandleFunc("/permissions-grid", PermissionsGridHandler)
-	http.HandleFunc("/agents", AgentsHandler)
-	http.HandleFunc("/agents-grid", AgentsGridHandler)
+	logging.Info("Starting server on http://localhost on " + portNumber)
+	return srv.serve(ctx, ln)
+}
 
-	http.HandleFunc("/leaderboard", LeaderboardHandler)
-	http.HandleFunc("/stats", StatsHandler)
-	http.HandleFunc("/jumpgates", JumpgatesHandler)
+// serve answers on ln until ctx is cancelled, then stops accepting, ends
+// the event streams and waits up to shutdownTimeout for the rest
+func (srv *Server) serve(ctx context.Context, ln net.Listener) error {
+	hs := &http.Server{
+		Handler:           srv,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	hs.RegisterOnShutdown(srv.shutdown)
 
-	http.HandleFunc("/export", ExportHandler)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- hs.Serve(ln)
+	}()
 
-	http.Handle("/debug/vars", expvar.Handler())
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	logging.Info("stopping server, draining connections")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := hs.Shutdown(shutdownCtx); err != nil {
+		hs.Close()
+		return fmt.Errorf("draining connections: %w", err)
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	logging.Info("server stopped")
+	return nil
+}
 
-	logging.Info("Starting server on http://localhost on " + portNumber)
-	logging.Warn("Server Done", http.ListenAndServe(portNumber, nil))
+// shutdown tells long lived handlers to finish
+func (srv *Server) shutdown() {
+	srv.closeOnce.Do(func() {
+		close(srv.closing)
+	})
 }
 
-func updateResetLoop() {
+func (srv *Server) updateResetLoop(ctx context.Context) {
 	for {
 		// logging.Debug("find all resets we have data for")
-		resets = ds.AllResets()
+		srv.refreshResets()
 		// logging.Debug("find next reset")
-		nextReset := ds.NextReset()
+		nextReset := ds.NextReset(srv.store)
 
 		// logging.Debug("Ready to sleep", "resets", resets, "next reset", nextReset)
 
@@ -100,8 +272,83 @@ func updateResetLoop() {
 			sleepDuration = 5 * time.Minute
 		}
 		// logging.Debug("sleeping until next reset check", "duration", sleepDuration)
-		time.Sleep(sleepDuration)
+		t := time.NewTimer(sleepDuration)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// newestReset is the most recent reset found on the last scan, falling back
+// to the store's current reset before the first scan finds anything. It is
+// empty until the collector has found the reset
+func (srv *Server) newestReset() ds.Reset {
+	srv.resetsMu.RLock()
+	defer srv.resetsMu.RUnlock()
+	if len(srv.resets) == 0 {
+		return srv.store.CurrentReset()
+	}
+	return ds.Reset(srv.resets[0])
+}
+
+var errNoReset = errors.New("no reset collected yet, try again shortly")
+
+// needsReset answers 503 until there is a reset to show, rather than
+// rendering pages for a reset with no name
+func (srv *Server) needsReset(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if srv.newestReset() == "" {
+			http.Error(w, errNoReset.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// refreshResets rescans the store for resets
+func (srv *Server) refreshResets() {
+	resets := srv.store.AllResets()
+	srv.resetsMu.Lock()
+	srv.resets = resets
+	srv.resetsMu.Unlock()
+}
+
+// knownResets is the list from the last scan, newest first
+func (srv *Server) knownResets() []string {
+	srv.resetsMu.RLock()
+	defer srv.resetsMu.RUnlock()
+	return append([]string{}, srv.resets...)
+}
+
+// requestReset is the reset named by the "reset" query parameter if we have
+// data for it, otherwise the newest one. Only known names are accepted since
+// the reset is used as a directory name
+func (srv *Server) requestReset(r *http.Request) ds.Reset {
+	if want := r.URL.Query().Get("reset"); want != "" {
+		for _, known := range srv.knownResets() {
+			if known == want {
+				return ds.Reset(want)
+			}
+		}
+	}
+	return srv.newestReset()
+}
+
+// resetEnd is where charts for thisReset end: now for the newest reset,
+// the time it was replaced for older ones
+func (srv *Server) resetEnd(thisReset ds.Reset) time.Time {
+	now := time.Now()
+	if thisReset == srv.newestReset() {
+		return now
+	}
+	st, err := srv.store.GetStats(thisReset)
+	if err != nil || st.NextReset.IsZero() || st.NextReset.After(now) {
+		return now
 	}
+	return st.NextReset
 }
 
 func mergeAgents(args ...any) []string {
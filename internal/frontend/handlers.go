@@ -1,35 +1,40 @@
 package frontend
 
 import (
-	"archive/tar"
-	"compress/gzip"
-	"fmt"
 	"html/template"
-	"io"
 	"net/http"
-	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/papaburgs/fluffy-robot/internal/analytics"
 	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
 	"github.com/papaburgs/fluffy-robot/internal/logging"
 	"github.com/papaburgs/fluffy-robot/internal/metrics"
 )
 
-func RootHandler(w http.ResponseWriter, r *http.Request) {
+func (srv *Server) RootHandler(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
-	w.Header().Set("Content-Type", "text/html")
 	logging.Info("Incoming request", "endpoint", "index")
-	if err := t.ExecuteTemplate(w, "index.html", map[string]interface{}{"Reset": ds.LatestReset()}); err != nil {
+	srv.renderIndex(w, r, "/leaderboard")
+	metrics.RecordDuration("root", start)
+}
+
+// renderIndex serves the dashboard with content loaded into its content
+// area, so pages that are fragments can be opened directly too
+func (srv *Server) renderIndex(w http.ResponseWriter, r *http.Request, content string) {
+	w.Header().Set("Content-Type", "text/html")
+	if err := srv.t.ExecuteTemplate(w, "index.html", map[string]interface{}{
+		"Reset":   string(srv.requestReset(r)),
+		"Resets":  srv.knownResets(),
+		"Content": content,
+	}); err != nil {
 		logging.Error("template error", err)
 	}
-	metrics.RecordDuration("root", start)
 }
 
-func LoadChartHandler(w http.ResponseWriter, r *http.Request) {
+func (srv *Server) LoadChartHandler(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	q := r.URL.Query()
 	storageAgents := q.Get("storageAgents")
@@ -57,24 +62,34 @@ func LoadChartHandler(w http.ResponseWriter, r *http.Request) {
 		duration = 7 * 24 * time.Hour
 		title = "Last 7 days"
 	default:
+		period = "1h"
 		duration = 1 * time.Hour
 		title = "Last Hour"
 	}
-	startTime = time.Now().Add(-1 * duration).Unix()
-
-	thisReset := ds.Reset(resets[0])
+	pageData.Period = period
+	thisReset := srv.requestReset(r)
+	end := srv.resetEnd(thisReset)
+	startTime = end.Add(-1 * duration).Unix()
 
-	agentHist, _ := ds.GetAgentHistory(thisReset, startTime, 0)
-	jgList, _ := ds.GetJumpgateList(thisReset)
-	constrList, _ := ds.GetConstructions(thisReset, startTime, 0)
+	agentSeries, _, _ := ds.AgentSeries(srv.store, thisReset, chartAgents, startTime, end.Unix(), targetDataPoints)
+	jgList, _ := srv.store.GetJumpgateList(thisReset)
 
 	agentsLookup := make(map[string]ds.Agent)
-	aList, _ := ds.GetAgentList(thisReset)
+	aList, _ := srv.store.GetAgentList(thisReset)
 	agentsLookup = agentsMap(aList)
 	jgLookup := jumpgatesMap(jgList)
 
-	creditChart = CreditChart(chartAgents, agentHist, duration, title)
-	shipChart = ShipChart(chartAgents, agentHist, duration, title)
+	chartJumpgates := make([]string, 0, len(chartAgents))
+	for _, a := range chartAgents {
+		if jg, ok := jgLookup[agentsLookup[a].System]; ok {
+			chartJumpgates = append(chartJumpgates, jg.Jumpgate)
+		}
+	}
+	constrSeries, _, _ := ds.ConstructionSeries(srv.store, thisReset, chartJumpgates, startTime, end.Unix(), targetDataPoints)
+	constrList := constructionRows(constrSeries)
+
+	creditChart = CreditChart(chartAgents, agentSeries, duration, end, title)
+	shipChart = ShipChart(chartAgents, agentSeries, duration, end, title)
 
 	if creditChart != nil {
 		snippet := creditChart.RenderSnippet()
@@ -94,10 +109,16 @@ func LoadChartHandler(w http.ResponseWriter, r *http.Request) {
 
 	overview := latestConstructionRecords(agentsLookup, jgLookup, constrList, chartAgents)
 	pageData.ConstructionTable = overview
+	recipes := make([]ds.Materials, 0, len(overview))
+	for _, o := range overview {
+		recipes = append(recipes, o.Materials)
+	}
+	pageData.Materials = materialSymbols(recipes...)
 	overview = nil
+	recipes = nil
 
 	recs := constructionRecords(agentsLookup, jgLookup, constrList, chartAgents)
-	constChart := JumpgateConstructionChart(recs, duration)
+	constChart := JumpgateConstructionChart(recs, duration, end)
 	if constChart != nil {
 		snippet := constChart.RenderSnippet()
 		pageData.ConstructionChart = ChartSnippet{
@@ -107,27 +128,28 @@ func LoadChartHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	recs = nil
 
-	agentHist = nil
+	agentSeries = nil
 	aList = nil
 	jgList = nil
 	constrList = nil
+	constrSeries = nil
 
 	w.Header().Set("Content-Type", "text/html")
-	if err := RenderChartFragment(w, pageData); err != nil {
+	if err := srv.RenderChartFragment(w, pageData); err != nil {
 		logging.Error("template error", err)
 	}
 	metrics.RecordDuration("chart", start)
 }
 
-func PermissionsHandler(w http.ResponseWriter, r *http.Request) {
+func (srv *Server) PermissionsHandler(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
-	aList, err := ds.GetAgentList(ds.Reset(resets[0]))
+	aList, err := srv.store.GetAgentList(srv.requestReset(r))
 	if err != nil {
 		logging.Error("error loading agents", err)
 	}
 	agents := agentsMap(aList)
 
-	if err := t.ExecuteTemplate(w, "permissions.html", map[string]interface{}{
+	if err := srv.t.ExecuteTemplate(w, "permissions.html", map[string]interface{}{
 		"Agents": agents,
 	}); err != nil {
 		logging.Error("template error", err)
@@ -137,77 +159,20 @@ func PermissionsHandler(w http.ResponseWriter, r *http.Request) {
 	metrics.RecordDuration("permissions", start)
 }
 
-func HeaderHandler(w http.ResponseWriter, r *http.Request) {
+func (srv *Server) HeaderHandler(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
-	if err := t.ExecuteTemplate(w, "header.html", map[string]interface{}{
-		"Reset": ds.LatestReset(),
+	if err := srv.t.ExecuteTemplate(w, "header.html", map[string]interface{}{
+		"Reset":  string(srv.requestReset(r)),
+		"Resets": srv.knownResets(),
 	}); err != nil {
 		logging.Error("template error", err)
 	}
 	metrics.RecordDuration("header", start)
 }
 
-func ExportHandler(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	logging.Info("Export Handler called")
-
-	filename := "data_export.tar.gz"
-	w.Header().Set("Content-Type", "application/gzip")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-
-	gw := gzip.NewWriter(w)
-	defer gw.Close()
-	tw := tar.NewWriter(gw)
-	defer tw.Close()
-
-	srcDir := ds.DataPath()
-
-	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if path == srcDir {
-			return nil
-		}
-
-		header, err := tar.FileInfoHeader(info, info.Name())
-		if err != nil {
-			return err
-		}
-
-		relPath, err := filepath.Rel(srcDir, path)
-		if err != nil {
-			return err
-		}
-		header.Name = relPath
-
-		if err := tw.WriteHeader(header); err != nil {
-			return err
-		}
-
-		if info.IsDir() {
-			return nil
-		}
-
-		file, err := os.Open(path)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-
-		_, err = io.Copy(tw, file)
-		return err
-	})
-
-	if err != nil {
-		http.Error(w, "Failed to package data", http.StatusInternalServerError)
-	}
-	metrics.RecordDuration("export", start)
-}
-
-func PermissionsGridHandler(w http.ResponseWriter, r *http.Request) {
+func (srv *Server) PermissionsGridHandler(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
-	aList, err := ds.GetAgentList(ds.LatestReset())
+	aList, err := srv.store.GetAgentList(srv.requestReset(r))
 	if err != nil {
 		logging.Error("error loading agents", err)
 	}
@@ -264,105 +229,256 @@ func PermissionsGridHandler(w http.ResponseWriter, r *http.Request) {
 	aList = nil
 	storageAgentsMap = nil
 
-	if err := t.ExecuteTemplate(w, "permissions-grid.html", d); err != nil {
+	if err := srv.t.ExecuteTemplate(w, "permissions-grid.html", d); err != nil {
 		logging.Error("template error", err)
 	}
 	metrics.RecordDuration("permissions_grid", start)
 }
 
-func LeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+// RankChange is how many places an agent moved since an earlier
+// leaderboard, positive is up
+type RankChange struct {
+	Places int
+	// Known is false when no leaderboard is that old
+	Known bool
+	// New is set when the agent was not on the earlier board
+	New bool
+}
+
+type LeaderboardRow struct {
+	Rank      int
+	Symbol    string
+	Value     int64
+	Change1h  RankChange
+	Change24h RankChange
+}
+
+// rankChartAgents is how many of the top agents the rank chart follows
+const rankChartAgents = 10
+
+func board(s ds.LeaderboardSnapshot, leaderboardType string) []ds.LeaderboardEntry {
+	if leaderboardType == "charts" {
+		return s.ChartsList
+	}
+	return s.CreditsList
+}
+
+// rankChange compares rank with where symbol was in the latest snapshot at
+// or before ts
+func rankChange(history []ds.LeaderboardSnapshot, ts int64, leaderboardType, symbol string, rank int) RankChange {
+	earlier, ok := ds.LeaderboardAt(history, ts)
+	if !ok {
+		return RankChange{}
+	}
+	was := ds.Rank(board(earlier, leaderboardType), symbol)
+	if was == 0 {
+		return RankChange{Known: true, New: true}
+	}
+	return RankChange{Places: was - rank, Known: true}
+}
+
+// leaderboardRows is the board with each agent's move over the last hour
+// and day, measured back from the latest stored snapshot
+func leaderboardRows(entries []ds.LeaderboardEntry, history []ds.LeaderboardSnapshot, leaderboardType string) []LeaderboardRow {
+	var latest int64
+	if len(history) > 0 {
+		latest = history[len(history)-1].Timestamp
+	}
+	rows := make([]LeaderboardRow, 0, len(entries))
+	for i, e := range entries {
+		row := LeaderboardRow{Rank: i + 1, Symbol: e.Symbol, Value: e.Value}
+		if latest != 0 {
+			row.Change1h = rankChange(history, latest-3600, leaderboardType, e.Symbol, row.Rank)
+			row.Change24h = rankChange(history, latest-86400, leaderboardType, e.Symbol, row.Rank)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (srv *Server) LeaderboardHandler(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	leaderboardType := r.URL.Query().Get("type")
 	if leaderboardType == "" {
 		leaderboardType = "credits"
 	}
 	myAgent := r.URL.Query().Get("myAgent")
+	thisReset := srv.requestReset(r)
 
-	creditLB, chartLB, err := ds.GetLeaderboard(ds.LatestReset())
+	creditLB, chartLB, err := srv.store.GetLeaderboard(thisReset)
 	if err != nil {
 		logging.Error("error loading leaderboard", err)
 		creditLB = nil
 		chartLB = nil
 	}
+	history, err := srv.store.GetLeaderboardHistory(thisReset, 0, 0)
+	if err != nil {
+		logging.Error("error loading leaderboard history", err)
+		history = nil
+	}
 
 	data := creditLB
 	if leaderboardType == "charts" {
 		data = chartLB
 	}
+	rows := leaderboardRows(data, history, leaderboardType)
 
-	err = t.ExecuteTemplate(w, "leaderboard.html", map[string]interface{}{
-		"Type":    leaderboardType,
-		"Data":    data,
-		"MyAgent": myAgent,
+	var rankChart ChartSnippet
+	if len(history) > 0 {
+		chartAgents := []string{}
+		for _, e := range data[:min(len(data), rankChartAgents)] {
+			chartAgents = append(chartAgents, e.Symbol)
+		}
+		chartAgents = mergeAgents(chartAgents, myAgent)
+		points := ds.RankPoints(thinPoints(history, targetDataPoints), chartAgents)
+		snippet := RankChart(chartAgents, points, leaderboardType).RenderSnippet()
+		rankChart = ChartSnippet{
+			Element: template.HTML(snippet.Element),
+			Script:  template.HTML(snippet.Script),
+		}
+	}
+
+	err = srv.t.ExecuteTemplate(w, "leaderboard.html", map[string]interface{}{
+		"Type":      leaderboardType,
+		"Data":      rows,
+		"MyAgent":   myAgent,
+		"RankChart": rankChart,
 	})
 	creditLB = nil
 	chartLB = nil
 	data = nil
+	history = nil
 	if err != nil {
 		logging.Error("template error", err)
 	}
 	metrics.RecordDuration("leaderboard", start)
 }
 
-func StatsHandler(w http.ResponseWriter, r *http.Request) {
+type StatsPageData struct {
+	ds.Stats
+	GrowthChart  ChartSnippet
+	RateChart    ChartSnippet
+	CompareChart ChartSnippet
+}
+
+func (srv *Server) StatsHandler(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
-	stats, err := ds.GetStats(ds.LatestReset())
+	thisReset := srv.requestReset(r)
+	stats, err := srv.store.GetStats(thisReset)
 	if err != nil {
 		logging.Error("error loading stats", err)
 		stats = ds.Stats{}
 	}
-	if err := t.ExecuteTemplate(w, "stats.html", stats); err != nil {
+	pageData := StatsPageData{Stats: stats}
+
+	history, err := srv.store.GetStatsHistory(thisReset, 0, 0)
+	if err != nil {
+		logging.Error("error loading stats history", err)
+		history = nil
+	}
+	if len(history) > 0 {
+		snippet := StatsGrowthChart(thinPoints(history, targetDataPoints)).RenderSnippet()
+		pageData.GrowthChart = ChartSnippet{
+			Element: template.HTML(snippet.Element),
+			Script:  template.HTML(snippet.Script),
+		}
+		snippet = StatsRateChart(ds.StatsRates(history, 3600)).RenderSnippet()
+		pageData.RateChart = ChartSnippet{
+			Element: template.HTML(snippet.Element),
+			Script:  template.HTML(snippet.Script),
+		}
+	}
+	history = nil
+
+	histories := make(map[ds.Reset][]ds.StatsPoint)
+	for _, name := range srv.knownResets() {
+		hist, _ := srv.store.GetStatsHistory(ds.Reset(name), 0, 0)
+		if len(hist) > 0 {
+			histories[ds.Reset(name)] = thinPoints(hist, targetDataPoints)
+		}
+	}
+	if len(histories) > 1 {
+		snippet := StatsCompareChart(histories).RenderSnippet()
+		pageData.CompareChart = ChartSnippet{
+			Element: template.HTML(snippet.Element),
+			Script:  template.HTML(snippet.Script),
+		}
+	}
+	histories = nil
+
+	if err := srv.t.ExecuteTemplate(w, "stats.html", pageData); err != nil {
 		logging.Error("template error", err)
 	}
 	metrics.RecordDuration("stats", start)
 }
 
-func JumpgatesHandler(w http.ResponseWriter, r *http.Request) {
+// ForecastRow is an agent's jumpgate on the jumpgates page
+type ForecastRow struct {
+	Agent string
+	analytics.Forecast
+}
+
+// sortForecastRows puts unfinished gates first, soonest ETA first with the
+// ones without an ETA after them, then finished gates
+func sortForecastRows(rows []ForecastRow) {
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Complete != b.Complete {
+			return !a.Complete
+		}
+		if (a.ETA == 0) != (b.ETA == 0) {
+			return a.ETA != 0
+		}
+		if a.ETA != b.ETA {
+			return a.ETA < b.ETA
+		}
+		return a.Agent < b.Agent
+	})
+}
+
+func (srv *Server) JumpgatesHandler(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
-	thisReset := ds.LatestReset()
+	thisReset := srv.requestReset(r)
 
-	aList, _ := ds.GetAgentList(thisReset)
-	jgList, _ := ds.GetJumpgateList(thisReset)
-	constrList, _ := ds.GetConstructions(thisReset, 0, 0)
+	aList, _ := srv.store.GetAgentList(thisReset)
+	jgList, _ := srv.store.GetJumpgateList(thisReset)
+	constrList, _ := srv.store.GetConstructions(thisReset, 0, 0)
 
 	agentsLookup := agentsMap(aList)
 	jumpgates := jumpgatesMap(jgList)
 
-	allNames := make([]string, 0, len(agentsLookup))
-	for name := range agentsLookup {
-		allNames = append(allNames, name)
-	}
-	construction := latestConstructionRecords(agentsLookup, jumpgates, constrList, allNames)
-	constructMap := make(map[string]ds.ConstructionOverview, len(construction))
-	for _, c := range construction {
-		constructMap[c.Agent] = c
-	}
+	constructMap := constructionOverviews(agentsLookup, jumpgates, constrList)
+	forecasts, _ := analytics.ComputeForecasts(srv.store, thisReset, nil, srv.resetEnd(thisReset), srv.stallWindow)
 
 	rows := []ConstructionParallelRow{}
+	forecastRows := []ForecastRow{}
 	for name, a := range agentsLookup {
 		jg, ok := jumpgates[a.System]
 		if !ok || jg.Status == ds.NoActivity {
 			continue
 		}
 		co, hasConstruct := constructMap[name]
-		if !hasConstruct || (co.Fabmat == 0 && co.Advcct == 0) {
+		if !hasConstruct || !co.Materials.Started() {
 			continue
 		}
 		rows = append(rows, ConstructionParallelRow{
-			Agent:    name,
-			Jumpgate: jg.Jumpgate,
-			Fabmat:   co.Fabmat,
-			Advcct:   co.Advcct,
+			Agent:     name,
+			Jumpgate:  jg.Jumpgate,
+			Materials: co.Materials,
 		})
+		if fc, ok := forecasts[jg.Jumpgate]; ok {
+			forecastRows = append(forecastRows, ForecastRow{Agent: name, Forecast: fc})
+		}
 	}
+	sortForecastRows(forecastRows)
 
 	aList = nil
 	jgList = nil
 	constrList = nil
 	agentsLookup = nil
 	jumpgates = nil
-	construction = nil
 	constructMap = nil
+	forecasts = nil
 
 	parallel := ConstructionParallelChart(rows)
 	rows = nil
@@ -370,15 +486,19 @@ func JumpgatesHandler(w http.ResponseWriter, r *http.Request) {
 	snippet := parallel.RenderSnippet()
 	pageData := struct {
 		ParallelChart ChartSnippet
+		Forecasts     []ForecastRow
+		StallWindow   time.Duration
 	}{
 		ParallelChart: ChartSnippet{
 			Element: template.HTML(snippet.Element),
 			Script:  template.HTML(snippet.Script),
 		},
+		Forecasts:   forecastRows,
+		StallWindow: srv.stallWindow,
 	}
 
 	w.Header().Set("Content-Type", "text/html")
-	if err := t.ExecuteTemplate(w, "jumpgates.html", pageData); err != nil {
+	if err := srv.t.ExecuteTemplate(w, "jumpgates.html", pageData); err != nil {
 		logging.Error("template error", err)
 	}
 	metrics.RecordDuration("jumpgates", start)
@@ -417,17 +537,27 @@ type AgentRow struct {
 	MultiSystem   bool
 	IsChecked     bool
 	ShowConstruct bool
+	// CreditsPerHour and the rest are from the analytics package
+	CreditsPerHour       float64
+	RecentCreditsPerHour float64
+	Acceleration         float64
+	ShipsPerDay          float64
+	ProjectedCredits     int64
+	// ConstructionETA is when the agent's jumpgate should be done, 0 when
+	// it is done or there is no rate yet
+	ConstructionETA     int64
+	ConstructionStalled bool
 }
 
-func AgentsHandler(w http.ResponseWriter, r *http.Request) {
+func (srv *Server) AgentsHandler(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
-	thisReset := ds.LatestReset()
+	thisReset := srv.requestReset(r)
 
 	uniqueFactions := make(map[string]factionInfo)
 	for _, fi := range factionMap {
 		uniqueFactions[fi.Symbol] = fi
 	}
-	aList, _ := ds.GetAgentList(thisReset)
+	aList, _ := srv.store.GetAgentList(thisReset)
 	systemSet := make(map[string]bool)
 	for _, a := range aList {
 		systemSet[a.System] = true
@@ -439,7 +569,7 @@ func AgentsHandler(w http.ResponseWriter, r *http.Request) {
 	sort.Strings(systemList)
 	aList = nil
 
-	if err := t.ExecuteTemplate(w, "agents.html", map[string]interface{}{
+	if err := srv.t.ExecuteTemplate(w, "agents.html", map[string]interface{}{
 		"Factions": uniqueFactions,
 		"Systems":  systemList,
 	}); err != nil {
@@ -448,19 +578,24 @@ func AgentsHandler(w http.ResponseWriter, r *http.Request) {
 	metrics.RecordDuration("agents", start)
 }
 
-func AgentsGridHandler(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	thisReset := ds.LatestReset()
-
-	aList, _ := ds.GetAgentList(thisReset)
-	agentHist, _ := ds.GetAgentHistory(thisReset, 0, 0)
-	jgList, _ := ds.GetJumpgateList(thisReset)
-	constrList, _ := ds.GetConstructions(thisReset, 0, 0)
-
-	agentsLookup := agentsMap(aList)
-	ships := latestShips(agentHist)
-	jumpgates := jumpgatesMap(jgList)
+// agentFilter narrows and orders the agents table, shared by the agents grid
+// and the agents API
+type agentFilter struct {
+	search           string
+	faction          string
+	system           string
+	sortBy           string
+	hideInactive     bool
+	constructionOnly bool
+	// symbols keeps only these agents when set
+	symbols map[string]bool
+	// checked marks agents selected for charting
+	checked map[string]bool
+}
 
+// constructionOverviews is the latest construction record of every agent's
+// jumpgate, keyed by agent
+func constructionOverviews(agentsLookup map[string]ds.Agent, jumpgates map[string]ds.JGInfo, constrList []ds.JGConstruction) map[string]ds.ConstructionOverview {
 	allNames := make([]string, 0, len(agentsLookup))
 	for name := range agentsLookup {
 		allNames = append(allNames, name)
@@ -470,39 +605,44 @@ func AgentsGridHandler(w http.ResponseWriter, r *http.Request) {
 	for _, c := range construction {
 		constructMap[c.Agent] = c
 	}
+	return constructMap
+}
+
+// agentRows builds the agents table for thisReset
+func (srv *Server) agentRows(thisReset ds.Reset, f agentFilter) []AgentRow {
+	aList, _ := srv.store.GetAgentList(thisReset)
+	agentHist, _ := srv.store.GetAgentHistory(thisReset, 0, 0)
+	jgList, _ := srv.store.GetJumpgateList(thisReset)
+	constrList, _ := srv.store.GetConstructions(thisReset, 0, 0)
+
+	agentsLookup := agentsMap(aList)
+	ships := latestShips(agentHist)
+	jumpgates := jumpgatesMap(jgList)
+	constructMap := constructionOverviews(agentsLookup, jumpgates, constrList)
+	derived, _ := analytics.Compute(srv.store, thisReset, nil)
+	forecasts, _ := analytics.ComputeForecasts(srv.store, thisReset, nil, srv.resetEnd(thisReset), srv.stallWindow)
 
 	systemCount := make(map[string]int)
 	for _, a := range aList {
 		systemCount[a.System]++
 	}
 
-	storageAgents := r.URL.Query().Get("storageAgents")
-	paramAgents := r.URL.Query().Get("paramAgents")
-	storageAgentsMap := make(map[string]bool)
-	for _, i := range mergeAgents(storageAgents, paramAgents) {
-		storageAgentsMap[i] = true
-	}
-
-	q := r.URL.Query()
-	searchStr := strings.ToLower(q.Get("agentSearch"))
-	hideInactive := q.Get("hideInactive") == "on"
-	sortBy := q.Get("sortBy")
-	filterFaction := q.Get("faction")
-	filterSystem := q.Get("system")
-	showConstructionOnly := q.Get("showConstruction") == "on"
-
+	search := strings.ToLower(f.search)
 	rows := []AgentRow{}
 	for name, a := range agentsLookup {
-		if searchStr != "" && !strings.Contains(strings.ToLower(name), searchStr) {
+		if f.symbols != nil && !f.symbols[name] {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(name), search) {
 			continue
 		}
-		if hideInactive && a.Credits == 175000 {
+		if f.hideInactive && a.Credits == 175000 {
 			continue
 		}
-		if filterFaction != "" && a.Faction != filterFaction {
+		if f.faction != "" && a.Faction != f.faction {
 			continue
 		}
-		if filterSystem != "" && a.System != filterSystem {
+		if f.system != "" && a.System != f.system {
 			continue
 		}
 
@@ -511,7 +651,7 @@ func AgentsGridHandler(w http.ResponseWriter, r *http.Request) {
 			constructStr, hasConstruct = constructionString(co, jumpgates[a.System])
 		}
 
-		if showConstructionOnly && !hasConstruct {
+		if f.constructionOnly && !hasConstruct {
 			continue
 		}
 
@@ -523,37 +663,73 @@ func AgentsGridHandler(w http.ResponseWriter, r *http.Request) {
 			factionName = fi.Name
 		}
 
+		var fc analytics.Forecast
+		if hasConstruct {
+			fc = forecasts[jumpgates[a.System].Jumpgate]
+		}
+		eta := fc.ETA
+		if fc.Complete {
+			eta = 0
+		}
+
+		m := derived[name]
 		rows = append(rows, AgentRow{
-			Symbol:        name,
-			Faction:       a.Faction,
-			Credits:       a.Credits,
-			Ships:         ships[name],
-			System:        a.System,
-			IsActive:      a.Credits != 175000,
-			FactionColor:  factionColor,
-			FactionName:   factionName,
-			Construction:  constructStr,
-			SystemCount:   systemCount[a.System],
-			MultiSystem:   systemCount[a.System] > 1,
-			IsChecked:     storageAgentsMap[name],
-			ShowConstruct: hasConstruct,
+			Symbol:               name,
+			Faction:              a.Faction,
+			Credits:              a.Credits,
+			Ships:                ships[name],
+			System:               a.System,
+			IsActive:             a.Credits != 175000,
+			FactionColor:         factionColor,
+			FactionName:          factionName,
+			Construction:         constructStr,
+			SystemCount:          systemCount[a.System],
+			MultiSystem:          systemCount[a.System] > 1,
+			IsChecked:            f.checked[name],
+			ShowConstruct:        hasConstruct,
+			CreditsPerHour:       m.CreditsPerHour,
+			RecentCreditsPerHour: m.RecentCreditsPerHour,
+			Acceleration:         m.Acceleration,
+			ShipsPerDay:          m.ShipsPerDay,
+			ProjectedCredits:     m.ProjectedCredits,
+			ConstructionETA:      eta,
+			ConstructionStalled:  fc.Stalled,
 		})
 	}
 
 	sort.Slice(rows, func(i, j int) bool {
-		if sortBy == "credits" {
-			if rows[i].Credits != rows[j].Credits {
-				return rows[i].Credits > rows[j].Credits
+		a, b := rows[i], rows[j]
+		switch f.sortBy {
+		case "credits":
+			if a.Credits != b.Credits {
+				return a.Credits > b.Credits
 			}
-			return rows[i].Symbol < rows[j].Symbol
-		}
-		if sortBy == "ships" {
-			if rows[i].Ships != rows[j].Ships {
-				return rows[i].Ships > rows[j].Ships
+		case "ships":
+			if a.Ships != b.Ships {
+				return a.Ships > b.Ships
+			}
+		case "rate":
+			if a.CreditsPerHour != b.CreditsPerHour {
+				return a.CreditsPerHour > b.CreditsPerHour
+			}
+		case "recent":
+			if a.RecentCreditsPerHour != b.RecentCreditsPerHour {
+				return a.RecentCreditsPerHour > b.RecentCreditsPerHour
+			}
+		case "acceleration":
+			if a.Acceleration != b.Acceleration {
+				return a.Acceleration > b.Acceleration
+			}
+		case "shipRate":
+			if a.ShipsPerDay != b.ShipsPerDay {
+				return a.ShipsPerDay > b.ShipsPerDay
+			}
+		case "projected":
+			if a.ProjectedCredits != b.ProjectedCredits {
+				return a.ProjectedCredits > b.ProjectedCredits
 			}
-			return rows[i].Symbol < rows[j].Symbol
 		}
-		return rows[i].Symbol < rows[j].Symbol
+		return a.Symbol < b.Symbol
 	})
 
 	aList = nil
@@ -563,13 +739,167 @@ func AgentsGridHandler(w http.ResponseWriter, r *http.Request) {
 	agentsLookup = nil
 	ships = nil
 	jumpgates = nil
-	construction = nil
 	constructMap = nil
+	derived = nil
+	forecasts = nil
+	return rows
+}
 
-	if err := t.ExecuteTemplate(w, "agents-grid.html", map[string]interface{}{
+func (srv *Server) AgentsGridHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	q := r.URL.Query()
+
+	checked := make(map[string]bool)
+	for _, i := range mergeAgents(q.Get("storageAgents"), q.Get("paramAgents")) {
+		checked[i] = true
+	}
+	rows := srv.agentRows(srv.requestReset(r), agentFilter{
+		search:           q.Get("agentSearch"),
+		faction:          q.Get("faction"),
+		system:           q.Get("system"),
+		sortBy:           q.Get("sortBy"),
+		hideInactive:     q.Get("hideInactive") == "on",
+		constructionOnly: q.Get("showConstruction") == "on",
+		checked:          checked,
+	})
+
+	if err := srv.t.ExecuteTemplate(w, "agents-grid.html", map[string]interface{}{
 		"Agents": rows,
 	}); err != nil {
 		logging.Error("template error", err)
 	}
 	metrics.RecordDuration("agents_grid", start)
 }
+
+// CompareHandler shows one agent over every reset we have data for. The
+// agent comes from the "agent" parameter, falling back to myAgent
+func (srv *Server) CompareHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	q := r.URL.Query()
+	agent := strings.ToUpper(strings.TrimSpace(q.Get("agent")))
+	if agent == "" {
+		agent = strings.ToUpper(strings.TrimSpace(q.Get("myAgent")))
+	}
+	logging.Info("Incoming request", "endpoint", "compare", "agent", agent)
+
+	pageData := map[string]interface{}{
+		"Agent": agent,
+	}
+	if agent != "" {
+		summaries := ds.AgentAcrossResets(srv.store, agent)
+		snippet := CompareChart(agent, summaries).RenderSnippet()
+		pageData["Resets"] = summaries
+		pageData["Chart"] = ChartSnippet{
+			Element: template.HTML(snippet.Element),
+			Script:  template.HTML(snippet.Script),
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	if err := srv.t.ExecuteTemplate(w, "compare.html", pageData); err != nil {
+		logging.Error("template error", err)
+	}
+	metrics.RecordDuration("compare", start)
+}
+
+type AgentPageData struct {
+	Symbol string
+	// Found is false when the agent is not in the reset
+	Found        bool
+	Agent        AgentRow
+	Headquarters string
+	Jumpgate     ds.JGInfo
+	HasJumpgate  bool
+	CreditsRank  int
+	ChartsRank   int
+	// CreditsPerHour is over the whole reset
+	CreditsPerHour float64
+	Days           []ds.AgentDay
+	// Neighbours are the other agents with headquarters in the same system
+	Neighbours        []AgentRow
+	CreditChart       ChartSnippet
+	ShipChart         ChartSnippet
+	ConstructionChart ChartSnippet
+	RankChart         ChartSnippet
+}
+
+// AgentHandler is one agent's page: where it is, its credits, ships,
+// jumpgate construction and leaderboard rank over the whole reset, and how
+// fast it gains credits and ships
+func (srv *Server) AgentHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	symbol := strings.ToUpper(strings.TrimSpace(r.PathValue("symbol")))
+	logging.Info("Incoming request", "endpoint", "agent", "agent", symbol)
+	if r.Header.Get("HX-Request") == "" {
+		srv.renderIndex(w, r, r.URL.RequestURI())
+		metrics.RecordDuration("agent", start)
+		return
+	}
+	thisReset := srv.requestReset(r)
+	pageData := AgentPageData{Symbol: symbol}
+
+	aList, _ := srv.store.GetAgentList(thisReset)
+	agentsLookup := agentsMap(aList)
+	agent, ok := agentsLookup[symbol]
+	if ok {
+		pageData.Found = true
+		pageData.Headquarters = agent.Headquarters
+		for _, row := range srv.agentRows(thisReset, agentFilter{system: agent.System}) {
+			if row.Symbol == symbol {
+				pageData.Agent = row
+			} else {
+				pageData.Neighbours = append(pageData.Neighbours, row)
+			}
+		}
+		jgList, _ := srv.store.GetJumpgateList(thisReset)
+		jgLookup := jumpgatesMap(jgList)
+		pageData.Jumpgate, pageData.HasJumpgate = jgLookup[agent.System]
+
+		creditLB, chartLB, _ := srv.store.GetLeaderboard(thisReset)
+		pageData.CreditsRank = ds.Rank(creditLB, symbol)
+		pageData.ChartsRank = ds.Rank(chartLB, symbol)
+
+		days, _ := ds.AgentDays(srv.store, thisReset, symbol)
+		pageData.Days = days
+		var gained int64
+		var hours float64
+		for _, d := range days {
+			gained += d.CreditsGained
+			hours += d.Hours
+		}
+		if hours > 0 {
+			pageData.CreditsPerHour = float64(gained) / hours
+		}
+
+		end := srv.resetEnd(thisReset)
+		resetStart := ds.ResetStart(thisReset)
+		if resetStart.IsZero() && len(days) > 0 {
+			resetStart = time.Unix(days[0].Day, 0)
+		}
+		duration := end.Sub(resetStart)
+		agentSeries, _, _ := ds.AgentSeries(srv.store, thisReset, []string{symbol}, resetStart.Unix(), end.Unix(), targetDataPoints)
+		pageData.CreditChart = chartSnippet(CreditChart([]string{symbol}, agentSeries, duration, end, "This reset"))
+		pageData.ShipChart = chartSnippet(ShipChart([]string{symbol}, agentSeries, duration, end, "This reset"))
+		agentSeries = nil
+
+		if pageData.HasJumpgate && pageData.Jumpgate.Status != ds.NoActivity {
+			constrSeries, _, _ := ds.ConstructionSeries(srv.store, thisReset, []string{pageData.Jumpgate.Jumpgate}, resetStart.Unix(), end.Unix(), targetDataPoints)
+			recs := constructionRecords(agentsLookup, jgLookup, constructionRows(constrSeries), []string{symbol})
+			pageData.ConstructionChart = chartSnippet(JumpgateConstructionChart(recs, duration, end))
+			constrSeries = nil
+			recs = nil
+		}
+
+		if points, _ := ds.RankHistory(srv.store, thisReset, []string{symbol}, 0, 0); len(points) > 0 {
+			pageData.RankChart = chartSnippet(AgentRankChart(thinPoints(points, targetDataPoints)))
+		}
+	}
+	aList = nil
+	agentsLookup = nil
+
+	w.Header().Set("Content-Type", "text/html")
+	if err := srv.t.ExecuteTemplate(w, "agent.html", pageData); err != nil {
+		logging.Error("template error", err)
+	}
+	metrics.RecordDuration("agent", start)
+}
@@ -0,0 +1,293 @@
+package frontend
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+)
+
+func testServer(t *testing.T) *Server {
+	store := ds.NewMemoryStore()
+	if err := store.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	var status ds.ResponseStatus
+	status.ResetDate = "2026-01-04"
+	status.Status = "ok"
+	status.Stats.Agents = 2
+	status.Leaderboards.MostCredits = append(status.Leaderboards.MostCredits, struct {
+		AgentSymbol string `json:"agentSymbol"`
+		Credits     int64  `json:"credits"`
+	}{AgentSymbol: "BRAVO", Credits: 250000})
+	if err := store.StoreStats(status, time.Now().Add(-time.Minute).Unix()); err != nil {
+		t.Fatalf("StoreStats: %v", err)
+	}
+	if err := store.StoreLeaderboards(status, time.Now().Add(-time.Minute).Unix()); err != nil {
+		t.Fatalf("StoreLeaderboards: %v", err)
+	}
+	err := store.StoreAgents([]ds.PublicAgent{
+		{Symbol: "ALPHA", Credits: 175000, Headquarters: "X1-AB12-A1", ShipCount: 2, StartingFaction: "COSMIC"},
+		{Symbol: "BRAVO", Credits: 250000, Headquarters: "X1-CD34-B2", ShipCount: 3, StartingFaction: "VOID"},
+	}, time.Now().Add(-time.Minute).Unix())
+	if err != nil {
+		t.Fatalf("StoreAgents: %v", err)
+	}
+
+	srv, err := NewServer(store, ".", ".")
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	return srv
+}
+
+func get(t *testing.T, srv *Server, url string) string {
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s: status %d", url, rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestLeaderboardHandler(t *testing.T) {
+	srv := testServer(t)
+	body := get(t, srv, "/leaderboard?type=credits&myAgent=BRAVO")
+	if !strings.Contains(body, "BRAVO") || !strings.Contains(body, "250000") {
+		t.Fatalf("leaderboard missing BRAVO: %s", body)
+	}
+	if !strings.Contains(body, "my-agent-highlight") {
+		t.Fatalf("expected my agent to be highlighted")
+	}
+	if !strings.Contains(body, "Rank over time") {
+		t.Fatalf("leaderboard has no rank chart")
+	}
+}
+
+func TestLeaderboardRows(t *testing.T) {
+	now := int64(1767312000)
+	history := []ds.LeaderboardSnapshot{
+		{Timestamp: now - 86400, CreditsList: []ds.LeaderboardEntry{{Symbol: "ALPHA"}, {Symbol: "BRAVO"}}},
+		{Timestamp: now - 3600, CreditsList: []ds.LeaderboardEntry{{Symbol: "BRAVO"}, {Symbol: "ALPHA"}}},
+		{Timestamp: now, CreditsList: []ds.LeaderboardEntry{{Symbol: "CHARLIE"}, {Symbol: "BRAVO"}, {Symbol: "ALPHA"}}},
+	}
+	rows := leaderboardRows(history[2].CreditsList, history, "credits")
+	want := []LeaderboardRow{
+		{Rank: 1, Symbol: "CHARLIE", Change1h: RankChange{Known: true, New: true}, Change24h: RankChange{Known: true, New: true}},
+		{Rank: 2, Symbol: "BRAVO", Change1h: RankChange{Places: -1, Known: true}, Change24h: RankChange{Known: true}},
+		{Rank: 3, Symbol: "ALPHA", Change1h: RankChange{Places: -1, Known: true}, Change24h: RankChange{Places: -2, Known: true}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("leaderboardRows = %+v\nwant %+v", rows, want)
+	}
+
+	rows = leaderboardRows(history[2].CreditsList, history[2:], "credits")
+	if rows[0].Change1h.Known || rows[0].Change24h.Known {
+		t.Fatalf("no earlier board but a change is known: %+v", rows[0])
+	}
+}
+
+func TestAgentHandler(t *testing.T) {
+	srv := testServer(t)
+	err := srv.store.StoreAgents([]ds.PublicAgent{
+		{Symbol: "ALPHA", Credits: 175000, Headquarters: "X1-AB12-A1", ShipCount: 2, StartingFaction: "COSMIC"},
+		{Symbol: "BRAVO", Credits: 250000, Headquarters: "X1-CD34-B2", ShipCount: 3, StartingFaction: "VOID"},
+		{Symbol: "CHARLIE", Credits: 180000, Headquarters: "X1-AB12-C3", ShipCount: 2, StartingFaction: "COSMIC"},
+	}, time.Now().Unix())
+	if err != nil {
+		t.Fatalf("StoreAgents: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/agent/alpha", nil)
+	req.Header.Set("HX-Request", "true")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "X1-AB12-A1") || !strings.Contains(body, "Cosmic Engineers") {
+		t.Fatalf("agent page missing ALPHA's details: %d %s", rec.Code, body)
+	}
+	if !strings.Contains(body, `hx-get="/agent/CHARLIE"`) || strings.Contains(body, `hx-get="/agent/BRAVO"`) {
+		t.Fatalf("expected only CHARLIE as a neighbour: %s", body)
+	}
+
+	// opened directly the page comes inside the dashboard
+	body = get(t, srv, "/agent/ALPHA?reset=2026-01-04")
+	if !strings.Contains(body, `hx-get="/agent/ALPHA?reset=2026-01-04"`) || !strings.Contains(body, "<html") {
+		t.Fatalf("expected the dashboard loading the agent page: %s", body)
+	}
+}
+
+func TestAgentsGridHandler(t *testing.T) {
+	srv := testServer(t)
+	body := get(t, srv, "/agents-grid?hideInactive=on")
+	if !strings.Contains(body, "BRAVO") {
+		t.Fatalf("agents grid missing BRAVO: %s", body)
+	}
+	if strings.Contains(body, "btn-ALPHA") {
+		t.Fatalf("inactive agent ALPHA should be hidden")
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	srv := testServer(t)
+	body := get(t, srv, "/stats")
+	if !strings.Contains(body, "2026-01-04") {
+		t.Fatalf("stats missing reset date: %s", body)
+	}
+	if !strings.Contains(body, "New per hour") {
+		t.Fatalf("stats page has no growth charts")
+	}
+}
+
+func TestResetParameter(t *testing.T) {
+	store := ds.NewMemoryStore()
+	for i, r := range []string{"2025-12-28", "2026-01-04"} {
+		if err := store.UpdateReset(ds.Reset(r)); err != nil {
+			t.Fatalf("UpdateReset: %v", err)
+		}
+		var status ds.ResponseStatus
+		status.ResetDate = r
+		status.Version = fmt.Sprintf("v%d", i+1)
+		if err := store.StoreStats(status, time.Now().Add(-time.Minute).Unix()); err != nil {
+			t.Fatalf("StoreStats: %v", err)
+		}
+		err := store.StoreAgents([]ds.PublicAgent{
+			{Symbol: "ALPHA", Credits: int64(200000 * (i + 1)), Headquarters: "X1-AB12-A1", ShipCount: 2},
+		}, time.Now().Add(-time.Minute).Unix())
+		if err != nil {
+			t.Fatalf("StoreAgents: %v", err)
+		}
+	}
+	srv, err := NewServer(store, ".", ".")
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	if body := get(t, srv, "/stats"); !strings.Contains(body, "v2") || !strings.Contains(body, "Agents by day of reset") {
+		t.Fatalf("expected newest reset by default and every reset compared: %s", body)
+	}
+	if body := get(t, srv, "/stats?reset=2025-12-28"); !strings.Contains(body, "v1") {
+		t.Fatalf("expected older reset: %s", body)
+	}
+	if body := get(t, srv, "/stats?reset=../../etc"); !strings.Contains(body, "v2") {
+		t.Fatalf("unknown reset should fall back to the newest: %s", body)
+	}
+
+	body := get(t, srv, "/compare?agent=alpha")
+	if !strings.Contains(body, "2025-12-28") || !strings.Contains(body, "200000") || !strings.Contains(body, "400000") {
+		t.Fatalf("compare view missing a reset: %s", body)
+	}
+}
+
+func TestNoReset(t *testing.T) {
+	store := ds.NewMemoryStore()
+	srv, err := NewServer(store, ".", ".")
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	// before the collector finds the reset pages are unavailable, not stuck
+	for _, url := range []string{"/", "/stats", "/agent/ALPHA", "/api/v1/stats", "/export?format=csv&type=agents"} {
+		rec := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("GET %s: still waiting for a reset", url)
+		}
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("GET %s: expected 503, got %d", url, rec.Code)
+		}
+	}
+	if body := get(t, srv, "/api/v1/resets"); !strings.Contains(body, "[]") {
+		t.Fatalf("expected no resets: %s", body)
+	}
+
+	if err := store.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	get(t, srv, "/stats")
+}
+
+func TestConstructionMaterials(t *testing.T) {
+	srv := testServer(t)
+	err := srv.store.UpdateJumpGates([]ds.JGInfo{
+		{Jumpgate: "X1-CD34-I1", System: "X1-CD34", Headquarters: "X1-CD34-B2", Status: ds.Const},
+	})
+	if err != nil {
+		t.Fatalf("UpdateJumpGates: %v", err)
+	}
+	now := time.Now().Add(-time.Minute).Unix()
+	err = srv.store.AddConstructions([]ds.JGConstruction{{Timestamp: now, Jumpgate: "X1-CD34-I1", Materials: ds.Materials{
+		{Symbol: "FAB_MATS", Required: 2000, Fulfilled: 500},
+		{Symbol: "QUANTUM_STABILIZERS", Required: 10, Fulfilled: 3},
+	}}}, now)
+	if err != nil {
+		t.Fatalf("AddConstructions: %v", err)
+	}
+
+	// the recipe comes from the records, not from what gates used to need
+	body := get(t, srv, "/agents-grid")
+	if !strings.Contains(body, "500/2000 FB, 3/10 QUANTUM_STABILIZERS") {
+		t.Fatalf("agents grid missing construction progress: %s", body)
+	}
+	body = get(t, srv, "/chart?paramAgents=BRAVO")
+	if !strings.Contains(body, "<th>QUANTUM_STABILIZERS</th>") || strings.Contains(body, "Adv Circuitry") {
+		t.Fatalf("construction table columns not taken from the data: %s", body)
+	}
+	body = get(t, srv, "/jumpgates")
+	if !strings.Contains(body, `"name":"QUANTUM_STABILIZERS"`) || !strings.Contains(body, `"max":2000`) {
+		t.Fatalf("parallel chart axes not taken from the data: %s", body)
+	}
+}
+
+func TestConstructionForecast(t *testing.T) {
+	srv := testServer(t)
+	err := srv.store.UpdateJumpGates([]ds.JGInfo{
+		{Jumpgate: "X1-CD34-I1", System: "X1-CD34", Headquarters: "X1-CD34-B2", Status: ds.Const},
+	})
+	if err != nil {
+		t.Fatalf("UpdateJumpGates: %v", err)
+	}
+	// 100 an hour for two hours, then nothing for three
+	now := time.Now().Unix()
+	for i, fulfilled := range []int{0, 100, 200, 200, 200, 200} {
+		ts := now - int64(5-i)*3600 - 60
+		err := srv.store.AddConstructions([]ds.JGConstruction{{Timestamp: ts, Jumpgate: "X1-CD34-I1", Materials: ds.Materials{
+			{Symbol: "FAB_MATS", Required: 1000, Fulfilled: fulfilled},
+		}}}, ts)
+		if err != nil {
+			t.Fatalf("AddConstructions: %v", err)
+		}
+	}
+
+	body := get(t, srv, "/jumpgates")
+	if !strings.Contains(body, "200/1000 (20%)") || !strings.Contains(body, "<td>40.0</td>") {
+		t.Fatalf("jumpgates page missing the forecast: %s", body)
+	}
+	if strings.Contains(body, `class="construction-stalled"`) {
+		t.Fatalf("stalled inside the default window: %s", body)
+	}
+	if body := get(t, srv, "/agents-grid"); !strings.Contains(body, "construction-eta") {
+		t.Fatalf("agents grid missing the ETA: %s", body)
+	}
+
+	srv.stallWindow = 2 * time.Hour
+	if body := get(t, srv, "/jumpgates"); !strings.Contains(body, `class="construction-stalled"`) {
+		t.Fatalf("expected the gate to be stalled: %s", body)
+	}
+	var rows []AgentRow
+	getAPI(t, srv, "/api/v1/agents?agents=BRAVO", http.StatusOK, &rows)
+	if len(rows) != 1 || !rows[0].ConstructionStalled || rows[0].ConstructionETA == 0 {
+		t.Fatalf("agents API missing the forecast: %+v", rows)
+	}
+}
@@ -0,0 +1,112 @@
+package frontend
+
+import (
+	"net/http"
+	"time"
+
+	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+	"github.com/papaburgs/fluffy-robot/internal/metrics"
+)
+
+// Health is the body of /healthz and /readyz
+type Health struct {
+	// Status is ok, or the first problem found
+	Status   string   `json:"status"`
+	Problems []string `json:"problems"`
+	// Reset is empty until the collector has found the current reset
+	Reset string `json:"reset"`
+	// LastUpdate is the unix time of the newest collected data, 0 before
+	// the first ingestion. Data counts as stale StaleAfterSeconds after it,
+	// or after startup when there is none yet
+	LastUpdate        int64     `json:"lastUpdate"`
+	StaleSeconds      int64     `json:"staleSeconds"`
+	StaleAfterSeconds int64     `json:"staleAfterSeconds"`
+	Agents            Ingestion `json:"agents"`
+	Jumpgates         Ingestion `json:"jumpgates"`
+	// StorageWritable is true for stores that do not write to disk
+	StorageWritable bool   `json:"storageWritable"`
+	StorageError    string `json:"storageError,omitempty"`
+	// GateQueue is how many API calls are waiting on the rate limiter
+	GateQueue int64 `json:"gateQueue"`
+}
+
+// Ingestion is how the last runs of one kind of update went, as unix times
+type Ingestion struct {
+	// OK is false when the most recent run failed
+	OK          bool  `json:"ok"`
+	LastSuccess int64 `json:"lastSuccess"`
+	LastFailure int64 `json:"lastFailure"`
+}
+
+func ingestion(success, failure int64) Ingestion {
+	return Ingestion{
+		OK:          failure == 0 || success >= failure,
+		LastSuccess: success,
+		LastFailure: failure,
+	}
+}
+
+// health gathers the checks. Stale data fails both endpoints, since a
+// restart may help; an unknown reset or unwritable storage only readiness
+func (srv *Server) health(now time.Time, checkStorage bool) (h Health, live, ready bool) {
+	h = Health{
+		Problems:          []string{},
+		Reset:             string(srv.store.CurrentReset()),
+		LastUpdate:        metrics.CollectorLastTimestamp.Value(),
+		StaleAfterSeconds: int64(srv.staleAfter.Seconds()),
+		Agents:            ingestion(metrics.CollectorAgentLastSuccess.Value(), metrics.CollectorAgentLastFailure.Value()),
+		Jumpgates:         ingestion(metrics.CollectorJumpgateLastSuccess.Value(), metrics.CollectorJumpgateLastFailure.Value()),
+		StorageWritable:   true,
+		GateQueue:         metrics.GateQueueLength.Value(),
+	}
+	since := srv.started
+	if h.LastUpdate > 0 {
+		since = time.Unix(h.LastUpdate, 0)
+	}
+	h.StaleSeconds = max(int64(now.Sub(since).Seconds()), 0)
+
+	live = now.Sub(since) <= srv.staleAfter
+	if !live {
+		h.Problems = append(h.Problems, "stale")
+	}
+	ready = live
+	if h.Reset == "" {
+		h.Problems = append(h.Problems, "reset unknown")
+		ready = false
+	}
+	if wc, ok := srv.store.(ds.WriteChecker); ok && checkStorage {
+		if err := wc.CheckWritable(); err != nil {
+			h.StorageWritable = false
+			h.StorageError = err.Error()
+			h.Problems = append(h.Problems, "storage not writable")
+			ready = false
+		}
+	}
+	h.Status = "ok"
+	if len(h.Problems) > 0 {
+		h.Status = h.Problems[0]
+	}
+	return h, live, ready
+}
+
+// HealthzHandler is the liveness check, 503 once the collected data is
+// older than the stale threshold
+func (srv *Server) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	h, live, _ := srv.health(time.Now(), false)
+	status := http.StatusOK
+	if !live {
+		status = http.StatusServiceUnavailable
+	}
+	writeAPI(w, status, h)
+}
+
+// ReadyzHandler is the readiness check, 503 while the reset is unknown, the
+// storage cannot be written or the data is stale
+func (srv *Server) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	h, _, ready := srv.health(time.Now(), true)
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeAPI(w, status, h)
+}
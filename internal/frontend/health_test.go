@@ -0,0 +1,126 @@
+package frontend
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+	"github.com/papaburgs/fluffy-robot/internal/metrics"
+)
+
+func getHealth(t *testing.T, srv *Server, path string) (int, Health) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var h Health
+	if err := json.NewDecoder(rec.Body).Decode(&h); err != nil {
+		t.Fatalf("%s: decoding: %v", path, err)
+	}
+	return rec.Code, h
+}
+
+func TestHealthHandlers(t *testing.T) {
+	now := time.Now().Unix()
+	metrics.CollectorLastTimestamp.Set(now - 60)
+	metrics.CollectorAgentLastSuccess.Set(now - 60)
+	metrics.CollectorAgentLastFailure.Set(now - 600)
+	metrics.CollectorJumpgateLastSuccess.Set(now - 1800)
+	metrics.CollectorJumpgateLastFailure.Set(now - 120)
+	metrics.GateQueueLength.Set(3)
+	t.Cleanup(func() {
+		for _, v := range []interface{ Set(int64) }{
+			metrics.CollectorLastTimestamp, metrics.CollectorAgentLastSuccess, metrics.CollectorAgentLastFailure,
+			metrics.CollectorJumpgateLastSuccess, metrics.CollectorJumpgateLastFailure, metrics.GateQueueLength,
+		} {
+			v.Set(0)
+		}
+	})
+
+	srv := testServer(t)
+	for _, path := range []string{"/healthz", "/readyz"} {
+		code, h := getHealth(t, srv, path)
+		if code != http.StatusOK || h.Status != "ok" || len(h.Problems) != 0 {
+			t.Fatalf("%s: expected ok, got %d %+v", path, code, h)
+		}
+		if h.Reset != "2026-01-04" || h.StaleSeconds < 60 || h.StaleAfterSeconds != 3600 || h.GateQueue != 3 {
+			t.Fatalf("%s: unexpected report %+v", path, h)
+		}
+		if !h.Agents.OK || h.Jumpgates.OK || h.Jumpgates.LastFailure != now-120 {
+			t.Fatalf("%s: unexpected ingestion %+v %+v", path, h.Agents, h.Jumpgates)
+		}
+	}
+
+	// stale data fails both
+	srv.staleAfter = 30 * time.Second
+	for _, path := range []string{"/healthz", "/readyz"} {
+		if code, h := getHealth(t, srv, path); code != http.StatusServiceUnavailable || h.Status != "stale" {
+			t.Fatalf("%s: expected stale, got %d %+v", path, code, h)
+		}
+	}
+
+	// before any data the time since startup counts
+	metrics.CollectorLastTimestamp.Set(0)
+	srv.started = time.Now()
+	if code, h := getHealth(t, srv, "/healthz"); code != http.StatusOK || h.LastUpdate != 0 {
+		t.Fatalf("expected a fresh start to be healthy, got %d %+v", code, h)
+	}
+	srv.started = time.Now().Add(-time.Minute)
+	if code, _ := getHealth(t, srv, "/healthz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected no data for a minute to be stale, got %d", code)
+	}
+}
+
+func TestReadyz_NotReady(t *testing.T) {
+	// no reset yet, and nowhere to write
+	dir := filepath.Join(t.TempDir(), "data")
+	store, err := ds.NewDiskStore(dir, false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	srv, err := NewServer(store, ".", ".")
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if code, h := getHealth(t, srv, "/readyz"); code != http.StatusServiceUnavailable || h.Status != "reset unknown" || !h.StorageWritable {
+		t.Fatalf("expected reset unknown, got %d %+v", code, h)
+	}
+	// liveness does not care
+	if code, _ := getHealth(t, srv, "/healthz"); code != http.StatusOK {
+		t.Fatalf("expected /healthz to pass, got %d", code)
+	}
+
+	if err := store.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	if code, h := getHealth(t, srv, "/readyz"); code != http.StatusOK {
+		t.Fatalf("expected ready, got %d %+v", code, h)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("RemoveAll: %v", err)
+	}
+	code, h := getHealth(t, srv, "/readyz")
+	if code != http.StatusServiceUnavailable || h.StorageWritable || h.StorageError == "" || h.Status != "storage not writable" {
+		t.Fatalf("expected storage not writable, got %d %+v", code, h)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	srv := testServer(t)
+	get(t, srv, "/agent/ALPHA")
+
+	body := get(t, srv, "/metrics")
+	for _, want := range []string{
+		`fluffy_http_request_duration_seconds_count{code="200",endpoint="GET /agent/{symbol}"}`,
+		"fluffy_gate_queue_length",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package frontend
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+	"github.com/papaburgs/fluffy-robot/internal/logging"
+	"github.com/papaburgs/fluffy-robot/internal/metrics"
+)
+
+// maxImportSize is the largest archive ImportHandler accepts
+const maxImportSize = 1 << 30
+
+// ImportHandler loads an archive from /export, sent as the request body,
+// into the store and replies with the ds.ImportReport. It needs the token
+// set with FLUFFY_IMPORT_TOKEN as a bearer token and is not served at all
+// without one. With dryRun=true it reports what would change and writes
+// nothing
+func (srv *Server) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	if srv.importToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(srv.importToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		apiFail(w, http.StatusUnauthorized, errors.New("a valid import token is required"))
+		return
+	}
+	importer, ok := srv.store.(ds.Importer)
+	if !ok {
+		apiFail(w, http.StatusNotImplemented, errors.New("import is not supported by this datastore"))
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	logging.Info("Import Handler called, dry run", dryRun)
+
+	report, err := importer.Import(http.MaxBytesReader(w, r.Body, maxImportSize), dryRun)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		apiFail(w, http.StatusRequestEntityTooLarge, err)
+		return
+	case errors.Is(err, ds.ErrInvalidArchive):
+		// the report says which files are broken
+		writeAPI(w, http.StatusUnprocessableEntity, report)
+		return
+	case err != nil:
+		logging.Error("import:", err)
+		apiFail(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !dryRun {
+		srv.refreshResets()
+	}
+	writeAPI(w, http.StatusOK, report)
+	metrics.RecordDuration("import", start)
+}
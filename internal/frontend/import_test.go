@@ -0,0 +1,83 @@
+package frontend
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ds "github.com/papaburgs/fluffy-robot/internal/datastore"
+)
+
+func postImport(t *testing.T, srv *Server, url, token string, body []byte, want int) ds.ImportReport {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != want {
+		t.Fatalf("POST %s: status %d, want %d: %s", url, rec.Code, want, rec.Body.String())
+	}
+	var report ds.ImportReport
+	if want == http.StatusOK || want == http.StatusUnprocessableEntity {
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("decoding report: %v", err)
+		}
+	}
+	return report
+}
+
+func TestImportHandler(t *testing.T) {
+	src, err := ds.NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	if err := src.UpdateReset("2026-01-04"); err != nil {
+		t.Fatalf("UpdateReset: %v", err)
+	}
+	agents := []ds.PublicAgent{{Symbol: "ALPHA", Credits: 175000, Headquarters: "X1-AB12-A1", ShipCount: 2}}
+	if err := src.StoreAgents(agents, time.Now().Add(-time.Minute).Unix()); err != nil {
+		t.Fatalf("StoreAgents: %v", err)
+	}
+	srcSrv, err := NewServer(src, ".", ".")
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	archive := export(t, srcSrv, "/export").Body.Bytes()
+
+	dst, err := ds.NewDiskStore(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	srv, err := NewServer(dst, ".", ".")
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	postImport(t, srv, "/import", "secret", archive, http.StatusNotFound)
+
+	srv.importToken = "secret"
+	postImport(t, srv, "/import", "", archive, http.StatusUnauthorized)
+	postImport(t, srv, "/import", "guess", archive, http.StatusUnauthorized)
+
+	report := postImport(t, srv, "/import?dryRun=true", "secret", archive, http.StatusOK)
+	if len(report.Imported) == 0 || len(dst.AllResets()) != 0 {
+		t.Fatalf("dry run imported nothing or wrote: %+v", report)
+	}
+	report = postImport(t, srv, "/import", "secret", archive, http.StatusOK)
+	if len(report.Imported) == 0 || len(report.Conflicts) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if srv.newestReset() != "2026-01-04" {
+		t.Fatalf("the imported reset is not served: %v", srv.resets)
+	}
+	hist, err := dst.GetAgentHistory("2026-01-04", 0, 0)
+	if err != nil || len(hist) != 1 {
+		t.Fatalf("history was not imported: %v %v", hist, err)
+	}
+
+	postImport(t, srv, "/import", "secret", []byte("not an archive"), http.StatusUnprocessableEntity)
+}
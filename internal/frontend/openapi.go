@@ -0,0 +1,207 @@
+package frontend
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/papaburgs/fluffy-robot/internal/logging"
+)
+
+// The OpenAPI document is built from apiRoutes and the Go types they return,
+// so a field added to AgentRow or ds.Stats shows up in it without anyone
+// having to remember. tools/apigen generates the client package from it.
+
+type apiParam struct {
+	description string
+	// schema is the parameter's OpenAPI schema
+	schema map[string]any
+	// csv marks a comma separated list
+	csv bool
+}
+
+var (
+	stringSchema  = map[string]any{"type": "string"}
+	integerSchema = map[string]any{"type": "integer"}
+	booleanSchema = map[string]any{"type": "boolean"}
+	listSchema    = map[string]any{"type": "array", "items": stringSchema}
+	timeSchema    = map[string]any{"type": "integer", "format": "int64"}
+)
+
+func enumSchema(values ...string) map[string]any {
+	return map[string]any{"type": "string", "enum": values}
+}
+
+var apiParams = map[string]apiParam{
+	"reset":        {"Reset date such as 2026-01-04, the newest by default", stringSchema, false},
+	"limit":        {"Page size, 100 by default and at most 1000", integerSchema, false},
+	"offset":       {"Items to skip", integerSchema, false},
+	"agents":       {"Only these agents", listSchema, true},
+	"jumpgates":    {"Only these jumpgates", listSchema, true},
+	"start":        {"Unix seconds, RFC 3339 is accepted too", timeSchema, false},
+	"end":          {"Unix seconds, RFC 3339 is accepted too. The end of the reset by default", timeSchema, false},
+	"tier":         {"Resolution, raw 5 minute records by default", enumSchema("5m", "1h", "1d"), false},
+	"type":         {"Which leaderboard, credits by default", enumSchema("credits", "charts"), false},
+	"search":       {"Part of the agent symbol, any case", stringSchema, false},
+	"faction":      {"Starting faction", stringSchema, false},
+	"system":       {"Headquarters system", stringSchema, false},
+	"active":       {"Only agents whose credits changed from the starting amount", booleanSchema, false},
+	"construction": {"Only agents whose jumpgate is being built", booleanSchema, false},
+	"sort":         {"Order, by symbol by default", enumSchema("credits", "ships", "rate", "recent", "acceleration", "shipRate", "projected"), false},
+	"status":       {"Only jumpgates with this status", enumSchema("NoActivity", "Active", "Const", "Complete"), false},
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaBuilder turns Go types into OpenAPI schemas the way encoding/json
+// would encode them. Named structs become components referenced by name
+type schemaBuilder struct {
+	components map[string]any
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.schema(t.Elem())
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		if _, ok := b.components[t.Name()]; !ok {
+			// placeholder first so self references end
+			b.components[t.Name()] = nil
+			b.components[t.Name()] = b.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Uint:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	return map[string]any{}
+}
+
+func (b *schemaBuilder) object(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+		if tag[0] != "" {
+			name = tag[0]
+		}
+		props[name] = b.schema(f.Type)
+		if len(tag) < 2 || tag[1] != "omitempty" {
+			required = append(required, name)
+		}
+	}
+	return map[string]any{"type": "object", "properties": props, "required": required}
+}
+
+func paramSpec(name string) map[string]any {
+	p := apiParams[name]
+	res := map[string]any{"name": name, "in": "query", "description": p.description, "schema": p.schema}
+	if p.csv {
+		res["style"] = "form"
+		res["explode"] = false
+	}
+	return res
+}
+
+// buildOpenAPI returns the OpenAPI 3 document for apiRoutes
+func buildOpenAPI() map[string]any {
+	b := &schemaBuilder{components: make(map[string]any)}
+	metaRef := b.schema(reflect.TypeOf(APIMeta{}))
+	errorRef := b.schema(reflect.TypeOf(APIError{}))
+	errorResponse := func(description string) map[string]any {
+		return map[string]any{
+			"description": description,
+			"content":     map[string]any{"application/json": map[string]any{"schema": errorRef}},
+		}
+	}
+
+	paths := make(map[string]any)
+	for _, route := range apiRoutes {
+		params := []any{}
+		if route.path != "/api/v1/resets" {
+			params = append(params, paramSpec("reset"))
+		}
+		for _, name := range route.params {
+			params = append(params, paramSpec(name))
+		}
+		if route.paged {
+			params = append(params, paramSpec("limit"), paramSpec("offset"))
+		}
+		body := map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"data": b.schema(reflect.TypeOf(route.data)), "meta": metaRef},
+			"required":   []string{"data"},
+		}
+		paths[route.path] = map[string]any{"get": map[string]any{
+			"operationId": route.id,
+			"summary":     route.summary,
+			"parameters":  params,
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": route.summary,
+					"content":     map[string]any{"application/json": map[string]any{"schema": body}},
+				},
+				"400": errorResponse("Invalid parameters"),
+				"404": errorResponse("Unknown reset"),
+				"503": errorResponse("No reset collected yet"),
+			},
+		}}
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Fluffy Robot",
+			"description": "Public SpaceTraders leaderboards, agents and jumpgate construction as collected by the dashboard",
+			"version":     "1",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": b.components},
+	}
+}
+
+// OpenAPISpec is the indented JSON OpenAPI document for the /api/v1 routes
+var OpenAPISpec = sync.OnceValues(func() ([]byte, error) {
+	return json.MarshalIndent(buildOpenAPI(), "", "  ")
+})
+
+func (srv *Server) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	spec, err := OpenAPISpec()
+	if err != nil {
+		logging.Error("openapi:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
@@ -3,7 +3,6 @@ package gate
 import (
 	"container/list"
 	"context"
-	"fmt"
 	"sync"
 	"time"
 
@@ -21,6 +20,8 @@ type Gate struct {
 	t60Count   int
 	queue      *list.List
 	queueMutex sync.Mutex
+	done       chan struct{}
+	stopOnce   sync.Once
 }
 
 func New(t1Limit, t60Limit int) *Gate {
@@ -34,16 +35,30 @@ func New(t1Limit, t60Limit int) *Gate {
 		t60Count:   0,
 		queue:      &list.List{},
 		queueMutex: sync.Mutex{},
+		done:       make(chan struct{}),
 	}
 	g.queue.Init()
 	go g.loop()
 	return &g
 }
 
+// Stop ends the gate's loop and tickers. Anyone waiting in Latch, or
+// calling it later, is let through at once
+func (g *Gate) Stop() {
+	g.stopOnce.Do(func() {
+		close(g.done)
+	})
+}
+
 func (g *Gate) loop() {
+	defer g.t1Ticker.Stop()
+	defer g.t60Ticker.Stop()
+	defer g.tCheck.Stop()
 	ratelimit := 1
 	for {
 		select {
+		case <-g.done:
+			return
 		case <-g.t1Ticker.C:
 			g.queueMutex.Lock()
 			g.t1Count = 0
@@ -65,6 +80,7 @@ func (g *Gate) loop() {
 						metrics.GateT1Requests.Add(1)
 						c <- true
 						g.queue.Remove(node)
+						metrics.GateQueueLength.Set(int64(g.queue.Len()))
 					case g.t60Count < g.t60Limit:
 						if g.t60Count == 0 {
 							g.t60Ticker.Reset(time.Minute)
@@ -73,6 +89,7 @@ func (g *Gate) loop() {
 						metrics.GateT60Requests.Add(1)
 						c <- true
 						g.queue.Remove(node)
+						metrics.GateQueueLength.Set(int64(g.queue.Len()))
 					default:
 						ratelimit++
 						metrics.GateBlocked.Add(1)
@@ -90,16 +107,23 @@ func (g *Gate) loop() {
 
 func (g *Gate) Latch(ctx context.Context) {
 	g.queueMutex.Lock()
-	c := make(chan bool)
-	g.queue.PushBack(c)
-	g.queueMutex.Unlock()
+	// buffered so the loop never waits on someone who has given up
+	c := make(chan bool, 1)
+	node := g.queue.PushBack(c)
 	metrics.GateQueueLength.Set(int64(g.queue.Len()))
+	g.queueMutex.Unlock()
 	select {
 	case <-c:
 		return
+	case <-g.done:
+		return
 	case <-ctx.Done():
-		fmt.Println("level=warn context cancelled")
+		logging.Warn("context cancelled")
 	}
+	g.queueMutex.Lock()
+	g.queue.Remove(node)
+	metrics.GateQueueLength.Set(int64(g.queue.Len()))
+	g.queueMutex.Unlock()
 }
 
 func (g *Gate) Lock(ctx context.Context) {
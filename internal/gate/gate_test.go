@@ -47,6 +47,45 @@ func TestGate_Latch_ContextCancel(t *testing.T) {
 	}
 }
 
+// TestGate_Latch_CancelledLeavesQueue checks someone who gave up does not
+// hold up the ones behind them
+func TestGate_Latch_CancelledLeavesQueue(t *testing.T) {
+	g := New(1, 0)
+	defer g.Stop()
+	g.Lock(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	g.Latch(ctx)
+	g.queueMutex.Lock()
+	n := g.queue.Len()
+	g.queueMutex.Unlock()
+	if n != 0 {
+		t.Fatalf("expected the cancelled latch to leave the queue, %d waiting", n)
+	}
+}
+
+func TestGate_Stop(t *testing.T) {
+	g := New(1, 0)
+	g.Lock(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		g.Latch(context.Background())
+		close(done)
+	}()
+	g.Stop()
+	g.Stop()
+
+	select {
+	case <-done:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Latch was not released by Stop")
+	}
+	// and does not wait once stopped
+	g.Latch(context.Background())
+}
+
 // TestGateInitialBlast does 20, they should all finish in less than a second
 func TestGateInitialBlast(t *testing.T) {
 	g := New(2, 20)
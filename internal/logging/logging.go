@@ -2,7 +2,6 @@ package logging
 
 import (
 	"fmt"
-	"os"
 	"strings"
 	"sync"
 )
@@ -12,12 +11,11 @@ var (
 	debugMode bool
 )
 
-func InitLogger() {
-	if logl, ok := os.LookupEnv("FLUFFY_LOG_LEVEL"); ok {
-		switch strings.ToLower(logl) {
-		case "debug", "dbg":
-			debugMode = true
-		}
+// InitLogger turns on debug output when level is debug or dbg
+func InitLogger(level string) {
+	switch strings.ToLower(level) {
+	case "debug", "dbg":
+		SetDebug(true)
 	}
 }
 
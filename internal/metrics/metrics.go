@@ -14,6 +14,14 @@ var (
 	CollectorConstructionChecks = expvar.NewInt("collector_construction_checks_total")
 	CollectorResetDetections    = expvar.NewInt("collector_reset_detections_total")
 	CollectorLastTimestamp      = expvar.NewInt("collector_last_update_timestamp")
+	// unix times of the last agent and jumpgate ingestions that worked and
+	// that failed
+	CollectorAgentLastSuccess    = expvar.NewInt("collector_agent_last_success_timestamp")
+	CollectorAgentLastFailure    = expvar.NewInt("collector_agent_last_failure_timestamp")
+	CollectorJumpgateLastSuccess = expvar.NewInt("collector_jumpgate_last_success_timestamp")
+	CollectorJumpgateLastFailure = expvar.NewInt("collector_jumpgate_last_failure_timestamp")
+	// unix time of the next reset as announced by the server
+	CollectorNextReset = expvar.NewInt("collector_next_reset_timestamp")
 
 	GateQueueLength = expvar.NewInt("gate_queue_length")
 	GateT1Requests  = expvar.NewInt("gate_requests_t1_total")
@@ -21,9 +29,42 @@ var (
 	GateBlocked     = expvar.NewInt("gate_blocked_total")
 	GateLockCount   = expvar.NewInt("gate_lock_count")
 
-	DatastoreWrites      = expvar.NewInt("datastore_write_operations_total")
-	DatastoreReads       = expvar.NewInt("datastore_read_operations_total")
-	DatastoreCacheResets = expvar.NewInt("datastore_cache_resets_total")
+	DatastoreWrites = expvar.NewInt("datastore_write_operations_total")
+	DatastoreReads  = expvar.NewInt("datastore_read_operations_total")
+	// bytes of files and database rows, as stored on disk
+	DatastoreBytesWritten   = expvar.NewInt("datastore_written_bytes_total")
+	DatastoreBytesRead      = expvar.NewInt("datastore_read_bytes_total")
+	DatastoreCacheResets    = expvar.NewInt("datastore_cache_resets_total")
+	DatastoreCacheHits      = expvar.NewInt("datastore_cache_hits_total")
+	DatastoreCacheMisses    = expvar.NewInt("datastore_cache_misses_total")
+	DatastoreCacheEvictions = expvar.NewInt("datastore_cache_evictions_total")
+	DatastoreCacheBytes     = expvar.NewInt("datastore_cache_bytes")
+
+	DatastoreCompactionRuns        = expvar.NewInt("datastore_compaction_runs_total")
+	DatastoreCompactionFilesMerged = expvar.NewInt("datastore_compaction_files_merged_total")
+	DatastoreCompactionBytesSaved  = expvar.NewInt("datastore_compaction_bytes_saved_total")
+	DatastoreCompactionErrors      = expvar.NewInt("datastore_compaction_errors_total")
+
+	DatastoreRetentionRuns          = expvar.NewInt("datastore_retention_runs_total")
+	DatastoreRetentionErrors        = expvar.NewInt("datastore_retention_errors_total")
+	DatastoreRetentionResetsTrimmed = expvar.NewInt("datastore_retention_resets_trimmed_total")
+	DatastoreRetentionResetsDeleted = expvar.NewInt("datastore_retention_resets_deleted_total")
+	DatastoreRetentionBytesRemoved  = expvar.NewInt("datastore_retention_bytes_removed_total")
+	// set by dry runs: what a real run would remove
+	DatastoreRetentionPendingResets = expvar.NewInt("datastore_retention_pending_resets")
+	DatastoreRetentionPendingBytes  = expvar.NewInt("datastore_retention_pending_bytes")
+
+	DatastoreQuarantinedFiles = expvar.NewInt("datastore_quarantined_files_total")
+
+	EventsPublished = expvar.NewInt("events_published_total")
+	// events a slow subscriber missed
+	EventsDropped        = expvar.NewInt("events_dropped_total")
+	FrontendEventStreams = expvar.NewInt("frontend_event_streams")
+
+	AlertsFired = expvar.NewInt("alerts_fired_total")
+	// alerts held back by their rule's cooldown
+	AlertsSuppressed     = expvar.NewInt("alerts_suppressed_total")
+	AlertWebhookFailures = expvar.NewInt("alerts_webhook_failures_total")
 )
 
 func getOrCreateMap(name string) *expvar.Map {
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"expvar"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric on /metrics
+const namespace = "fluffy"
+
+var (
+	// HTTPRequestDuration is handler latency by mux pattern and status code
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time to serve a request, by route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint", "code"})
+
+	// APIRequestDuration is SpaceTraders call latency by route template and
+	// status code, "error" when there was no response
+	APIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "collector_api_request_duration_seconds",
+		Help:      "Time for one SpaceTraders API call, by route and status code.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"route", "code"})
+
+	resetCountdown = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "reset_countdown_seconds",
+		Help:      "Seconds until the next reset, 0 when it is unknown or due.",
+	}, func() float64 {
+		next := CollectorNextReset.Value()
+		if next == 0 {
+			return 0
+		}
+		return max(time.Until(time.Unix(next, 0)).Seconds(), 0)
+	})
+
+	// Registry is everything /metrics shows
+	Registry = prometheus.NewRegistry()
+)
+
+func init() {
+	Registry.MustRegister(
+		HTTPRequestDuration,
+		APIRequestDuration,
+		resetCountdown,
+		expvarCollector{},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves Registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// expvarCollector shows every expvar.Int, so the counters above need no
+// second definition. Names ending in _total are counters, the rest gauges.
+// It describes nothing up front since the set of expvars is only known at
+// scrape time
+type expvarCollector struct{}
+
+func (expvarCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (expvarCollector) Collect(ch chan<- prometheus.Metric) {
+	expvar.Do(func(kv expvar.KeyValue) {
+		v, ok := kv.Value.(*expvar.Int)
+		if !ok {
+			return
+		}
+		valueType := prometheus.GaugeValue
+		if strings.HasSuffix(kv.Key, "_total") {
+			valueType = prometheus.CounterValue
+		}
+		desc := prometheus.NewDesc(namespace+"_"+kv.Key, "expvar "+kv.Key+".", nil, nil)
+		ch <- prometheus.MustNewConstMetric(desc, valueType, float64(v.Value()))
+	})
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestHandler(t *testing.T) {
+	DatastoreBytesWritten.Add(42)
+	GateQueueLength.Set(2)
+	CollectorNextReset.Set(time.Now().Add(time.Hour).Unix())
+	APIRequestDuration.WithLabelValues("/agents", "200").Observe(0.2)
+	t.Cleanup(func() {
+		GateQueueLength.Set(0)
+		CollectorNextReset.Set(0)
+	})
+
+	body := scrape(t)
+	for _, want := range []string{
+		"# TYPE fluffy_datastore_written_bytes_total counter",
+		"# TYPE fluffy_gate_queue_length gauge",
+		"fluffy_gate_queue_length 2",
+		"# TYPE fluffy_reset_countdown_seconds gauge",
+		`fluffy_collector_api_request_duration_seconds_bucket{code="200",route="/agents",le="0.25"} 1`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if strings.Contains(body, "fluffy_reset_countdown_seconds 0\n") {
+		t.Error("expected a countdown with the next reset known")
+	}
+}
@@ -0,0 +1,344 @@
+// Package parquet writes flat tables as Apache Parquet files. It covers what
+// the exports need and no more: required INT64 and UTF8 string columns, one
+// PLAIN encoded uncompressed page per column and row group. Rows are held in
+// memory until their row group is full, then written out.
+//
+// The footer is Thrift compact protocol, written by hand to avoid pulling in
+// a Thrift library. Field ids are from parquet.thrift in apache/parquet-format.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+var magic = []byte("PAR1")
+
+type Type int
+
+const (
+	Int64 Type = iota
+	String
+)
+
+type Column struct {
+	Name string
+	Type Type
+}
+
+// physical types, converted types and enums from parquet.thrift
+const (
+	physicalInt64     = 2
+	physicalByteArray = 6
+	convertedUTF8     = 0
+	repetitionReq     = 0
+	encodingPlain     = 0
+	encodingRLE       = 3
+	codecUncompressed = 0
+	pageData          = 0
+)
+
+// RowGroupRows is how many rows a row group holds before it is written
+const RowGroupRows = 64 << 10
+
+type Writer struct {
+	w       io.Writer
+	columns []Column
+	// values holds each column's PLAIN encoded values of the open row group
+	values    []bytes.Buffer
+	groupRows int64
+	maxRows   int64
+	rows      int64
+	// offset is how much of the file has been written
+	offset int64
+	groups []rowGroup
+	closed bool
+}
+
+func NewWriter(w io.Writer, columns []Column) *Writer {
+	return &Writer{w: w, columns: columns, values: make([]bytes.Buffer, len(columns)), maxRows: RowGroupRows}
+}
+
+// Write adds a row, one value per column: int64 (or int) for Int64 columns
+// and string for String ones. A row with a wrong value is not added
+func (pw *Writer) Write(row []any) error {
+	if len(row) != len(pw.columns) {
+		return fmt.Errorf("parquet: row has %d values for %d columns", len(row), len(pw.columns))
+	}
+	encoded := make([][]byte, len(row))
+	for i, c := range pw.columns {
+		switch v := row[i].(type) {
+		case int64:
+			if c.Type == Int64 {
+				encoded[i] = binary.LittleEndian.AppendUint64(nil, uint64(v))
+			}
+		case int:
+			if c.Type == Int64 {
+				encoded[i] = binary.LittleEndian.AppendUint64(nil, uint64(v))
+			}
+		case string:
+			if c.Type == String {
+				encoded[i] = append(binary.LittleEndian.AppendUint32(nil, uint32(len(v))), v...)
+			}
+		}
+		if encoded[i] == nil {
+			return fmt.Errorf("parquet: column %s cannot hold %T", c.Name, row[i])
+		}
+	}
+	for i, e := range encoded {
+		pw.values[i].Write(e)
+	}
+	pw.rows++
+	pw.groupRows++
+	if pw.groupRows >= pw.maxRows {
+		return pw.flush()
+	}
+	return nil
+}
+
+type chunkInfo struct {
+	offset int64
+	size   int64
+}
+
+type rowGroup struct {
+	chunks []chunkInfo
+	rows   int64
+}
+
+func (pw *Writer) write(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	return err
+}
+
+// flush writes the open row group, and the magic before the first one
+func (pw *Writer) flush() error {
+	if pw.offset == 0 {
+		if err := pw.write(magic); err != nil {
+			return err
+		}
+	}
+	g := rowGroup{chunks: make([]chunkInfo, len(pw.columns)), rows: pw.groupRows}
+	for i := range pw.columns {
+		data := pw.values[i].Bytes()
+		header := encodePageHeader(len(data), pw.groupRows)
+		g.chunks[i] = chunkInfo{offset: pw.offset, size: int64(len(header) + len(data))}
+		if err := pw.write(header); err != nil {
+			return err
+		}
+		if err := pw.write(data); err != nil {
+			return err
+		}
+		pw.values[i].Reset()
+	}
+	pw.groups = append(pw.groups, g)
+	pw.groupRows = 0
+	return nil
+}
+
+// Close writes the file. It does not close the underlying writer
+func (pw *Writer) Close() error {
+	if pw.closed {
+		return nil
+	}
+	pw.closed = true
+
+	// an empty table still gets a row group, with empty pages
+	if pw.groupRows > 0 || len(pw.groups) == 0 {
+		if err := pw.flush(); err != nil {
+			return err
+		}
+	}
+	footer := pw.encodeFileMetaData()
+	if err := pw.write(footer); err != nil {
+		return err
+	}
+	if err := pw.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer)))); err != nil {
+		return err
+	}
+	return pw.write(magic)
+}
+
+func physicalType(t Type) int64 {
+	if t == String {
+		return physicalByteArray
+	}
+	return physicalInt64
+}
+
+func encodePageHeader(size int, rows int64) []byte {
+	var e encoder
+	e.i32(1, pageData)
+	e.i32(2, int64(size))
+	e.i32(3, int64(size))
+	e.beginStruct(5) // DataPageHeader
+	e.i32(1, rows)
+	e.i32(2, encodingPlain)
+	e.i32(3, encodingRLE)
+	e.i32(4, encodingRLE)
+	e.endStruct()
+	e.stop()
+	return e.buf.Bytes()
+}
+
+func (pw *Writer) encodeFileMetaData() []byte {
+	var e encoder
+	e.i32(1, 1)
+
+	e.beginList(2, len(pw.columns)+1) // schema, the root first
+	e.beginElem()
+	e.binary(4, "schema")
+	e.i32(5, int64(len(pw.columns)))
+	e.endStruct()
+	for _, c := range pw.columns {
+		e.beginElem()
+		e.i32(1, physicalType(c.Type))
+		e.i32(3, repetitionReq)
+		e.binary(4, c.Name)
+		if c.Type == String {
+			e.i32(6, convertedUTF8)
+		}
+		e.endStruct()
+	}
+
+	e.i64(3, pw.rows)
+
+	e.beginList(4, len(pw.groups)) // row_groups
+	for _, g := range pw.groups {
+		var total int64
+		for _, c := range g.chunks {
+			total += c.size
+		}
+		e.beginElem()
+		e.beginList(1, len(pw.columns)) // columns
+		for i, c := range pw.columns {
+			e.beginElem()
+			e.i64(2, g.chunks[i].offset)
+			e.beginStruct(3) // ColumnMetaData
+			e.i32(1, physicalType(c.Type))
+			e.i32List(2, encodingPlain, encodingRLE)
+			e.stringList(3, c.Name)
+			e.i32(4, codecUncompressed)
+			e.i64(5, g.rows)
+			e.i64(6, g.chunks[i].size)
+			e.i64(7, g.chunks[i].size)
+			e.i64(9, g.chunks[i].offset)
+			e.endStruct()
+			e.endStruct()
+		}
+		e.i64(2, total)
+		e.i64(3, g.rows)
+		e.endStruct()
+	}
+
+	e.binary(6, "fluffy-robot")
+	e.stop()
+	return e.buf.Bytes()
+}
+
+// compact protocol type ids
+const (
+	ctI32    = 5
+	ctI64    = 6
+	ctBinary = 8
+	ctList   = 9
+	ctStruct = 12
+)
+
+// encoder writes Thrift compact protocol. Field ids are delta encoded against
+// the previous field of the same struct, so each open struct keeps its own
+type encoder struct {
+	buf  bytes.Buffer
+	last []int
+	prev int
+}
+
+func (e *encoder) field(id, typ int) {
+	if delta := id - e.prev; delta > 0 && delta <= 15 {
+		e.buf.WriteByte(byte(delta<<4 | typ))
+	} else {
+		e.buf.WriteByte(byte(typ))
+		e.varint(zigzag(int64(id)))
+	}
+	e.prev = id
+}
+
+func (e *encoder) varint(v uint64) {
+	e.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+func (e *encoder) i32(id int, v int64) {
+	e.field(id, ctI32)
+	e.varint(zigzag(v))
+}
+
+func (e *encoder) i64(id int, v int64) {
+	e.field(id, ctI64)
+	e.varint(zigzag(v))
+}
+
+func (e *encoder) binary(id int, s string) {
+	e.field(id, ctBinary)
+	e.varint(uint64(len(s)))
+	e.buf.WriteString(s)
+}
+
+func (e *encoder) listHeader(size, elemType int) {
+	if size < 15 {
+		e.buf.WriteByte(byte(size<<4 | elemType))
+	} else {
+		e.buf.WriteByte(byte(0xf0 | elemType))
+		e.varint(uint64(size))
+	}
+}
+
+func (e *encoder) i32List(id int, values ...int64) {
+	e.field(id, ctList)
+	e.listHeader(len(values), ctI32)
+	for _, v := range values {
+		e.varint(zigzag(v))
+	}
+}
+
+func (e *encoder) stringList(id int, values ...string) {
+	e.field(id, ctList)
+	e.listHeader(len(values), ctBinary)
+	for _, s := range values {
+		e.varint(uint64(len(s)))
+		e.buf.WriteString(s)
+	}
+}
+
+// beginList starts a list of structs, each opened with beginElem and closed
+// with endStruct
+func (e *encoder) beginList(id, size int) {
+	e.field(id, ctList)
+	e.listHeader(size, ctStruct)
+}
+
+func (e *encoder) beginElem() {
+	e.last = append(e.last, e.prev)
+	e.prev = 0
+}
+
+func (e *encoder) beginStruct(id int) {
+	e.field(id, ctStruct)
+	e.beginElem()
+}
+
+func (e *encoder) endStruct() {
+	e.stop()
+	e.prev = e.last[len(e.last)-1]
+	e.last = e.last[:len(e.last)-1]
+}
+
+func (e *encoder) stop() {
+	e.buf.WriteByte(0)
+}
@@ -0,0 +1,264 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// decoder reads Thrift compact protocol into map[field id]value, enough to
+// check what the writer produced
+type decoder struct {
+	b   []byte
+	pos int
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b[d.pos:])
+	d.pos += n
+	return v
+}
+
+func (d *decoder) zigzag() int64 {
+	v := d.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (d *decoder) value(typ byte) any {
+	switch typ {
+	case 1:
+		return true
+	case 2:
+		return false
+	case ctI32, ctI64:
+		return d.zigzag()
+	case ctBinary:
+		n := int(d.uvarint())
+		s := string(d.b[d.pos : d.pos+n])
+		d.pos += n
+		return s
+	case ctList:
+		h := d.b[d.pos]
+		d.pos++
+		size := int(h >> 4)
+		if size == 15 {
+			size = int(d.uvarint())
+		}
+		res := make([]any, size)
+		for i := range res {
+			res[i] = d.value(h & 0x0f)
+		}
+		return res
+	case ctStruct:
+		return d.structure()
+	}
+	panic(fmt.Sprintf("unexpected type %d", typ))
+}
+
+func (d *decoder) structure() map[int]any {
+	res := make(map[int]any)
+	prev := 0
+	for {
+		h := d.b[d.pos]
+		d.pos++
+		if h == 0 {
+			return res
+		}
+		id := prev + int(h>>4)
+		if h>>4 == 0 {
+			id = int(d.zigzag())
+		}
+		res[id] = d.value(h & 0x0f)
+		prev = id
+	}
+}
+
+func TestWriter(t *testing.T) {
+	columns := []Column{{"timestamp", Int64}, {"symbol", String}, {"credits", Int64}}
+	rows := [][]any{
+		{int64(1767225600), "ALPHA", int64(175000)},
+		{int64(1767225900), "BRAVO", 250000},
+		{int64(1767226200), "", int64(-5)},
+	}
+	var buf bytes.Buffer
+	pw := NewWriter(&buf, columns)
+	for _, r := range rows {
+		if err := pw.Write(r); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := pw.Write([]any{int64(1), "ALPHA", "1"}); err == nil {
+		t.Fatalf("expected an error for a string in an integer column")
+	}
+	if pw.rows != int64(len(rows)) {
+		t.Fatalf("a rejected row was counted")
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	b := buf.Bytes()
+	if !bytes.HasPrefix(b, magic) || !bytes.HasSuffix(b, magic) {
+		t.Fatalf("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	footer := &decoder{b: b[len(b)-8-footerLen : len(b)-8]}
+	meta := footer.structure()
+	if footer.pos != footerLen {
+		t.Fatalf("footer decoded %d of %d bytes", footer.pos, footerLen)
+	}
+	if meta[3] != int64(len(rows)) {
+		t.Fatalf("num_rows = %v", meta[3])
+	}
+	schema := meta[2].([]any)
+	if len(schema) != len(columns)+1 || schema[0].(map[int]any)[5] != int64(len(columns)) {
+		t.Fatalf("schema = %v", schema)
+	}
+
+	chunks := meta[4].([]any)[0].(map[int]any)[1].([]any)
+	for i, c := range columns {
+		if name := schema[i+1].(map[int]any)[4]; name != c.Name {
+			t.Fatalf("schema column %d is %v", i, name)
+		}
+		cm := chunks[i].(map[int]any)[3].(map[int]any)
+		if cm[3].([]any)[0] != c.Name || cm[5] != int64(len(rows)) {
+			t.Fatalf("column metadata %v", cm)
+		}
+
+		// the page at data_page_offset holds the values in order
+		page := &decoder{b: b, pos: int(cm[9].(int64))}
+		header := page.structure()
+		if header[5].(map[int]any)[1] != int64(len(rows)) || int64(page.pos)+header[2].(int64) != cm[9].(int64)+cm[6].(int64) {
+			t.Fatalf("page header %v", header)
+		}
+		for j, r := range rows {
+			var got any
+			switch c.Type {
+			case Int64:
+				got = int64(binary.LittleEndian.Uint64(b[page.pos:]))
+				page.pos += 8
+			case String:
+				n := int(binary.LittleEndian.Uint32(b[page.pos:]))
+				got = string(b[page.pos+4 : page.pos+4+n])
+				page.pos += 4 + n
+			}
+			want := r[i]
+			if n, ok := want.(int); ok {
+				want = int64(n)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("row %d column %s = %v, want %v", j, c.Name, got, want)
+			}
+		}
+	}
+}
+
+func footerOf(t *testing.T, b []byte) map[int]any {
+	t.Helper()
+	if !bytes.HasPrefix(b, magic) || !bytes.HasSuffix(b, magic) {
+		t.Fatalf("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	return (&decoder{b: b[len(b)-8-footerLen : len(b)-8]}).structure()
+}
+
+func TestWriter_RowGroups(t *testing.T) {
+	var buf bytes.Buffer
+	pw := NewWriter(&buf, []Column{{"n", Int64}})
+	pw.maxRows = 2
+	for i := 0; i < 5; i++ {
+		if err := pw.Write([]any{i}); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	// full row groups are written as they fill up
+	if buf.Len() == 0 {
+		t.Fatalf("expected the first row groups to be written before Close")
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	b := buf.Bytes()
+	meta := footerOf(t, b)
+	groups := meta[4].([]any)
+	if meta[3] != int64(5) || len(groups) != 3 {
+		t.Fatalf("num_rows = %v, %d row groups", meta[3], len(groups))
+	}
+	var got []int64
+	for _, g := range groups {
+		rg := g.(map[int]any)
+		cm := rg[1].([]any)[0].(map[int]any)[3].(map[int]any)
+		page := &decoder{b: b, pos: int(cm[9].(int64))}
+		page.structure()
+		for j := int64(0); j < rg[3].(int64); j++ {
+			got = append(got, int64(binary.LittleEndian.Uint64(b[page.pos:])))
+			page.pos += 8
+		}
+	}
+	if !reflect.DeepEqual(got, []int64{0, 1, 2, 3, 4}) {
+		t.Fatalf("values = %v", got)
+	}
+}
+
+// TestWriter_PyArrow reads the output back with pyarrow, the reader pandas
+// uses, so the file is checked by something other than this package. It is
+// skipped where pyarrow is not installed
+func TestWriter_PyArrow(t *testing.T) {
+	if err := exec.Command("python3", "-c", "import pyarrow.parquet").Run(); err != nil {
+		t.Skip("pyarrow is not installed")
+	}
+	columns := []Column{{"timestamp", Int64}, {"symbol", String}, {"credits", Int64}}
+	var want []map[string]any
+	var buf bytes.Buffer
+	pw := NewWriter(&buf, columns)
+	pw.maxRows = 3
+	for i := 0; i < 7; i++ {
+		row := []any{int64(1767225600 + i*300), fmt.Sprintf("AGENT-%d", i), int64(-5 + i*100000)}
+		if err := pw.Write(row); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		want = append(want, map[string]any{"timestamp": row[0], "symbol": row[1], "credits": row[2]})
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "table.parquet")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	script := `import json, sys, pyarrow.parquet as pq
+f = pq.ParquetFile(sys.argv[1])
+print(json.dumps({"groups": f.metadata.num_row_groups, "schema": [str(t) for t in f.schema_arrow.types], "rows": f.read().to_pylist()}))`
+	out, err := exec.Command("python3", "-c", script, path).Output()
+	if err != nil {
+		t.Fatalf("pyarrow could not read the file: %v", err)
+	}
+	var got struct {
+		Groups int
+		Schema []string
+		Rows   []map[string]any
+	}
+	dec := json.NewDecoder(bytes.NewReader(out))
+	dec.UseNumber()
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("decoding %s: %v", out, err)
+	}
+	if got.Groups != 3 || !reflect.DeepEqual(got.Schema, []string{"int64", "string", "int64"}) || len(got.Rows) != len(want) {
+		t.Fatalf("pyarrow read %s", out)
+	}
+	for i, r := range got.Rows {
+		for k, v := range want[i] {
+			if fmt.Sprint(r[k]) != fmt.Sprint(v) {
+				t.Fatalf("row %d %s = %v, want %v", i, k, r[k], v)
+			}
+		}
+	}
+}
@@ -2,33 +2,112 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"io"
 	"os"
-	"strconv"
-	"time"
+	"os/signal"
+	"sync"
+	"syscall"
 
+	"github.com/papaburgs/fluffy-robot/internal/alerts"
 	"github.com/papaburgs/fluffy-robot/internal/collector"
+	"github.com/papaburgs/fluffy-robot/internal/config"
 	"github.com/papaburgs/fluffy-robot/internal/datastore"
+	"github.com/papaburgs/fluffy-robot/internal/events"
 	"github.com/papaburgs/fluffy-robot/internal/frontend"
 	"github.com/papaburgs/fluffy-robot/internal/gate"
 	"github.com/papaburgs/fluffy-robot/internal/logging"
 )
 
 func main() {
-	logging.InitLogger()
+	configPath := flag.String("config", os.Getenv("FLUFFY_CONFIG"), "YAML config file, FLUFFY_* variables override it")
+	flag.Parse()
 
-	gateBucketSize, err := strconv.Atoi(os.Getenv("FLUFFY_GATE_BUCKET_SIZE"))
+	cfg, err := config.Load(*configPath)
 	if err != nil {
-		logging.Error("error parsing FLUFFY_GATE_BUCKET_SIZE, defaulting to 20", "error", err)
-		gateBucketSize = 20
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
 	}
-	baseURL := "https://api.spacetraders.io/v2"
+	logging.InitLogger(cfg.LogLevel)
+	logging.Info("effective config:\n" + cfg.String())
 
-	c := collector.NewCollector(gate.New(2, gateBucketSize), baseURL)
+	store, err := datastore.NewStore(cfg.Datastore)
+	if err != nil {
+		logging.Error("failed to open datastore", err)
+		os.Exit(1)
+	}
+
+	if ic, ok := store.(datastore.IntegrityChecker); ok {
+		if bad, err := ic.CheckIntegrity(); err != nil {
+			logging.Error("integrity check failed", err)
+		} else if len(bad) > 0 {
+			logging.Warn("quarantined unreadable files", "files", bad)
+		}
+	}
 
-	datastore.Init()
-	time.Sleep(time.Second)
-	go c.Run(context.Background())
+	// the first interrupt shuts down cleanly, a second one kills
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+		logging.Info("shutting down, interrupt again to force")
+	}()
 
-	time.Sleep(2 * time.Second)
-	frontend.StartServer()
+	var wg sync.WaitGroup
+	background := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+
+	if cs, ok := store.(datastore.Compactor); ok {
+		background(func() { cs.RunCompaction(ctx, cfg.Datastore.CompactionInterval) })
+	}
+	if j, ok := store.(datastore.Janitor); ok {
+		background(func() {
+			datastore.RunRetention(ctx, j, datastore.NewRetentionPolicy(cfg.Datastore.Retention), cfg.Datastore.Retention.Interval)
+		})
+	}
+
+	bus := events.NewBus()
+	g := gate.New(cfg.Gate.PerSecond, cfg.Gate.Burst)
+	c := collector.NewCollector(g, cfg.Collector, store)
+	c.PublishTo(bus)
+	if cfg.AlertsFile != "" {
+		rules, err := alerts.LoadFile(cfg.AlertsFile)
+		if err != nil {
+			logging.Error("failed to load alert rules", err)
+			os.Exit(1)
+		}
+		logging.Info("loaded alert rules", "rules", len(rules.Rules), "webhooks", len(rules.Webhooks))
+		c.AlertWith(alerts.NewEngine(store, rules))
+	}
+
+	background(func() { c.Run(ctx) })
+	// serve once the collector knows which reset it is in
+	<-c.Ready()
+
+	exitCode := 0
+	if err := frontend.StartServer(ctx, cfg.Frontend, store, bus); err != nil {
+		logging.Error("server failed", err)
+		exitCode = 1
+		stop()
+	}
+
+	// ctx is done by now, from a signal or the stop above, so everything
+	// else is winding down too
+	wg.Wait()
+	g.Stop()
+	if closer, ok := store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logging.Error("failed to close datastore", err)
+			exitCode = 1
+		}
+	}
+	logging.Info("stopped")
+	os.Exit(exitCode)
 }
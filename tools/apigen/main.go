@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/papaburgs/fluffy-robot/internal/frontend"
+)
+
+// apigen writes the client package's types and methods from the frontend's
+// OpenAPI document. Run it with go generate ./client after changing a route
+// or a type the API returns
+func main() {
+	out := flag.String("out", "client/api_gen.go", "file to write")
+	flag.Parse()
+
+	spec, err := frontend.OpenAPISpec()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error building the OpenAPI document: %v\n", err)
+		os.Exit(1)
+	}
+	src, err := generate(spec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating the client: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*out, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", *out, err)
+		os.Exit(1)
+	}
+}
+
+// the parts of an OpenAPI document the generator reads
+
+type schema struct {
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Ref                  string             `json:"$ref"`
+	Items                *schema            `json:"items"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *schema            `json:"additionalProperties"`
+}
+
+type parameter struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Schema      schema `json:"schema"`
+}
+
+type operation struct {
+	OperationID string      `json:"operationId"`
+	Summary     string      `json:"summary"`
+	Parameters  []parameter `json:"parameters"`
+	Responses   map[string]struct {
+		Content map[string]struct {
+			Schema schema `json:"schema"`
+		} `json:"content"`
+	} `json:"responses"`
+}
+
+type document struct {
+	Paths map[string]struct {
+		Get *operation `json:"get"`
+	} `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type generator struct {
+	buf     bytes.Buffer
+	imports map[string]bool
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func exportName(name string) string {
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (g *generator) goType(s *schema) (string, error) {
+	if s.Ref != "" {
+		return s.Ref[strings.LastIndex(s.Ref, "/")+1:], nil
+	}
+	switch s.Type {
+	case "array":
+		t, err := g.goType(s.Items)
+		return "[]" + t, err
+	case "object":
+		if s.AdditionalProperties == nil {
+			return "", fmt.Errorf("inline objects are not supported")
+		}
+		t, err := g.goType(s.AdditionalProperties)
+		return "map[string]" + t, err
+	case "string":
+		if s.Format == "date-time" {
+			g.imports["time"] = true
+			return "time.Time", nil
+		}
+		return "string", nil
+	case "boolean":
+		return "bool", nil
+	case "integer":
+		switch s.Format {
+		case "int64":
+			return "int64", nil
+		case "int32":
+			return "int32", nil
+		}
+		return "int", nil
+	case "number":
+		return "float64", nil
+	}
+	return "", fmt.Errorf("unsupported schema type %q", s.Type)
+}
+
+func (g *generator) writeType(name string, s *schema) error {
+	required := make(map[string]bool)
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	g.printf("type %s struct {\n", name)
+	for _, prop := range sortedKeys(s.Properties) {
+		t, err := g.goType(s.Properties[prop])
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", name, prop, err)
+		}
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+		}
+		g.printf("%s %s `json:\"%s\"`\n", exportName(prop), t, tag)
+	}
+	g.printf("}\n\n")
+	return nil
+}
+
+// writeParams writes the parameters struct of op and the method turning it
+// into a query. Zero values are left out so the server's defaults apply
+func (g *generator) writeParams(name string, op *operation) error {
+	g.printf("type %s struct {\n", name)
+	for _, p := range op.Parameters {
+		t, err := g.goType(&p.Schema)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", name, p.Name, err)
+		}
+		g.printf("// %s\n%s %s\n", p.Description, exportName(p.Name), t)
+	}
+	g.printf("}\n\n")
+
+	g.imports["net/url"] = true
+	g.printf("func (p %s) values() url.Values {\nv := url.Values{}\n", name)
+	for _, p := range op.Parameters {
+		field := "p." + exportName(p.Name)
+		switch t, _ := g.goType(&p.Schema); t {
+		case "[]string":
+			g.imports["strings"] = true
+			g.printf("if len(%s) > 0 {\nv.Set(%q, strings.Join(%s, \",\"))\n}\n", field, p.Name, field)
+		case "string":
+			g.printf("if %s != \"\" {\nv.Set(%q, %s)\n}\n", field, p.Name, field)
+		case "bool":
+			g.printf("if %s {\nv.Set(%q, \"true\")\n}\n", field, p.Name)
+		case "int":
+			g.imports["strconv"] = true
+			g.printf("if %s != 0 {\nv.Set(%q, strconv.Itoa(%s))\n}\n", field, p.Name, field)
+		case "int64":
+			g.imports["strconv"] = true
+			g.printf("if %s != 0 {\nv.Set(%q, strconv.FormatInt(%s, 10))\n}\n", field, p.Name, field)
+		default:
+			return fmt.Errorf("%s.%s: unsupported parameter type %s", name, p.Name, t)
+		}
+	}
+	g.printf("return v\n}\n\n")
+	return nil
+}
+
+func (g *generator) writeOperation(path string, op *operation) error {
+	name := exportName(op.OperationID)
+	resp, ok := op.Responses["200"].Content["application/json"]
+	if !ok {
+		return fmt.Errorf("%s has no JSON response", path)
+	}
+	data, ok := resp.Schema.Properties["data"]
+	if !ok {
+		return fmt.Errorf("%s has no data in its response", path)
+	}
+	dataType, err := g.goType(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	args, query := "", "nil"
+	if len(op.Parameters) > 0 {
+		if err := g.writeParams(name+"Params", op); err != nil {
+			return err
+		}
+		args, query = ", p "+name+"Params", "p.values()"
+	}
+	g.imports["context"] = true
+	g.printf("// %s returns %s\n", name, strings.ToLower(op.Summary[:1])+op.Summary[1:])
+	g.printf("func (c *Client) %s(ctx context.Context%s) (%s, *APIMeta, error) {\n", name, args, dataType)
+	g.printf("var data %s\nmeta, err := c.get(ctx, %q, %s, &data)\nreturn data, meta, err\n}\n\n", dataType, path, query)
+	return nil
+}
+
+func generate(spec []byte) ([]byte, error) {
+	var doc document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, err
+	}
+	g := &generator{imports: make(map[string]bool)}
+	for _, name := range sortedKeys(doc.Components.Schemas) {
+		if err := g.writeType(name, doc.Components.Schemas[name]); err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range sortedKeys(doc.Paths) {
+		if op := doc.Paths[path].Get; op != nil {
+			if err := g.writeOperation(path, op); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var head bytes.Buffer
+	head.WriteString("// Code generated by tools/apigen from the OpenAPI document. DO NOT EDIT.\n\npackage client\n\nimport (\n")
+	for _, imp := range sortedKeys(g.imports) {
+		fmt.Fprintf(&head, "%q\n", imp)
+	}
+	head.WriteString(")\n\n")
+	head.Write(g.buf.Bytes())
+	return format.Source(head.Bytes())
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/papaburgs/fluffy-robot/internal/frontend"
+)
+
+func TestClientUpToDate(t *testing.T) {
+	spec, err := frontend.OpenAPISpec()
+	if err != nil {
+		t.Fatalf("OpenAPISpec: %v", err)
+	}
+	want, err := generate(spec)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	got, err := os.ReadFile("../../client/api_gen.go")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("client/api_gen.go is out of date, run go generate ./client")
+	}
+}
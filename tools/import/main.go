@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/papaburgs/fluffy-robot/internal/config"
+	"github.com/papaburgs/fluffy-robot/internal/datastore"
+)
+
+// import loads an archive downloaded from /export into the configured store,
+// read from FLUFFY_CONFIG and the FLUFFY_* variables. Stop the collector
+// first if the archive holds the current reset
+func main() {
+	dryRun := flag.Bool("dry-run", false, "only report what would be imported")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-dry-run] <archive.tar.gz>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening archive: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	cfg, err := config.FromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	s, err := datastore.NewStore(cfg.Datastore)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening datastore: %v\n", err)
+		os.Exit(1)
+	}
+	if c, ok := s.(io.Closer); ok {
+		defer c.Close()
+	}
+	importer, ok := s.(datastore.Importer)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: this datastore cannot import\n")
+		os.Exit(1)
+	}
+
+	report, err := importer.Import(f, *dryRun)
+	for _, p := range report.Invalid {
+		fmt.Printf("invalid   %s: %s\n", p.File, p.Reason)
+	}
+	for _, p := range report.Conflicts {
+		fmt.Printf("conflict  %s: %s\n", p.File, p.Reason)
+	}
+	for _, p := range report.Skipped {
+		fmt.Printf("skipped   %s: %s\n", p.File, p.Reason)
+	}
+	for _, name := range report.Imported {
+		fmt.Printf("imported  %s\n", name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing: %v\n", err)
+		os.Exit(1)
+	}
+	verb := "imported"
+	if *dryRun {
+		verb = "to import"
+	}
+	fmt.Printf("%d files %s, %d unchanged, %d conflicts\n", len(report.Imported), verb, len(report.Unchanged), len(report.Conflicts))
+}
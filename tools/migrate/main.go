@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/papaburgs/fluffy-robot/internal/config"
+	"github.com/papaburgs/fluffy-robot/internal/datastore"
+)
+
+// migrate rewrites every snapshot under the configured storage path that was
+// written with an older schema version. Stop the collector first
+func main() {
+	dryRun := flag.Bool("dry-run", false, "only list the files that would be rewritten")
+	flag.Parse()
+
+	cfg, err := config.FromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	d, err := datastore.OpenDiskStore(cfg.Datastore)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening datastore: %v\n", err)
+		os.Exit(1)
+	}
+	files, err := d.Migrate(*dryRun)
+	for _, f := range files {
+		fmt.Println(f)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error migrating: %v\n", err)
+		os.Exit(1)
+	}
+	if *dryRun {
+		fmt.Printf("%d files to migrate\n", len(files))
+	} else {
+		fmt.Printf("%d files migrated\n", len(files))
+	}
+}